@@ -0,0 +1,711 @@
+// Package client is the Go client of the task distributer's API.
+//
+//	c, err := client.New("https://ancient-mountain-96195.herokuapp.com", apiKey)
+//	if err != nil {
+//		return err
+//	}
+//	task, err := c.CreateTask(ctx, client.CreateTaskRequest{
+//		Name:     "My Cool Task",
+//		Skills:   []string{"skill1"},
+//		Priority: "high",
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API with an API key or bearer token.  It is safe to use
+// from multiple goroutines.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the HTTP client instead of
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries retries a request up to retries times, waiting backoff before
+// the first retry and doubling it before each retry after that.  Requests are
+// retried when the server can not be reached or responds with a 429, 502, 503
+// or 504.  Only GET, PUT and DELETE requests are retried on every such error,
+// a POST is only retried when the server could not be reached, so a task is
+// never created, completed or commented on twice.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client of the API at the base URL, like
+// https://ancient-mountain-96195.herokuapp.com.  By default requests are
+// retried twice, after 100ms and then 200ms.
+func New(baseURL, apiKey string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base URL %s must be an absolute URL", baseURL)
+	}
+	if apiKey == "" {
+		return nil, errors.New("API key must be present")
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+		retries:    2,
+		backoff:    100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// CreateTask creates a task and distributes it to an agent.
+func (c *Client) CreateTask(ctx context.Context, task CreateTaskRequest) (Task, error) {
+	var resp struct {
+		Task Task `json:"task"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/task/create", task, &resp)
+	return resp.Task, err
+}
+
+// CreateTaskFromTemplate distributes a task to an agent, the fields the
+// request does not have are filled in with the defaults of the template.
+func (c *Client) CreateTaskFromTemplate(ctx context.Context, template string, task CreateTaskRequest) (Task, error) {
+	var resp struct {
+		Task Task `json:"task"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/task/create?template="+url.QueryEscape(template), task, &resp)
+	return resp.Task, err
+}
+
+// GetTask returns the task.
+func (c *Client) GetTask(ctx context.Context, id string) (Task, error) {
+	var resp struct {
+		Task Task `json:"task"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/task/"+url.PathEscape(id), nil, &resp)
+	return resp.Task, err
+}
+
+// CompleteTask marks the task as complete on behalf of the agent.
+func (c *Client) CompleteTask(ctx context.Context, id string) error {
+	return c.CompleteTaskWithResult(ctx, id, TaskResult{})
+}
+
+// CompleteTaskWithResult marks the task as complete on behalf of the agent,
+// with the result.
+func (c *Client) CompleteTaskWithResult(ctx context.Context, id string, result TaskResult) error {
+	return c.do(ctx, http.MethodPost, "/v1/task/"+url.PathEscape(id)+"/complete", result, nil)
+}
+
+// ListComments returns the comments of the task, oldest first.
+func (c *Client) ListComments(ctx context.Context, taskID string) ([]Comment, error) {
+	return c.listComments(ctx, "/v1/task/"+url.PathEscape(taskID)+"/comments")
+}
+
+// CreateComment adds a comment to the task.
+func (c *Client) CreateComment(ctx context.Context, taskID, body string) (Comment, error) {
+	return c.createComment(ctx, "/v1/task/"+url.PathEscape(taskID)+"/comments", body)
+}
+
+// ListAgentComments returns the comments of a task assigned to the agent,
+// oldest first.
+func (c *Client) ListAgentComments(ctx context.Context, agentID, taskID string) ([]Comment, error) {
+	return c.listComments(ctx, "/v1/agent/"+url.PathEscape(agentID)+"/tasks/"+url.PathEscape(taskID)+"/comments")
+}
+
+// CreateAgentComment adds a comment to a task assigned to the agent.
+func (c *Client) CreateAgentComment(ctx context.Context, agentID, taskID, body string) (Comment, error) {
+	return c.createComment(ctx, "/v1/agent/"+url.PathEscape(agentID)+"/tasks/"+url.PathEscape(taskID)+"/comments", body)
+}
+
+func (c *Client) listComments(ctx context.Context, path string) ([]Comment, error) {
+	var resp struct {
+		Comments []Comment `json:"comments"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &resp)
+	return resp.Comments, err
+}
+
+func (c *Client) createComment(ctx context.Context, path, body string) (Comment, error) {
+	req := struct {
+		Body string `json:"body"`
+	}{
+		Body: body,
+	}
+	var resp struct {
+		Comment Comment `json:"comment"`
+	}
+	err := c.do(ctx, http.MethodPost, path, req, &resp)
+	return resp.Comment, err
+}
+
+// UploadAttachment attaches the contents to the task, with the file name and
+// content type, which must be one of the types the server allows.
+func (c *Client) UploadAttachment(ctx context.Context, taskID, name, contentType string, data []byte) (Attachment, error) {
+	return c.uploadAttachment(ctx, "/v1/task/"+url.PathEscape(taskID)+"/attachments", name, contentType, data)
+}
+
+// ListAttachments returns the attachments of the task, oldest first.
+func (c *Client) ListAttachments(ctx context.Context, taskID string) ([]Attachment, error) {
+	return c.listAttachments(ctx, "/v1/task/"+url.PathEscape(taskID)+"/attachments")
+}
+
+// DownloadAttachment returns the contents of the task's attachment, once
+// their checksum is verified.
+func (c *Client) DownloadAttachment(ctx context.Context, taskID, id string) ([]byte, error) {
+	return c.downloadAttachment(ctx, "/v1/task/"+url.PathEscape(taskID)+"/attachments/"+url.PathEscape(id))
+}
+
+// UploadAgentAttachment attaches the contents to a task assigned to the agent.
+func (c *Client) UploadAgentAttachment(ctx context.Context, agentID, taskID, name, contentType string, data []byte) (Attachment, error) {
+	return c.uploadAttachment(ctx, "/v1/agent/"+url.PathEscape(agentID)+"/tasks/"+url.PathEscape(taskID)+"/attachments", name, contentType, data)
+}
+
+// ListAgentAttachments returns the attachments of a task assigned to the
+// agent, oldest first.
+func (c *Client) ListAgentAttachments(ctx context.Context, agentID, taskID string) ([]Attachment, error) {
+	return c.listAttachments(ctx, "/v1/agent/"+url.PathEscape(agentID)+"/tasks/"+url.PathEscape(taskID)+"/attachments")
+}
+
+// DownloadAgentAttachment returns the contents of an attachment of a task
+// assigned to the agent, once their checksum is verified.
+func (c *Client) DownloadAgentAttachment(ctx context.Context, agentID, taskID, id string) ([]byte, error) {
+	return c.downloadAttachment(ctx, "/v1/agent/"+url.PathEscape(agentID)+"/tasks/"+url.PathEscape(taskID)+"/attachments/"+url.PathEscape(id))
+}
+
+func (c *Client) uploadAttachment(ctx context.Context, path, name, contentType string, data []byte) (Attachment, error) {
+	sum := sha256.Sum256(data)
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set(checksumHeader, hex.EncodeToString(sum[:]))
+	path += "?" + url.Values{"name": {name}}.Encode()
+	var resp struct {
+		Attachment Attachment `json:"attachment"`
+	}
+	// A repeated upload would attach the contents twice.
+	err := c.retry(ctx, false, func() error {
+		b, _, err := c.roundTrip(ctx, http.MethodPost, path, header, data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, &resp)
+	})
+	return resp.Attachment, err
+}
+
+func (c *Client) listAttachments(ctx context.Context, path string) ([]Attachment, error) {
+	var resp struct {
+		Attachments []Attachment `json:"attachments"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &resp)
+	return resp.Attachments, err
+}
+
+func (c *Client) downloadAttachment(ctx context.Context, path string) ([]byte, error) {
+	var (
+		data   []byte
+		header http.Header
+	)
+	accept := http.Header{}
+	accept.Set("Accept", "*/*")
+	err := c.retry(ctx, true, func() error {
+		var err error
+		data, header, err = c.roundTrip(ctx, http.MethodGet, path, accept, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if expected, checksum := header.Get(checksumHeader), hex.EncodeToString(sum[:]); expected != "" && expected != checksum {
+		return nil, fmt.Errorf("attachment SHA-256 is %s, not %s", checksum, expected)
+	}
+	return data, nil
+}
+
+// ListTasks returns the tasks selected by the filter, newest first.
+func (c *Client) ListTasks(ctx context.Context, filter TaskFilter) ([]Task, error) {
+	query := url.Values{}
+	if len(filter.Statuses) > 0 {
+		query.Set("status", strings.Join(filter.Statuses, ","))
+	}
+	if filter.Agent != "" {
+		query.Set("agent", filter.Agent)
+	}
+	if len(filter.Tags) > 0 {
+		query.Set("tag", strings.Join(filter.Tags, ","))
+	}
+	for key, value := range filter.Metadata {
+		query.Set("metadata."+key, value)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	path := "/v1/task"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var resp struct {
+		Tasks []Task `json:"tasks"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &resp)
+	return resp.Tasks, err
+}
+
+// CancelTask cancels a task that is not complete.
+func (c *Client) CancelTask(ctx context.Context, id string) (Task, error) {
+	var resp struct {
+		Task Task `json:"task"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/task/"+url.PathEscape(id)+"/cancel", nil, &resp)
+	return resp.Task, err
+}
+
+// CreateSchedule adds a schedule of a task, held until its start time or
+// recurring on its cron expression.
+func (c *Client) CreateSchedule(ctx context.Context, schedule CreateScheduleRequest) (Schedule, error) {
+	var resp struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/schedule", schedule, &resp)
+	return resp.Schedule, err
+}
+
+// ListSchedules returns the schedules, oldest first.
+func (c *Client) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	var resp struct {
+		Schedules []Schedule `json:"schedules"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/schedule", nil, &resp)
+	return resp.Schedules, err
+}
+
+// GetSchedule returns the schedule with its last run.
+func (c *Client) GetSchedule(ctx context.Context, id string) (Schedule, error) {
+	return c.scheduleAction(ctx, http.MethodGet, "/v1/schedule/"+url.PathEscape(id))
+}
+
+// PauseSchedule stops the schedule from distributing tasks until it is
+// resumed.
+func (c *Client) PauseSchedule(ctx context.Context, id string) (Schedule, error) {
+	return c.scheduleAction(ctx, http.MethodPost, "/v1/schedule/"+url.PathEscape(id)+"/pause")
+}
+
+// ResumeSchedule resumes the schedule from the next time its cron expression
+// matches.
+func (c *Client) ResumeSchedule(ctx context.Context, id string) (Schedule, error) {
+	return c.scheduleAction(ctx, http.MethodPost, "/v1/schedule/"+url.PathEscape(id)+"/resume")
+}
+
+// DeleteSchedule deletes the schedule, the tasks it distributed are kept.
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/schedule/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) scheduleAction(ctx context.Context, method, path string) (Schedule, error) {
+	var resp struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	err := c.do(ctx, method, path, nil, &resp)
+	return resp.Schedule, err
+}
+
+// CreateTemplate adds a template of the defaults of a kind of task.
+func (c *Client) CreateTemplate(ctx context.Context, template CreateTemplateRequest) (Template, error) {
+	var resp struct {
+		Template Template `json:"template"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/template", template, &resp)
+	return resp.Template, err
+}
+
+// ListTemplates returns the templates, by name.
+func (c *Client) ListTemplates(ctx context.Context) ([]Template, error) {
+	var resp struct {
+		Templates []Template `json:"templates"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/template", nil, &resp)
+	return resp.Templates, err
+}
+
+// GetTemplate returns the template.
+func (c *Client) GetTemplate(ctx context.Context, name string) (Template, error) {
+	var resp struct {
+		Template Template `json:"template"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/template/"+url.PathEscape(name), nil, &resp)
+	return resp.Template, err
+}
+
+// DeleteTemplate deletes the template, the tasks created with it are kept.
+func (c *Client) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/template/"+url.PathEscape(name), nil, nil)
+}
+
+// ListEvents returns up to limit events after the event id, oldest first.  A
+// limit of 0 is the API's default of 100.
+func (c *Client) ListEvents(ctx context.Context, after int64, limit int) ([]Event, error) {
+	query := url.Values{}
+	query.Set("after", strconv.FormatInt(after, 10))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Events []Event `json:"events"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/event?"+query.Encode(), nil, &resp)
+	return resp.Events, err
+}
+
+// ListAgents returns every agent with the tasks they are working on.
+func (c *Client) ListAgents(ctx context.Context) ([]AgentTasks, error) {
+	var resp struct {
+		AgentTasks []AgentTasks `json:"agent_tasks"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/agent", nil, &resp)
+	return resp.AgentTasks, err
+}
+
+// AgentReports returns the report of each agent in each window of the
+// period, by agent then window.
+func (c *Client) AgentReports(ctx context.Context, filter ReportFilter) ([]AgentReport, error) {
+	var resp struct {
+		Agents []AgentReport `json:"agents"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/report/agents"+filter.query(""), nil, &resp)
+	return resp.Agents, err
+}
+
+// AgentReportsCSV returns the agent reports as a CSV file with a header.
+func (c *Client) AgentReportsCSV(ctx context.Context, filter ReportFilter) ([]byte, error) {
+	return c.downloadCSV(ctx, "/v1/report/agents"+filter.query("csv"))
+}
+
+// QueueReports returns the report of each skill and priority in each window
+// of the period, by window then skill and priority.  The filter's agent is
+// ignored.
+func (c *Client) QueueReports(ctx context.Context, filter ReportFilter) ([]QueueReport, error) {
+	filter.Agent = ""
+	var resp struct {
+		Queues []QueueReport `json:"queues"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/report/queues"+filter.query(""), nil, &resp)
+	return resp.Queues, err
+}
+
+// QueueReportsCSV returns the queue reports as a CSV file with a header.
+func (c *Client) QueueReportsCSV(ctx context.Context, filter ReportFilter) ([]byte, error) {
+	filter.Agent = ""
+	return c.downloadCSV(ctx, "/v1/report/queues"+filter.query("csv"))
+}
+
+// SkillCoverage returns the skills with at most maxAgents agents, fewest
+// agents first.  A negative maxAgents returns every skill.
+func (c *Client) SkillCoverage(ctx context.Context, maxAgents int) ([]SkillCoverage, error) {
+	var resp struct {
+		Skills []SkillCoverage `json:"skills"`
+	}
+	err := c.do(ctx, http.MethodGet, skillCoveragePath(maxAgents, ""), nil, &resp)
+	return resp.Skills, err
+}
+
+// SkillCoverageCSV returns the skill coverage as a CSV file with a header.
+func (c *Client) SkillCoverageCSV(ctx context.Context, maxAgents int) ([]byte, error) {
+	return c.downloadCSV(ctx, skillCoveragePath(maxAgents, "csv"))
+}
+
+func skillCoveragePath(maxAgents int, format string) string {
+	query := url.Values{}
+	if maxAgents >= 0 {
+		query.Set("max_agents", strconv.Itoa(maxAgents))
+	}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if len(query) == 0 {
+		return "/v1/report/skills"
+	}
+	return "/v1/report/skills?" + query.Encode()
+}
+
+func (c *Client) downloadCSV(ctx context.Context, path string) ([]byte, error) {
+	var data []byte
+	accept := http.Header{}
+	accept.Set("Accept", "text/csv")
+	err := c.retry(ctx, true, func() error {
+		var err error
+		data, _, err = c.roundTrip(ctx, http.MethodGet, path, accept, nil)
+		return err
+	})
+	return data, err
+}
+
+// query returns the query string of the filter in the format.
+func (f ReportFilter) query(format string) string {
+	query := url.Values{}
+	if !f.From.IsZero() {
+		query.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		query.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.Window > 0 {
+		query.Set("window", f.Window.String())
+	}
+	if f.Agent != "" {
+		query.Set("agent", f.Agent)
+	}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// CreateAgent adds an agent with their skills.
+func (c *Client) CreateAgent(ctx context.Context, agent Agent) (Agent, error) {
+	var resp struct {
+		Agent Agent `json:"agent"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/agent", agent, &resp)
+	return resp.Agent, err
+}
+
+// UpdateAgentSkills replaces the skills of the agent.
+func (c *Client) UpdateAgentSkills(ctx context.Context, agentID string, skills []string) (Agent, error) {
+	if skills == nil {
+		skills = []string{}
+	}
+	req := struct {
+		Skills []string `json:"skills"`
+	}{
+		Skills: skills,
+	}
+	var resp struct {
+		Agent Agent `json:"agent"`
+	}
+	err := c.do(ctx, http.MethodPut, "/v1/agent/"+url.PathEscape(agentID)+"/skills", req, &resp)
+	return resp.Agent, err
+}
+
+// AgentTasks returns the tasks the agent is working on.
+func (c *Client) AgentTasks(ctx context.Context, agentID string) ([]Task, error) {
+	var resp struct {
+		Tasks []Task `json:"tasks"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/agent/"+url.PathEscape(agentID)+"/tasks", nil, &resp)
+	return resp.Tasks, err
+}
+
+// AcceptTask accepts a task assigned to the agent.
+func (c *Client) AcceptTask(ctx context.Context, agentID, taskID string) (Task, error) {
+	return c.agentTaskAction(ctx, agentID, taskID, "accept", nil)
+}
+
+// StartTask starts a task assigned to the agent.
+func (c *Client) StartTask(ctx context.Context, agentID, taskID string) (Task, error) {
+	return c.agentTaskAction(ctx, agentID, taskID, "start", nil)
+}
+
+// FinishTask completes a task assigned to the agent, with an optional result.
+func (c *Client) FinishTask(ctx context.Context, agentID, taskID, result string) (Task, error) {
+	return c.FinishTaskWithResult(ctx, agentID, taskID, TaskResult{Result: result})
+}
+
+// FinishTaskWithResult completes a task assigned to the agent, with its
+// outcome, notes and result data.
+func (c *Client) FinishTaskWithResult(ctx context.Context, agentID, taskID string, result TaskResult) (Task, error) {
+	return c.agentTaskAction(ctx, agentID, taskID, "complete", result)
+}
+
+func (c *Client) agentTaskAction(ctx context.Context, agentID, taskID, action string, req interface{}) (Task, error) {
+	var resp struct {
+		Task Task `json:"task"`
+	}
+	path := "/v1/agent/" + url.PathEscape(agentID) + "/tasks/" + url.PathEscape(taskID) + "/" + action
+	err := c.do(ctx, http.MethodPost, path, req, &resp)
+	return resp.Task, err
+}
+
+// ListSkills returns the skills an agent can have.
+func (c *Client) ListSkills(ctx context.Context) ([]Skill, error) {
+	var resp struct {
+		Skills []Skill `json:"skills"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/skill", nil, &resp)
+	return resp.Skills, err
+}
+
+// CreateSkill adds a skill.
+func (c *Client) CreateSkill(ctx context.Context, skill Skill) (Skill, error) {
+	var resp struct {
+		Skill Skill `json:"skill"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/skill", skill, &resp)
+	return resp.Skill, err
+}
+
+// ListPriorities returns the priorities a task can have, lowest level first.
+func (c *Client) ListPriorities(ctx context.Context) ([]Priority, error) {
+	var resp struct {
+		Priorities []Priority `json:"priorities"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/priority", nil, &resp)
+	return resp.Priorities, err
+}
+
+// CreatePriority adds a priority.
+func (c *Client) CreatePriority(ctx context.Context, priority Priority) (Priority, error) {
+	var resp struct {
+		Priority Priority `json:"priority"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/priority", priority, &resp)
+	return resp.Priority, err
+}
+
+// CreateAPIKey issues an API key, the key is only returned by this call.
+func (c *Client) CreateAPIKey(ctx context.Context, key CreateAPIKeyRequest) (APIKey, error) {
+	var resp struct {
+		APIKey APIKey `json:"api_key"`
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/apikey", key, &resp)
+	return resp.APIKey, err
+}
+
+// ListAPIKeys returns the issued API keys, without the keys themselves.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var resp struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/apikey", nil, &resp)
+	return resp.APIKeys, err
+}
+
+// RevokeAPIKey revokes the API key with the id.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/apikey/"+url.PathEscape(id), nil, nil)
+}
+
+// do sends the request, retrying it when it can be, and decodes the response
+// into resp.
+func (c *Client) do(ctx context.Context, method, path string, req, resp interface{}) error {
+	var body []byte
+	if req != nil {
+		var err error
+		if body, err = json.Marshal(req); err != nil {
+			return err
+		}
+	}
+	return c.retry(ctx, idempotent(method), func() error {
+		return c.send(ctx, method, path, body, resp)
+	})
+}
+
+// idempotent reports if a request with the method is safe to repeat.  A POST
+// creates something or moves a task on, so repeating it would do that twice,
+// a call which knows its POST is safe opts in by calling retry itself.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retry calls send until it succeeds or fails with an error that can not be
+// retried, waiting longer after each attempt.
+func (c *Client) retry(ctx context.Context, idempotent bool, send func() error) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= c.retries || !retryable(err, idempotent) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, resp interface{}) error {
+	header := http.Header{}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	b, _, err := c.roundTrip(ctx, method, path, header, body)
+	if err != nil || resp == nil {
+		return err
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return fmt.Errorf("unable to decode response %s", err.Error())
+	}
+	return nil
+}
+
+// roundTrip sends the request with the headers and returns the body and the
+// headers of a successful response.
+func (c *Client) roundTrip(ctx context.Context, method, path string, header http.Header, body []byte) ([]byte, http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if request.Header.Get("Accept") == "" {
+		request.Header.Set("Accept", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, nil, &transportError{err: err}
+	}
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, &transportError{err: err}
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, nil, newError(response.StatusCode, b)
+	}
+	return b, response.Header, nil
+}
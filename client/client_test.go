@@ -0,0 +1,345 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"task-distributer/distributer"
+)
+
+// newTestClient returns an admin client of a distributer with the seed data,
+// the distributer and its URL.
+func newTestClient(t *testing.T) (*Client, *distributer.Server, string) {
+	store, err := distributer.OpenStore(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("distributer.OpenStore() error = %v", err)
+	}
+	blobs, err := distributer.OpenBlobStore(t.TempDir(), os.Getenv)
+	if err != nil {
+		t.Fatalf("distributer.OpenBlobStore() error = %v", err)
+	}
+	server, err := distributer.NewServer(store, distributer.Config{Blobs: blobs})
+	if err != nil {
+		t.Fatalf("distributer.NewServer() error = %v", err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	key, err := server.IssueAPIKey(context.Background(), distributer.DefaultTenant, "test", distributer.RoleAdmin, "")
+	if err != nil {
+		t.Fatalf("Server.IssueAPIKey() error = %v", err)
+	}
+	c, err := New(ts.URL, key)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c, server, ts.URL
+}
+
+func TestClient_tasks(t *testing.T) {
+	ctx := context.Background()
+	c, server, url := newTestClient(t)
+
+	created, err := c.CreateTask(ctx, CreateTaskRequest{
+		Name:     "Test Name",
+		Skills:   []string{"skill2"},
+		Priority: "low",
+		TaskDetails: TaskDetails{
+			Metadata: json.RawMessage(`{"order":"12"}`),
+			Tags:     []string{"billing"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Client.CreateTask() error = %v", err)
+	}
+	if created.Agent != "1001" || created.Status != StatusAssigned {
+		t.Errorf("Client.CreateTask() = %v, want assigned to 1001", created)
+	}
+
+	got, err := c.GetTask(ctx, created.ID)
+	if err != nil || got.ID != created.ID {
+		t.Errorf("Client.GetTask() = %v, %v, want %v", got, err, created.ID)
+	}
+
+	agentKey, err := server.IssueAPIKey(context.Background(), distributer.DefaultTenant, "agent", distributer.RoleAgent, "1001")
+	if err != nil {
+		t.Fatalf("Server.IssueAPIKey() error = %v", err)
+	}
+	agent, err := New(url, agentKey)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := agent.AcceptTask(ctx, "1001", created.ID); err != nil {
+		t.Errorf("Client.AcceptTask() error = %v", err)
+	}
+	if _, err := agent.AcceptTask(ctx, "1001", created.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Client.AcceptTask() twice error = %v, want %v", err, ErrConflict)
+	}
+	if _, err := agent.AgentTasks(ctx, "1000"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Client.AgentTasks() of another agent error = %v, want %v", err, ErrForbidden)
+	}
+	if _, err := agent.CreateAgentComment(ctx, "1001", created.ID, "Called the customer"); err != nil {
+		t.Errorf("Client.CreateAgentComment() error = %v", err)
+	}
+	if comment, err := c.CreateComment(ctx, created.ID, "Thanks"); err != nil || comment.Role != RoleAdmin {
+		t.Errorf("Client.CreateComment() = %v, %v, want an admin comment", comment, err)
+	}
+	if comments, err := agent.ListAgentComments(ctx, "1001", created.ID); err != nil || len(comments) != 2 || comments[0].Agent != "1001" {
+		t.Errorf("Client.ListAgentComments() = %v, %v, want both comments", comments, err)
+	}
+	uploaded, err := agent.UploadAgentAttachment(ctx, "1001", created.ID, "notes.txt", "text/plain", []byte("Called back"))
+	if err != nil || uploaded.Name != "notes.txt" || uploaded.Size != 11 {
+		t.Errorf("Client.UploadAgentAttachment() = %v, %v, want the attachment", uploaded, err)
+	}
+	if _, err := c.UploadAttachment(ctx, created.ID, "page.html", "text/html", []byte("<html></html>")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Client.UploadAttachment() of html error = %v, want %v", err, ErrInvalid)
+	}
+	if attachments, err := c.ListAttachments(ctx, created.ID); err != nil || len(attachments) != 1 || attachments[0].ID != uploaded.ID {
+		t.Errorf("Client.ListAttachments() = %v, %v, want the uploaded attachment", attachments, err)
+	}
+	if data, err := c.DownloadAttachment(ctx, created.ID, uploaded.ID); err != nil || string(data) != "Called back" {
+		t.Errorf("Client.DownloadAttachment() = %q, %v, want the contents", data, err)
+	}
+	finished, err := agent.FinishTaskWithResult(ctx, "1001", created.ID, TaskResult{Outcome: "resolved", Result: "done", ResultData: json.RawMessage(`{"calls":1}`)})
+	if err != nil || finished.Status != StatusComplete || finished.Outcome != "resolved" || finished.Result != "done" || string(finished.ResultData) != `{"calls":1}` {
+		t.Errorf("Client.FinishTaskWithResult() = %v, %v, want complete with the result", finished, err)
+	}
+	if got, err := c.GetTask(ctx, created.ID); err != nil || len(got.Comments) != 2 || got.Outcome != "resolved" {
+		t.Errorf("Client.GetTask() = %v, %v, want the result and comments", got, err)
+	}
+
+	cancelled, err := c.CreateTask(ctx, CreateTaskRequest{Name: "Cancelled", Skills: []string{"skill2"}, Priority: "low"})
+	if err != nil {
+		t.Fatalf("Client.CreateTask() error = %v", err)
+	}
+	if got, err := c.CancelTask(ctx, cancelled.ID); err != nil || got.Status != StatusCancelled {
+		t.Errorf("Client.CancelTask() = %v, %v, want cancelled", got, err)
+	}
+	if _, err := c.CancelTask(ctx, created.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Client.CancelTask() of a complete task error = %v, want %v", err, ErrConflict)
+	}
+	tasks, err := c.ListTasks(ctx, TaskFilter{Statuses: []string{StatusComplete}, Agent: "1001"})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != created.ID {
+		t.Errorf("Client.ListTasks() = %v, %v, want the complete task", tasks, err)
+	}
+	tasks, err = c.ListTasks(ctx, TaskFilter{Tags: []string{"billing"}, Metadata: map[string]string{"order": "12"}})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != created.ID || string(tasks[0].Metadata) != `{"order":"12"}` {
+		t.Errorf("Client.ListTasks() by tag and metadata = %v, %v, want the tagged task", tasks, err)
+	}
+	schedule, err := c.CreateSchedule(ctx, CreateScheduleRequest{
+		Task: CreateTaskRequest{Name: "Daily report", Skills: []string{"skill1"}, Priority: "low"},
+		Cron: "0 9 * * *",
+	})
+	if err != nil || schedule.NextRun == nil || schedule.NextRun.Hour() != 9 || schedule.Task.Name != "Daily report" {
+		t.Errorf("Client.CreateSchedule() = %+v, %v, want the next run at 9:00", schedule, err)
+	}
+	if paused, err := c.PauseSchedule(ctx, schedule.ID); err != nil || !paused.Paused {
+		t.Errorf("Client.PauseSchedule() = %+v, %v, want paused", paused, err)
+	}
+	if schedules, err := c.ListSchedules(ctx); err != nil || len(schedules) != 1 || !schedules[0].Paused {
+		t.Errorf("Client.ListSchedules() = %+v, %v, want the paused schedule", schedules, err)
+	}
+	if err := c.DeleteSchedule(ctx, schedule.ID); err != nil {
+		t.Errorf("Client.DeleteSchedule() error = %v", err)
+	}
+	if _, err := c.GetSchedule(ctx, schedule.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Client.GetSchedule() of a deleted schedule error = %v, want %v", err, ErrNotFound)
+	}
+	events, err := c.ListEvents(ctx, 0, 0)
+	if err != nil || len(events) != 5 {
+		t.Fatalf("Client.ListEvents() = %v, %v, want 5 events", events, err)
+	}
+	if after, err := c.ListEvents(ctx, events[3].ID, 0); err != nil || len(after) != 1 || after[0].Status != StatusCancelled {
+		t.Errorf("Client.ListEvents() after = %v, %v, want the cancelled event", after, err)
+	}
+
+	if _, err := c.GetTask(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Client.GetTask() error = %v, want %v", err, ErrNotFound)
+	}
+	_, err = c.CreateTask(ctx, CreateTaskRequest{Name: "Test Name", Skills: []string{"skill9"}, Priority: "low"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "validation_failed" || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "skills" {
+		t.Errorf("Client.CreateTask() error = %v, want a 400 Error of the skills", err)
+	}
+	_, err = c.CreateTask(ctx, CreateTaskRequest{Name: "Test Name", Skills: []string{"skill1", "skill2", "skill3"}, Priority: "low"})
+	if !errors.Is(err, ErrNoAgent) {
+		t.Errorf("Client.CreateTask() error = %v, want %v", err, ErrNoAgent)
+	}
+
+	if _, err := c.CreateTemplate(ctx, CreateTemplateRequest{Name: "callback", NamePattern: "Call back {metadata.customer}", Skills: []string{"skill3"}, Priority: "high", SLA: "4h"}); err != nil {
+		t.Errorf("Client.CreateTemplate() error = %v", err)
+	}
+	if _, err := c.CreateTemplate(ctx, CreateTemplateRequest{Name: "callback"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Client.CreateTemplate() twice error = %v, want %v", err, ErrConflict)
+	}
+	templated, err := c.CreateTaskFromTemplate(ctx, "callback", CreateTaskRequest{TaskDetails: TaskDetails{Metadata: json.RawMessage(`{"customer":"Jane"}`)}})
+	if err != nil || templated.Name != "Call back Jane" || templated.Priority != "high" || templated.SLA != "4h0m0s" {
+		t.Errorf("Client.CreateTaskFromTemplate() = %+v, %v, want the defaults of the template", templated, err)
+	}
+	if templates, err := c.ListTemplates(ctx); err != nil || len(templates) != 1 || templates[0].Author == "" {
+		t.Errorf("Client.ListTemplates() = %+v, %v, want the template", templates, err)
+	}
+	if err := c.DeleteTemplate(ctx, "callback"); err != nil {
+		t.Errorf("Client.DeleteTemplate() error = %v", err)
+	}
+	if _, err := c.GetTemplate(ctx, "callback"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Client.GetTemplate() of a deleted template error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestClient_admin(t *testing.T) {
+	ctx := context.Background()
+	c, _, url := newTestClient(t)
+
+	if _, err := c.CreateSkill(ctx, Skill{Skill: "skill4", Description: "A new skill"}); err != nil {
+		t.Errorf("Client.CreateSkill() error = %v", err)
+	}
+	if _, err := c.CreateAgent(ctx, Agent{ID: "2000", FirstName: "Test", LastName: "Agent", Skills: []string{"skill4"}}); err != nil {
+		t.Errorf("Client.CreateAgent() error = %v", err)
+	}
+	if a, err := c.UpdateAgentSkills(ctx, "2000", []string{"skill1", "skill4"}); err != nil || len(a.Skills) != 2 {
+		t.Errorf("Client.UpdateAgentSkills() = %v, %v", a, err)
+	}
+	agents, err := c.ListAgents(ctx)
+	if err != nil || len(agents) != 5 {
+		t.Errorf("Client.ListAgents() = %v, %v, want 5 agents", agents, err)
+	}
+	reports, err := c.AgentReports(ctx, ReportFilter{Agent: "1000", Window: 24 * time.Hour})
+	if err != nil || len(reports) != 7 || reports[0].Agent != "1000" || reports[0].Skills == nil {
+		t.Errorf("Client.AgentReports() = %+v, %v, want a report of each day of the week", reports, err)
+	}
+	if data, err := c.AgentReportsCSV(ctx, ReportFilter{}); err != nil || !strings.HasPrefix(string(data), "agent,first_name,") {
+		t.Errorf("Client.AgentReportsCSV() = %s, %v, want the CSV file", data, err)
+	}
+	if queues, err := c.QueueReports(ctx, ReportFilter{Window: 24 * time.Hour}); err != nil || queues == nil {
+		t.Errorf("Client.QueueReports() = %+v, %v, want the queues", queues, err)
+	}
+	if data, err := c.QueueReportsCSV(ctx, ReportFilter{}); err != nil || !strings.HasPrefix(string(data), "from,to,skill,priority,") {
+		t.Errorf("Client.QueueReportsCSV() = %s, %v, want the CSV file", data, err)
+	}
+	coverage, err := c.SkillCoverage(ctx, 1)
+	if err != nil || len(coverage) != 2 || coverage[0].Skill.Skill != "skill2" || coverage[1].Agents != 1 {
+		t.Errorf("Client.SkillCoverage() = %+v, %v, want the skills of a single agent", coverage, err)
+	}
+	if data, err := c.SkillCoverageCSV(ctx, -1); err != nil || strings.Count(string(data), "\n") != 5 {
+		t.Errorf("Client.SkillCoverageCSV() = %s, %v, want every skill", data, err)
+	}
+	priorities, err := c.ListPriorities(ctx)
+	if err != nil || len(priorities) != 2 {
+		t.Errorf("Client.ListPriorities() = %v, %v, want 2 priorities", priorities, err)
+	}
+	key, err := c.CreateAPIKey(ctx, CreateAPIKeyRequest{Name: "submitter", Role: RoleSubmitter})
+	if err != nil || key.Key == "" {
+		t.Fatalf("Client.CreateAPIKey() = %v, %v", key, err)
+	}
+	if err := c.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Errorf("Client.RevokeAPIKey() error = %v", err)
+	}
+	revoked, err := New(url, key.Key)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := revoked.ListSkills(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Client.ListSkills() with a revoked key error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestClient_retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		call         func(c *Client) error
+		wantAttempts int32
+		wantErr      error
+	}{
+		{
+			name:         "Unavailable",
+			status:       http.StatusServiceUnavailable,
+			wantAttempts: 3,
+			wantErr:      ErrUnavailable,
+		},
+		{
+			name:         "Not found",
+			status:       http.StatusNotFound,
+			wantAttempts: 1,
+			wantErr:      ErrNotFound,
+		},
+		{
+			name:   "Create task",
+			status: http.StatusServiceUnavailable,
+			call: func(c *Client) error {
+				_, err := c.CreateTask(context.Background(), CreateTaskRequest{})
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      ErrUnavailable,
+		},
+		{
+			name:   "Create comment",
+			status: http.StatusServiceUnavailable,
+			call: func(c *Client) error {
+				_, err := c.CreateComment(context.Background(), "1", "Done")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      ErrUnavailable,
+		},
+		{
+			name:   "Revoke API key",
+			status: http.StatusServiceUnavailable,
+			call: func(c *Client) error {
+				return c.RevokeAPIKey(context.Background(), "1")
+			},
+			wantAttempts: 3,
+			wantErr:      ErrUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				http.Error(writer, `{"success":false,"error_message":"Try again"}`, tt.status)
+			}))
+			defer ts.Close()
+			c, err := New(ts.URL, "td_test", WithRetries(2, time.Millisecond))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if tt.call != nil {
+				err = tt.call(c)
+			} else {
+				_, err = c.GetTask(context.Background(), "1")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestClient_canceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, `{"success":false,"error_message":"Try again"}`, http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	c, err := New(ts.URL, "td_test", WithRetries(5, time.Hour))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListSkills(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Client.ListSkills() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
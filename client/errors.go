@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// The kinds of errors the API responds with, test for them with errors.Is.
+var (
+	ErrInvalid        = errors.New("request is not valid")
+	ErrUnauthorized   = errors.New("API key is not valid")
+	ErrForbidden      = errors.New("API key is not allowed")
+	ErrNotFound       = errors.New("not present")
+	ErrConflict       = errors.New("conflicts with the current state")
+	ErrNoAgent        = errors.New("no agent is available")
+	ErrUnavailable    = errors.New("service is unavailable")
+	ErrInternalServer = errors.New("internal server error")
+)
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Code is the stable code of the error, like validation_failed or
+	// no_agent_available, empty for servers that do not send one.
+	Code    string
+	Message string
+	// Fields are the invalid fields of a validation_failed error.
+	Fields []FieldError
+}
+
+// FieldError is an invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newError(status int, body []byte) *Error {
+	e := &Error{
+		StatusCode: status,
+	}
+	var resp struct {
+		ErrorMessage string       `json:"error_message"`
+		Code         string       `json:"code"`
+		Details      []FieldError `json:"details"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.ErrorMessage != "" {
+		e.Message = resp.ErrorMessage
+		e.Code = resp.Code
+		e.Fields = resp.Details
+	} else {
+		e.Message = http.StatusText(status)
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("task distributer: %d %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the error is one of the kinds of errors, by its code or
+// its status.
+func (e *Error) Is(target error) bool {
+	if e.Code == "no_agent_available" && target == ErrNoAgent {
+		return true
+	}
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		return target == ErrInvalid
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusInsufficientStorage:
+		// Servers without error codes respond 507 when no agent is available.
+		return target == ErrNoAgent
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return target == ErrUnavailable
+	case http.StatusInternalServerError:
+		return target == ErrInternalServer
+	default:
+		return false
+	}
+}
+
+// transportError is a request that did not get a response.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether the request can be sent again after the error.
+// Requests that are not idempotent are only sent again when they could not
+// have reached the server.
+func retryable(err error, idempotent bool) bool {
+	var te *transportError
+	if errors.As(err, &te) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var op *net.OpError
+		return idempotent || (errors.As(err, &op) && op.Op == "dial")
+	}
+	return idempotent && errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The statuses a task moves through once it has been distributed to an agent,
+// until it is complete or cancelled.
+const (
+	StatusAssigned  = "Assigned"
+	StatusAccepted  = "Accepted"
+	StatusStarted   = "Started"
+	StatusComplete  = "Complete"
+	StatusCancelled = "Cancelled"
+)
+
+// The roles that can be given to an API key.
+const (
+	RoleAdmin     = "admin"
+	RoleSubmitter = "submitter"
+	RoleAgent     = "agent"
+)
+
+// CreateTaskRequest is a task to distribute.
+type CreateTaskRequest struct {
+	Name     string   `json:"name"`
+	Skills   []string `json:"skills"`
+	Priority string   `json:"priority"`
+	TaskDetails
+}
+
+// CreateScheduleRequest is a task to distribute at the start time, or each
+// time the cron expression matches after it.  The cron expression, like
+// 0 9 * * mon-fri, is in UTC.
+type CreateScheduleRequest struct {
+	Task    CreateTaskRequest `json:"task"`
+	Cron    string            `json:"cron,omitempty"`
+	StartAt *time.Time        `json:"start_at,omitempty"`
+}
+
+// Schedule holds a task until its start time, or distributes a new task each
+// time its cron expression matches.  A schedule without a cron expression
+// has no next run once its task is distributed.
+type Schedule struct {
+	ID      string            `json:"id"`
+	Task    CreateTaskRequest `json:"task"`
+	Cron    string            `json:"cron,omitempty"`
+	StartAt *time.Time        `json:"start_at,omitempty"`
+	Paused  bool              `json:"paused"`
+	NextRun *time.Time        `json:"next_run,omitempty"`
+	// LastTask is the task distributed the last time it was due, or
+	// LastError why none was.
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastTask   string     `json:"last_task,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	Author     string     `json:"author"`
+	CreateTime time.Time  `json:"create_time"`
+}
+
+// TaskDetails describe a task, the metadata is a JSON object.
+type TaskDetails struct {
+	Description string          `json:"description,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	ExternalURL string          `json:"external_url,omitempty"`
+	// SLA is how long the task should take to complete, like 4h.
+	SLA string `json:"sla,omitempty"`
+}
+
+// CreateTemplateRequest is a template of the defaults of the tasks created
+// with CreateTaskFromTemplate.  Every default is optional.
+type CreateTemplateRequest struct {
+	Name string `json:"name"`
+	// NamePattern is the name of the tasks, with {date} and {time} replaced
+	// by when they are created, in UTC, and {metadata.<key>} by the value of
+	// the key in their metadata.
+	NamePattern string          `json:"name_pattern,omitempty"`
+	Skills      []string        `json:"skills,omitempty"`
+	Priority    string          `json:"priority,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	SLA         string          `json:"sla,omitempty"`
+}
+
+// Template holds the defaults of the tasks created with
+// CreateTaskFromTemplate.
+type Template struct {
+	CreateTemplateRequest
+	Author     string    `json:"author"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// Task is a task distributed to an agent.
+type Task struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Skills       []string  `json:"skills"`
+	Priority     string    `json:"priority"`
+	Status       string    `json:"status"`
+	StartTime    time.Time `json:"start_time"`
+	CompleteTime time.Time `json:"complete_time,omitempty"`
+	Agent        string    `json:"assigned_agent"`
+	TaskResult
+	TaskDetails
+	// Comments and Attachments are only returned by GetTask.
+	Comments    []Comment    `json:"comments,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// TaskResult is what was done for a task, reported when it is completed.
+type TaskResult struct {
+	// Outcome is a code of how the task ended, like resolved.
+	Outcome string `json:"outcome,omitempty"`
+	// Result is the notes of what was done.
+	Result string `json:"result,omitempty"`
+	// ResultData is a JSON object.
+	ResultData json.RawMessage `json:"result_data,omitempty"`
+}
+
+// Comment is a note on a task, left by an agent or a submitter.
+type Comment struct {
+	ID         string    `json:"id"`
+	Task       string    `json:"task"`
+	Author     string    `json:"author"`
+	Role       string    `json:"role"`
+	Agent      string    `json:"agent,omitempty"`
+	Body       string    `json:"body"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// checksumHeader is the hex SHA-256 of an attachment's contents.
+const checksumHeader = "X-Checksum-SHA256"
+
+// Attachment is a file of a task, its contents are downloaded with
+// DownloadAttachment.
+type Attachment struct {
+	ID          string    `json:"id"`
+	Task        string    `json:"task"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Author      string    `json:"author"`
+	CreateTime  time.Time `json:"create_time"`
+}
+
+// TaskFilter selects the tasks to list, every task when it is empty.
+type TaskFilter struct {
+	Statuses []string
+	Agent    string
+	// Tags are tags every task has.
+	Tags []string
+	// Metadata are string values of the tasks' metadata keys.
+	Metadata map[string]string
+	// Limit is the most tasks to list, the API's default of 100 when it is 0.
+	Limit int
+}
+
+// Event is a change of a task's status.  Event ids increase, so the events
+// after one can be followed.
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	Task       string    `json:"task"`
+	Agent      string    `json:"agent,omitempty"`
+	Status     string    `json:"status"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// Agent is who tasks are distributed to.
+type Agent struct {
+	ID        string   `json:"id"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Skills    []string `json:"skills,omitempty"`
+}
+
+// AgentTasks is an agent and the tasks they are working on.
+type AgentTasks struct {
+	Agent
+	Tasks []Task `json:"tasks,omitempty"`
+}
+
+// ReportFilter is the period of a report, split into windows of the same
+// length.  The API's defaults are the week until now, in a single window.
+type ReportFilter struct {
+	From   time.Time
+	To     time.Time
+	Window time.Duration
+	// Agent selects the agent to report, every agent when it is empty.
+	Agent string
+}
+
+// AgentReport is the workload and performance of an agent in a window.  The
+// times to complete are in seconds.
+type AgentReport struct {
+	Agent             string         `json:"agent"`
+	FirstName         string         `json:"first_name"`
+	LastName          string         `json:"last_name"`
+	From              time.Time      `json:"from"`
+	To                time.Time      `json:"to"`
+	Assigned          int            `json:"assigned"`
+	Completed         int            `json:"completed"`
+	AvgTimeToComplete float64        `json:"avg_time_to_complete"`
+	P95TimeToComplete float64        `json:"p95_time_to_complete"`
+	Preemptions       int            `json:"preemptions"`
+	Utilization       float64        `json:"utilization"`
+	Skills            map[string]int `json:"skills"`
+}
+
+// QueueReport is the queue of the tasks with a skill and priority in a window.
+// A task with several skills is in the report of each of them.  The wait
+// times are in seconds and the throughput in tasks completed per hour.
+type QueueReport struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Skill      string    `json:"skill"`
+	Priority   string    `json:"priority"`
+	Created    int       `json:"created"`
+	Completed  int       `json:"completed"`
+	Cancelled  int       `json:"cancelled"`
+	Open       int       `json:"open"`
+	Queued     int       `json:"queued"`
+	AvgWait    float64   `json:"avg_wait"`
+	P95Wait    float64   `json:"p95_wait"`
+	Throughput float64   `json:"throughput"`
+}
+
+// SkillCoverage is how many agents have a skill, and how many of the open
+// tasks require it.
+type SkillCoverage struct {
+	Skill
+	Agents int `json:"agents"`
+	Open   int `json:"open"`
+	Queued int `json:"queued"`
+}
+
+// Skill is a skill an agent can have and a task can require.
+type Skill struct {
+	Skill       string `json:"skill"`
+	Description string `json:"description"`
+}
+
+// Priority is a priority of a task, a higher level is a higher priority.
+type Priority struct {
+	Priority string `json:"priority"`
+	Level    int    `json:"priority_level"`
+}
+
+// CreateAPIKeyRequest is an API key to issue, the agent is only for the agent
+// role.
+type CreateAPIKeyRequest struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	Agent string `json:"agent,omitempty"`
+}
+
+// APIKey is an issued API key.  The key is only present when it is issued.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Tenant     string    `json:"tenant"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	Agent      string    `json:"agent,omitempty"`
+	Key        string    `json:"key,omitempty"`
+	CreateTime time.Time `json:"create_time"`
+	RevokeTime time.Time `json:"revoke_time,omitempty"`
+}
@@ -0,0 +1,801 @@
+// Command taskctl manages the tasks, agents and skills of a task distributer
+// through its API, like
+//
+//	taskctl -url https://ancient-mountain-96195.herokuapp.com -key td_... task list -status Assigned
+//
+// The URL and key default to $TASKCTL_URL and $TASKCTL_KEY.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-distributer/client"
+)
+
+const usage = `usage: taskctl [-url <url>] [-key <key>] [-o table|json|csv] <command>
+
+commands:
+  task create -name <name> -skills <skill,...> -priority <priority>
+              [-description <text>] [-tags <tag,...>] [-url <url>] [-metadata <json>]
+              [-sla <duration>] [-template <name>]
+  task list [-status <status,...>] [-agent <id>] [-tag <tag,...>] [-limit <n>]
+  task show <id>
+  task complete <id> [-outcome <code>] [-result <notes>] [-data <json>]
+  task cancel <id>
+  task comments <id>
+  task comment <id> -body <text>
+  task attachments <id>
+  task attach <id> -file <path> [-type <content type>]
+  task download <id> <attachment id> [-file <path>]
+  agent list
+  agent create -id <id> -first <name> -last <name> [-skills <skill,...>]
+  agent skills <id> -skills <skill,...>
+  schedule create -name <name> -skills <skill,...> -priority <priority>
+                  [-cron <expression>] [-start <RFC 3339 time>]
+  schedule list
+  schedule show|pause|resume|delete <id>
+  template create -name <name> [-pattern <name pattern>] [-skills <skill,...>]
+                  [-priority <priority>] [-metadata <json>] [-sla <duration>]
+  template list
+  template show|delete <name>
+  skill list
+  skill create -skill <skill> -description <description>
+  report agents [-from <RFC 3339 time>] [-to <RFC 3339 time>] [-window <duration>]
+                [-agent <id>]
+  report queues [-from <RFC 3339 time>] [-to <RFC 3339 time>] [-window <duration>]
+  report skills [-max-agents <n>]
+  events list [-after <id>] [-limit <n>]
+  events tail [-after <id>] [-interval <duration>]`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// cli runs a command with the client and writes its results to the output.
+type cli struct {
+	client *client.Client
+	out    *output
+}
+
+// run parses the global flags and runs the command in the rest of the args.
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("taskctl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+	}
+	baseURL := flags.String("url", os.Getenv("TASKCTL_URL"), "URL of the task distributer")
+	key := flags.String("key", os.Getenv("TASKCTL_KEY"), "API key or bearer token")
+	format := flags.String("o", formatTable, "output format: table, json or csv")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return errors.New(usage)
+	}
+	out, err := newOutput(stdout, *format)
+	if err != nil {
+		return err
+	}
+	c, err := client.New(*baseURL, *key)
+	if err != nil {
+		return err
+	}
+	cmd := &cli{
+		client: c,
+		out:    out,
+	}
+	args = flags.Args()
+	switch args[0] {
+	case "task":
+		return cmd.task(ctx, args[1], args[2:])
+	case "agent":
+		return cmd.agent(ctx, args[1], args[2:])
+	case "skill":
+		return cmd.skill(ctx, args[1], args[2:])
+	case "schedule":
+		return cmd.schedule(ctx, args[1], args[2:])
+	case "template":
+		return cmd.template(ctx, args[1], args[2:])
+	case "report":
+		return cmd.report(ctx, args[1], args[2:])
+	case "events":
+		return cmd.events(ctx, args[1], args[2:])
+	default:
+		return fmt.Errorf("command %s is not supported\n%s", args[0], usage)
+	}
+}
+
+func (c *cli) task(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("task "+sub, flag.ContinueOnError)
+	switch sub {
+	case "create":
+		name := flags.String("name", "", "name of the task")
+		skills := flags.String("skills", "", "comma separated skills the task requires")
+		priority := flags.String("priority", "", "priority of the task, low without a template")
+		description := flags.String("description", "", "description of the task")
+		tags := flags.String("tags", "", "comma separated tags of the task")
+		externalURL := flags.String("url", "", "URL of the task in another system")
+		metadata := flags.String("metadata", "", "JSON object of metadata of the task")
+		sla := flags.String("sla", "", "how long the task should take to complete, like 4h")
+		template := flags.String("template", "", "template whose defaults fill in the other flags")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		raw, err := jsonFlag("metadata", *metadata)
+		if err != nil {
+			return err
+		}
+		if *priority == "" && *template == "" {
+			*priority = "low"
+		}
+		req := client.CreateTaskRequest{
+			Name:     *name,
+			Skills:   splitList(*skills),
+			Priority: *priority,
+			TaskDetails: client.TaskDetails{
+				Description: *description,
+				Metadata:    raw,
+				Tags:        splitList(*tags),
+				ExternalURL: *externalURL,
+				SLA:         *sla,
+			},
+		}
+		var t client.Task
+		if *template != "" {
+			t, err = c.client.CreateTaskFromTemplate(ctx, *template, req)
+		} else {
+			t, err = c.client.CreateTask(ctx, req)
+		}
+		if err != nil {
+			return err
+		}
+		return c.writeTasks(t, []client.Task{t})
+	case "list":
+		status := flags.String("status", "", "comma separated statuses of the tasks")
+		agentID := flags.String("agent", "", "agent id of the tasks")
+		tags := flags.String("tag", "", "comma separated tags every task has")
+		limit := flags.Int("limit", 0, "most tasks to list, 100 by default")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		tasks, err := c.client.ListTasks(ctx, client.TaskFilter{
+			Statuses: splitList(*status),
+			Agent:    *agentID,
+			Tags:     splitList(*tags),
+			Limit:    *limit,
+		})
+		if err != nil {
+			return err
+		}
+		return c.writeTasks(tasks, tasks)
+	case "complete":
+		outcome := flags.String("outcome", "", "code of how the task ended, like resolved")
+		result := flags.String("result", "", "notes of what was done")
+		data := flags.String("data", "", "JSON object of the result")
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New("usage: taskctl task complete <id> [-outcome <code>] [-result <notes>] [-data <json>]")
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		raw, err := jsonFlag("data", *data)
+		if err != nil {
+			return err
+		}
+		err = c.client.CompleteTaskWithResult(ctx, args[0], client.TaskResult{Outcome: *outcome, Result: *result, ResultData: raw})
+		if err != nil {
+			return err
+		}
+		t, err := c.client.GetTask(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return c.writeTasks(t, []client.Task{t})
+	case "comment":
+		body := flags.String("body", "", "text of the comment")
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New("usage: taskctl task comment <id> -body <text>")
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		cm, err := c.client.CreateComment(ctx, args[0], *body)
+		if err != nil {
+			return err
+		}
+		return c.out.write(cm, commentHeader, [][]string{commentRow(cm)})
+	case "comments":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New("usage: taskctl task comments <id>")
+		}
+		comments, err := c.client.ListComments(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(comments))
+		for _, cm := range comments {
+			rows = append(rows, commentRow(cm))
+		}
+		return c.out.write(comments, commentHeader, rows)
+	case "attach":
+		file := flags.String("file", "", "path of the file to attach")
+		contentType := flags.String("type", "", "content type of the file, guessed from its name or contents by default")
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New("usage: taskctl task attach <id> -file <path> [-type <content type>]")
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *file == "" {
+			return errors.New("usage: taskctl task attach <id> -file <path> [-type <content type>]")
+		}
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		if *contentType == "" {
+			if *contentType = mime.TypeByExtension(filepath.Ext(*file)); *contentType == "" {
+				*contentType = http.DetectContentType(data)
+			}
+		}
+		a, err := c.client.UploadAttachment(ctx, args[0], filepath.Base(*file), *contentType, data)
+		if err != nil {
+			return err
+		}
+		return c.out.write(a, attachmentHeader, [][]string{attachmentRow(a)})
+	case "attachments":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New("usage: taskctl task attachments <id>")
+		}
+		attachments, err := c.client.ListAttachments(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(attachments))
+		for _, a := range attachments {
+			rows = append(rows, attachmentRow(a))
+		}
+		return c.out.write(attachments, attachmentHeader, rows)
+	case "download":
+		file := flags.String("file", "", "path to save the attachment, the output by default")
+		if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+			return errors.New("usage: taskctl task download <id> <attachment id> [-file <path>]")
+		}
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		data, err := c.client.DownloadAttachment(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if *file != "" {
+			return os.WriteFile(*file, data, 0o644)
+		}
+		_, err = c.out.w.Write(data)
+		return err
+	case "show", "cancel":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: taskctl task %s <id>", sub)
+		}
+		id := flags.Arg(0)
+		var t client.Task
+		var err error
+		switch sub {
+		case "show":
+			t, err = c.client.GetTask(ctx, id)
+		case "cancel":
+			t, err = c.client.CancelTask(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		return c.writeTasks(t, []client.Task{t})
+	default:
+		return fmt.Errorf("task command %s is not supported", sub)
+	}
+}
+
+var taskHeader = []string{"id", "name", "skills", "priority", "status", "agent", "started", "completed", "outcome", "result", "tags"}
+
+// writeTasks writes the value, which is the tasks or the only task, as JSON
+// or the tasks as rows.
+func (c *cli) writeTasks(v interface{}, tasks []client.Task) error {
+	rows := make([][]string, 0, len(tasks))
+	for _, t := range tasks {
+		rows = append(rows, []string{t.ID, t.Name, strings.Join(t.Skills, ","), t.Priority, t.Status, t.Agent, formatTime(t.StartTime), formatTime(t.CompleteTime), t.Outcome, t.Result, strings.Join(t.Tags, ",")})
+	}
+	return c.out.write(v, taskHeader, rows)
+}
+
+var commentHeader = []string{"id", "author", "role", "agent", "created", "body"}
+
+func commentRow(cm client.Comment) []string {
+	return []string{cm.ID, cm.Author, cm.Role, cm.Agent, formatTime(cm.CreateTime), cm.Body}
+}
+
+var attachmentHeader = []string{"id", "name", "type", "size", "sha256", "author", "created"}
+
+func attachmentRow(a client.Attachment) []string {
+	return []string{a.ID, a.Name, a.ContentType, strconv.FormatInt(a.Size, 10), a.SHA256, a.Author, formatTime(a.CreateTime)}
+}
+
+func (c *cli) agent(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("agent "+sub, flag.ContinueOnError)
+	switch sub {
+	case "list":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		agents, err := c.client.ListAgents(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(agents))
+		for _, a := range agents {
+			var tasks []string
+			for _, t := range a.Tasks {
+				tasks = append(tasks, t.ID)
+			}
+			rows = append(rows, []string{a.ID, a.FirstName, a.LastName, strings.Join(a.Skills, ","), strings.Join(tasks, ",")})
+		}
+		return c.out.write(agents, []string{"id", "first_name", "last_name", "skills", "tasks"}, rows)
+	case "create":
+		id := flags.String("id", "", "id of the agent")
+		first := flags.String("first", "", "first name of the agent")
+		last := flags.String("last", "", "last name of the agent")
+		skills := flags.String("skills", "", "comma separated skills of the agent")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		a, err := c.client.CreateAgent(ctx, client.Agent{
+			ID:        *id,
+			FirstName: *first,
+			LastName:  *last,
+			Skills:    splitList(*skills),
+		})
+		if err != nil {
+			return err
+		}
+		return c.writeAgent(a)
+	case "skills":
+		skills := flags.String("skills", "", "comma separated skills that replace the agent's skills")
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New("usage: taskctl agent skills <id> -skills <skill,...>")
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		a, err := c.client.UpdateAgentSkills(ctx, args[0], splitList(*skills))
+		if err != nil {
+			return err
+		}
+		return c.writeAgent(a)
+	default:
+		return fmt.Errorf("agent command %s is not supported", sub)
+	}
+}
+
+func (c *cli) writeAgent(a client.Agent) error {
+	return c.out.write(a, []string{"id", "first_name", "last_name", "skills"}, [][]string{{a.ID, a.FirstName, a.LastName, strings.Join(a.Skills, ",")}})
+}
+
+var templateHeader = []string{"name", "name pattern", "skills", "priority", "metadata", "sla", "author", "created"}
+
+func templateRow(tp client.Template) []string {
+	return []string{tp.Name, tp.NamePattern, strings.Join(tp.Skills, ","), tp.Priority, string(tp.Metadata), tp.SLA, tp.Author, formatTime(tp.CreateTime)}
+}
+
+func (c *cli) template(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("template "+sub, flag.ContinueOnError)
+	switch sub {
+	case "create":
+		name := flags.String("name", "", "name of the template")
+		pattern := flags.String("pattern", "", "name of the tasks, with {date}, {time} and {metadata.<key>} placeholders")
+		skills := flags.String("skills", "", "comma separated skills the tasks require")
+		priority := flags.String("priority", "", "priority of the tasks")
+		metadata := flags.String("metadata", "", "JSON object of metadata of the tasks")
+		sla := flags.String("sla", "", "how long the tasks should take to complete, like 4h")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		raw, err := jsonFlag("metadata", *metadata)
+		if err != nil {
+			return err
+		}
+		tp, err := c.client.CreateTemplate(ctx, client.CreateTemplateRequest{
+			Name:        *name,
+			NamePattern: *pattern,
+			Skills:      splitList(*skills),
+			Priority:    *priority,
+			Metadata:    raw,
+			SLA:         *sla,
+		})
+		if err != nil {
+			return err
+		}
+		return c.out.write(tp, templateHeader, [][]string{templateRow(tp)})
+	case "list":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		templates, err := c.client.ListTemplates(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(templates))
+		for _, tp := range templates {
+			rows = append(rows, templateRow(tp))
+		}
+		return c.out.write(templates, templateHeader, rows)
+	case "show", "delete":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: taskctl template %s <name>", sub)
+		}
+		if sub == "delete" {
+			return c.client.DeleteTemplate(ctx, flags.Arg(0))
+		}
+		tp, err := c.client.GetTemplate(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return c.out.write(tp, templateHeader, [][]string{templateRow(tp)})
+	default:
+		return fmt.Errorf("template command %s is not supported", sub)
+	}
+}
+
+func (c *cli) skill(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("skill "+sub, flag.ContinueOnError)
+	header := []string{"skill", "description"}
+	switch sub {
+	case "list":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		skills, err := c.client.ListSkills(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(skills))
+		for _, s := range skills {
+			rows = append(rows, []string{s.Skill, s.Description})
+		}
+		return c.out.write(skills, header, rows)
+	case "create":
+		name := flags.String("skill", "", "name of the skill")
+		description := flags.String("description", "", "description of the skill")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		s, err := c.client.CreateSkill(ctx, client.Skill{Skill: *name, Description: *description})
+		if err != nil {
+			return err
+		}
+		return c.out.write(s, header, [][]string{{s.Skill, s.Description}})
+	default:
+		return fmt.Errorf("skill command %s is not supported", sub)
+	}
+}
+
+var scheduleHeader = []string{"id", "name", "skills", "priority", "cron", "start", "paused", "next run", "last run", "last task", "last error"}
+
+func scheduleRow(sc client.Schedule) []string {
+	return []string{sc.ID, sc.Task.Name, strings.Join(sc.Task.Skills, ","), sc.Task.Priority, sc.Cron, formatTimePointer(sc.StartAt), strconv.FormatBool(sc.Paused), formatTimePointer(sc.NextRun), formatTimePointer(sc.LastRun), sc.LastTask, sc.LastError}
+}
+
+func (c *cli) schedule(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("schedule "+sub, flag.ContinueOnError)
+	switch sub {
+	case "create":
+		name := flags.String("name", "", "name of the tasks")
+		skills := flags.String("skills", "", "comma separated skills the tasks require")
+		priority := flags.String("priority", "low", "priority of the tasks")
+		cron := flags.String("cron", "", "cron expression in UTC, like \"0 9 * * mon-fri\"")
+		start := flags.String("start", "", "RFC 3339 time to hold the task until, or start the cron expression at")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		req := client.CreateScheduleRequest{
+			Task: client.CreateTaskRequest{
+				Name:     *name,
+				Skills:   splitList(*skills),
+				Priority: *priority,
+			},
+			Cron: *cron,
+		}
+		if *start != "" {
+			startAt, err := time.Parse(time.RFC3339, *start)
+			if err != nil {
+				return fmt.Errorf("start must be an RFC 3339 time, like 2024-01-31T09:00:00Z")
+			}
+			req.StartAt = &startAt
+		}
+		sc, err := c.client.CreateSchedule(ctx, req)
+		if err != nil {
+			return err
+		}
+		return c.out.write(sc, scheduleHeader, [][]string{scheduleRow(sc)})
+	case "list":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		schedules, err := c.client.ListSchedules(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(schedules))
+		for _, sc := range schedules {
+			rows = append(rows, scheduleRow(sc))
+		}
+		return c.out.write(schedules, scheduleHeader, rows)
+	case "show", "pause", "resume", "delete":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: taskctl schedule %s <id>", sub)
+		}
+		id := flags.Arg(0)
+		var sc client.Schedule
+		var err error
+		switch sub {
+		case "show":
+			sc, err = c.client.GetSchedule(ctx, id)
+		case "pause":
+			sc, err = c.client.PauseSchedule(ctx, id)
+		case "resume":
+			sc, err = c.client.ResumeSchedule(ctx, id)
+		case "delete":
+			return c.client.DeleteSchedule(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		return c.out.write(sc, scheduleHeader, [][]string{scheduleRow(sc)})
+	default:
+		return fmt.Errorf("schedule command %s is not supported", sub)
+	}
+}
+
+var eventHeader = []string{"id", "type", "task", "agent", "status", "created"}
+
+func eventRow(e client.Event) []string {
+	return []string{strconv.FormatInt(e.ID, 10), e.Type, e.Task, e.Agent, e.Status, formatTime(e.CreateTime)}
+}
+
+func (c *cli) events(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("events "+sub, flag.ContinueOnError)
+	after := flags.Int64("after", 0, "id of the event to list the events after")
+	switch sub {
+	case "list":
+		limit := flags.Int("limit", 0, "most events to list, 100 by default")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		events, err := c.client.ListEvents(ctx, *after, *limit)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(events))
+		for _, e := range events {
+			rows = append(rows, eventRow(e))
+		}
+		return c.out.write(events, eventHeader, rows)
+	case "tail":
+		interval := flags.Duration("interval", 2*time.Second, "how often to poll for new events")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		return c.tail(ctx, *after, *interval)
+	default:
+		return fmt.Errorf("events command %s is not supported", sub)
+	}
+}
+
+// tail polls for the events after the event id and writes them as they
+// arrive, until the context is done.
+func (c *cli) tail(ctx context.Context, after int64, interval time.Duration) error {
+	first := true
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := c.client.ListEvents(ctx, after, 0)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 || first {
+			values := make([]interface{}, 0, len(events))
+			rows := make([][]string, 0, len(events))
+			for _, e := range events {
+				values = append(values, e)
+				rows = append(rows, eventRow(e))
+				after = e.ID
+			}
+			if err := c.out.writeRows(values, eventHeader, rows, first); err != nil {
+				return err
+			}
+			first = false
+		}
+		// A full page may have more events waiting.
+		if len(events) == 100 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+var agentReportHeader = []string{"agent", "first name", "last name", "from", "to", "assigned", "completed", "avg time", "p95 time", "preemptions", "utilization", "skills"}
+
+func agentReportRow(r client.AgentReport) []string {
+	skills := make([]string, 0, len(r.Skills))
+	for skill, count := range r.Skills {
+		skills = append(skills, skill+"="+strconv.Itoa(count))
+	}
+	sort.Strings(skills)
+	return []string{
+		r.Agent, r.FirstName, r.LastName, formatTime(r.From), formatTime(r.To),
+		strconv.Itoa(r.Assigned), strconv.Itoa(r.Completed), formatSeconds(r.AvgTimeToComplete), formatSeconds(r.P95TimeToComplete),
+		strconv.Itoa(r.Preemptions), strconv.FormatFloat(r.Utilization*100, 'f', 1, 64) + "%", strings.Join(skills, ","),
+	}
+}
+
+var queueReportHeader = []string{"from", "to", "skill", "priority", "created", "completed", "cancelled", "open", "queued", "avg wait", "p95 wait", "per hour"}
+
+func queueReportRow(r client.QueueReport) []string {
+	return []string{
+		formatTime(r.From), formatTime(r.To), r.Skill, r.Priority,
+		strconv.Itoa(r.Created), strconv.Itoa(r.Completed), strconv.Itoa(r.Cancelled), strconv.Itoa(r.Open), strconv.Itoa(r.Queued),
+		formatSeconds(r.AvgWait), formatSeconds(r.P95Wait), strconv.FormatFloat(r.Throughput, 'f', 2, 64),
+	}
+}
+
+var skillCoverageHeader = []string{"skill", "description", "agents", "open", "queued"}
+
+func skillCoverageRow(sc client.SkillCoverage) []string {
+	return []string{sc.Skill.Skill, sc.Description, strconv.Itoa(sc.Agents), strconv.Itoa(sc.Open), strconv.Itoa(sc.Queued)}
+}
+
+func (c *cli) report(ctx context.Context, sub string, args []string) error {
+	flags := flag.NewFlagSet("report "+sub, flag.ContinueOnError)
+	from := flags.String("from", "", "RFC 3339 time to start the report at, a week before its end by default")
+	to := flags.String("to", "", "RFC 3339 time to end the report at, now by default")
+	window := flags.Duration("window", 0, "length of each window of the report, the whole report by default")
+	// filter returns the period of the report once the flags are parsed.
+	filter := func() (client.ReportFilter, error) {
+		f := client.ReportFilter{Window: *window}
+		var err error
+		if f.From, err = timeFlag("from", *from); err != nil {
+			return f, err
+		}
+		f.To, err = timeFlag("to", *to)
+		return f, err
+	}
+	switch sub {
+	case "agents":
+		agent := flags.String("agent", "", "id of the agent to report, every agent by default")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		f, err := filter()
+		if err != nil {
+			return err
+		}
+		f.Agent = *agent
+		reports, err := c.client.AgentReports(ctx, f)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(reports))
+		for _, r := range reports {
+			rows = append(rows, agentReportRow(r))
+		}
+		return c.out.write(reports, agentReportHeader, rows)
+	case "queues":
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		f, err := filter()
+		if err != nil {
+			return err
+		}
+		reports, err := c.client.QueueReports(ctx, f)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(reports))
+		for _, r := range reports {
+			rows = append(rows, queueReportRow(r))
+		}
+		return c.out.write(reports, queueReportHeader, rows)
+	case "skills":
+		maxAgents := flags.Int("max-agents", -1, "most agents a skill can have to be listed, every skill by default")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		coverage, err := c.client.SkillCoverage(ctx, *maxAgents)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(coverage))
+		for _, sc := range coverage {
+			rows = append(rows, skillCoverageRow(sc))
+		}
+		return c.out.write(coverage, skillCoverageHeader, rows)
+	default:
+		return fmt.Errorf("report command %s is not supported", sub)
+	}
+}
+
+// timeFlag returns the value of the flag as a time, which is zero when it is
+// empty.
+func timeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time, like 2024-01-31T09:00:00Z", name)
+	}
+	return t, nil
+}
+
+// jsonFlag returns the value of the flag as JSON, which is nil when it is
+// empty.
+func jsonFlag(name, value string) (json.RawMessage, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("%s must be valid JSON", name)
+	}
+	return json.RawMessage(value), nil
+}
+
+// splitList splits the comma separated list, an empty list is nil.
+func splitList(list string) []string {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task-distributer/distributer"
+)
+
+// newTestURL returns the URL and an admin key of a distributer with the seed
+// data.
+func newTestURL(t *testing.T) (string, string) {
+	store, err := distributer.OpenStore(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("distributer.OpenStore() error = %v", err)
+	}
+	blobs, err := distributer.OpenBlobStore(t.TempDir(), os.Getenv)
+	if err != nil {
+		t.Fatalf("distributer.OpenBlobStore() error = %v", err)
+	}
+	server, err := distributer.NewServer(store, distributer.Config{Blobs: blobs})
+	if err != nil {
+		t.Fatalf("distributer.NewServer() error = %v", err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	key, err := server.IssueAPIKey(context.Background(), distributer.DefaultTenant, "test", distributer.RoleAdmin, "")
+	if err != nil {
+		t.Fatalf("Server.IssueAPIKey() error = %v", err)
+	}
+	return ts.URL, key
+}
+
+func Test_run(t *testing.T) {
+	url, key := newTestURL(t)
+	var created bytes.Buffer
+	err := run(context.Background(), []string{"-url", url, "-key", key, "-o", "json", "task", "create", "-name", "Test Name", "-skills", "skill1", "-priority", "low", "-tags", "billing", "-metadata", `{"order":"12"}`}, &created)
+	if err != nil {
+		t.Fatalf("run() task create error = %v", err)
+	}
+	var task struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(created.Bytes(), &task); err != nil || task.ID == "" {
+		t.Fatalf("run() task create = %s, %v", created.String(), err)
+	}
+	var open struct {
+		ID string `json:"id"`
+	}
+	created.Reset()
+	if err := run(context.Background(), []string{"-url", url, "-key", key, "-o", "json", "task", "create", "-name", "Test Name", "-skills", "skill1", "-priority", "low"}, &created); err != nil {
+		t.Fatalf("run() task create error = %v", err)
+	}
+	if err := json.Unmarshal(created.Bytes(), &open); err != nil || open.ID == "" {
+		t.Fatalf("run() task create = %s, %v", created.String(), err)
+	}
+
+	notes := filepath.Join(t.TempDir(), "notes.csv")
+	if err := os.WriteFile(notes, []byte("order,12\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     []string
+		wantErr  bool
+		wantRows int
+	}{
+		{
+			name: "Show task table",
+			args: []string{"task", "show", task.ID},
+			want: []string{"ID", "STATUS", task.ID, "Assigned"},
+		},
+		{
+			name: "List tasks CSV",
+			args: []string{"-o", "csv", "task", "list", "-status", "Assigned,Accepted"},
+			want: []string{"id,name,skills,priority,status", task.ID + ",Test Name,skill1,low,Assigned"},
+		},
+		{
+			name: "List tasks by tag",
+			args: []string{"-o", "csv", "task", "list", "-tag", "billing"},
+			want: []string{task.ID + ",Test Name,skill1,low,Assigned", ",billing"},
+		},
+		{
+			name: "Comment task",
+			args: []string{"task", "comment", task.ID, "-body", "Please hurry"},
+			want: []string{"admin", "Please hurry"},
+		},
+		{
+			name: "List comments CSV",
+			args: []string{"-o", "csv", "task", "comments", task.ID},
+			want: []string{"id,author,role,agent,created,body", ",Please hurry"},
+		},
+		{
+			name: "Attach file",
+			args: []string{"-o", "csv", "task", "attach", task.ID, "-file", notes},
+			want: []string{"id,name,type,size,sha256", ",notes.csv,text/csv,9,"},
+		},
+		{
+			name: "List attachments",
+			args: []string{"task", "attachments", task.ID},
+			want: []string{"NAME", "notes.csv"},
+		},
+		{
+			name:    "Attach missing file",
+			args:    []string{"task", "attach", task.ID, "-file", notes + ".missing"},
+			wantErr: true,
+		},
+		{
+			name: "Cancel task",
+			args: []string{"task", "cancel", task.ID},
+			want: []string{"Cancelled"},
+		},
+		{
+			name:    "Cancel cancelled task",
+			args:    []string{"task", "cancel", task.ID},
+			wantErr: true,
+		},
+		{
+			name: "Create template",
+			args: []string{"-o", "csv", "template", "create", "-name", "callback", "-pattern", "Call back {metadata.customer}", "-skills", "skill2", "-priority", "high", "-sla", "4h"},
+			want: []string{"name,name pattern,skills,priority,metadata,sla", "callback,Call back {metadata.customer},skill2,high,,4h0m0s,"},
+		},
+		{
+			name: "Create task from template",
+			args: []string{"-o", "csv", "task", "create", "-template", "callback", "-metadata", `{"customer":"Jane"}`},
+			want: []string{",Call back Jane,skill2,high,Assigned"},
+		},
+		{
+			name:    "Create task from unknown template",
+			args:    []string{"task", "create", "-template", "unknown", "-name", "Test Name", "-skills", "skill1"},
+			wantErr: true,
+		},
+		{
+			name: "List templates",
+			args: []string{"template", "list"},
+			want: []string{"NAME PATTERN", "callback"},
+		},
+		{
+			name: "Create schedule",
+			args: []string{"-o", "csv", "schedule", "create", "-name", "Daily report", "-skills", "skill1", "-cron", "0 9 * * *"},
+			want: []string{"id,name,skills,priority,cron", ",Daily report,skill1,low,0 9 * * *,,false,"},
+		},
+		{
+			name: "List schedules",
+			args: []string{"schedule", "list"},
+			want: []string{"NEXT RUN", "Daily report"},
+		},
+		{
+			name:    "Create schedule with an invalid start",
+			args:    []string{"schedule", "create", "-name", "Report", "-skills", "skill1", "-start", "tomorrow"},
+			wantErr: true,
+		},
+		{
+			name: "Create skill",
+			args: []string{"skill", "create", "-skill", "skill4", "-description", "A new skill"},
+			want: []string{"skill4", "A new skill"},
+		},
+		{
+			name: "Update agent skills",
+			args: []string{"agent", "skills", "1000", "-skills", "skill1,skill4"},
+			want: []string{"1000", "skill1,skill4"},
+		},
+		{
+			name: "List events JSON",
+			args: []string{"-o", "json", "events", "list"},
+			want: []string{`"type": "task.assigned"`, `"type": "task.cancelled"`},
+		},
+		{
+			name:    "Complete cancelled task",
+			args:    []string{"task", "complete", task.ID},
+			wantErr: true,
+		},
+		{
+			name: "Complete with result",
+			args: []string{"-o", "csv", "task", "complete", open.ID, "-outcome", "resolved", "-result", "done"},
+			want: []string{",Complete,", ",resolved,done,"},
+		},
+		{
+			name: "Report agents",
+			args: []string{"-o", "csv", "report", "agents", "-agent", "1001", "-window", "24h"},
+			want: []string{"agent,first name,last name,from,to,assigned,completed", "1001,Ovaltine,Jenkins,"},
+		},
+		{
+			name: "Report queues",
+			args: []string{"-o", "csv", "report", "queues", "-window", "24h"},
+			want: []string{"from,to,skill,priority,created,completed,cancelled,open,queued", ",skill2,high,"},
+		},
+		{
+			name: "Report skills",
+			args: []string{"-o", "csv", "report", "skills", "-max-agents", "1"},
+			want: []string{"skill,description,agents,open,queued", "skill2,This is a awesome skill to have,1,"},
+		},
+		{
+			name:    "Report agents from an invalid time",
+			args:    []string{"report", "agents", "-from", "yesterday"},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported output",
+			args:    []string{"-o", "xml", "skill", "list"},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported command",
+			args:    []string{"tenant", "list"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(context.Background(), append([]string{"-url", url, "-key", key}, tt.args...), &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("run() = %s, want %s", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func Test_run_tail(t *testing.T) {
+	url, key := newTestURL(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		run(context.Background(), []string{"-url", url, "-key", key, "task", "create", "-name", "Test Name", "-skills", "skill1"}, &bytes.Buffer{})
+	}()
+	var out bytes.Buffer
+	if err := run(ctx, []string{"-url", url, "-key", key, "-o", "csv", "events", "tail", "-interval", "10ms"}, &out); err != nil {
+		t.Fatalf("run() events tail error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,type") || !strings.Contains(lines[1], "task.assigned") {
+		t.Errorf("run() events tail = %s, want the header and the assigned event", out.String())
+	}
+}
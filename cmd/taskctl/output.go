@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// output writes the results of the commands in a format.
+type output struct {
+	w      io.Writer
+	format string
+}
+
+func newOutput(w io.Writer, format string) (*output, error) {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return &output{w: w, format: format}, nil
+	default:
+		return nil, fmt.Errorf("output %s is not supported, use table, json or csv", format)
+	}
+}
+
+// write writes the value as JSON, or its rows under the header as a table or
+// CSV.
+func (o *output) write(v interface{}, header []string, rows [][]string) error {
+	switch o.format {
+	case formatJSON:
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case formatCSV:
+		w := csv.NewWriter(o.w)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+}
+
+// writeRows writes more rows of the same results, without the header unless
+// it is the first, so events that are tailed are written as they arrive.  As
+// JSON each value is written on its own line.
+func (o *output) writeRows(values []interface{}, header []string, rows [][]string, first bool) error {
+	switch o.format {
+	case formatJSON:
+		encoder := json.NewEncoder(o.w)
+		for _, v := range values {
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV:
+		w := csv.NewWriter(o.w)
+		if first {
+			w.Write(header)
+		}
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		// A tabwriter can not align rows it has not seen, so the columns
+		// are separated by tabs.
+		if first {
+			fmt.Fprintln(o.w, strings.ToUpper(strings.Join(header, "\t")))
+		}
+		for _, row := range rows {
+			fmt.Fprintln(o.w, strings.Join(row, "\t"))
+		}
+		return nil
+	}
+}
+
+// formatTimePointer formats an optional time, which is empty when it is nil.
+func formatTimePointer(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// formatTime formats the time for a table or CSV, the zero time is empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatSeconds formats a number of seconds as a duration, to the second.
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
@@ -0,0 +1,15 @@
+package distributer
+
+// agent is the payload for the database and HTTP response
+type agent struct {
+	ID        string   `json:"id"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Skills    []string `json:"skills,omitempty"`
+}
+
+// agentTasks is the list of tasks for an agent.
+type agentTasks struct {
+	agent
+	Tasks []task `json:"tasks,omitempty"`
+}
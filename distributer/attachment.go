@@ -0,0 +1,88 @@
+package distributer
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// defaultMaxAttachmentSize is the largest attachment, in bytes, when the
+// Config does not set one.
+const defaultMaxAttachmentSize = 10 << 20
+
+// defaultAttachmentTypes are the media types of the attachments when the
+// Config does not set them.
+var defaultAttachmentTypes = []string{
+	"application/pdf",
+	"application/json",
+	"application/zip",
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"text/csv",
+	"text/plain",
+}
+
+// attachment is a file of a task, for the database and HTTP response.  Its
+// contents are kept in the blob store, under the key of the tenant, task and
+// attachment ids.
+type attachment struct {
+	ID          string `json:"id"`
+	Task        string `json:"task"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// SHA256 is the hex checksum of the contents, verified when they are
+	// downloaded.
+	SHA256 string `json:"sha256"`
+	// Author is the subject of the API key or token that uploaded it.
+	Author     string    `json:"author"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// checksumHeader is the hex SHA-256 of an attachment's contents, verified on
+// upload when the client sends it and sent with the download.
+const checksumHeader = "X-Checksum-SHA256"
+
+// blobKey is the key of the attachment's contents in the blob store.
+func (a attachment) blobKey(tenant string) string {
+	return tenant + "/" + a.Task + "/" + a.ID
+}
+
+// mediaType returns the media type of a content type without its parameters,
+// like text/plain for text/plain; charset=utf-8.  An empty string is returned
+// when it is not valid.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+// sniffedAs reports whether the contents look like the media type.  Text
+// formats, like CSV and JSON, are only sniffed as plain text.
+func sniffedAs(data []byte, mt string) bool {
+	sniffed := mediaType(http.DetectContentType(data))
+	if sniffed == mt {
+		return true
+	}
+	textual := strings.HasPrefix(mt, "text/") || mt == "application/json"
+	return textual && sniffed == "text/plain"
+}
+
+// fileName returns the field without surrounding white space, which must be
+// present, at most maxFileNameLength characters and a name without a
+// directory or control characters.
+func (v *validator) fileName(field, value string) string {
+	value = v.text(field, value, maxFileNameLength)
+	if v.has(field) {
+		return value
+	}
+	if value == "." || value == ".." || strings.ContainsAny(value, `/\`) || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		v.add(field, fieldInvalid, "%s field must be a file name without a directory", field)
+	}
+	return value
+}
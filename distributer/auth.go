@@ -0,0 +1,265 @@
+package distributer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// The roles that can be given to an API key or token.
+const (
+	RoleAdmin     = "admin"
+	RoleSubmitter = "submitter"
+	RoleAgent     = "agent"
+)
+
+// principalKey is the request context key holding the authenticated principal.
+type principalKey struct{}
+
+// principal is who a request was authenticated as.
+type principal struct {
+	Subject string
+	Tenant  string
+	Role    string
+	Agent   string
+}
+
+// apiKey is the payload for the database and HTTP response.  The key itself is
+// only present when the key is issued, the database only holds its hash.
+type apiKey struct {
+	ID         string    `json:"id"`
+	Tenant     string    `json:"tenant"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	Agent      string    `json:"agent,omitempty"`
+	Key        string    `json:"key,omitempty"`
+	CreateTime time.Time `json:"create_time"`
+	RevokeTime time.Time `json:"revoke_time,omitempty"`
+	hash       string
+}
+
+func validRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleSubmitter, RoleAgent:
+		return true
+	default:
+		return false
+	}
+}
+
+// generateAPIKey returns a new random key and the hash that is stored for it.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key := "td_" + base64.RawURLEncoding.EncodeToString(b)
+	return key, hashAPIKey(key), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// credentials returns the API key or bearer token sent with the request.
+func credentials(request *http.Request) string {
+	if key := request.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	auth := request.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// authenticate checks the request's API key or JWT.
+func (s *Server) authenticate(request *http.Request) (*principal, error) {
+	cred := credentials(request)
+	if cred == "" {
+		return nil, errors.New("an API key or bearer token is required")
+	}
+	if strings.Count(cred, ".") == 2 {
+		return verifyToken(s.jwtSecret, cred, time.Now())
+	}
+	key, err := s.store.APIKeyByHash(request.Context(), hashAPIKey(cred))
+	if err != nil || !key.RevokeTime.IsZero() {
+		return nil, errors.New("the API key is not valid")
+	}
+	return &principal{
+		Subject: key.ID,
+		Tenant:  key.Tenant,
+		Role:    key.Role,
+		Agent:   key.Agent,
+	}, nil
+}
+
+// tokenClaims are the JWT claims used to authenticate.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Tenant    string `json:"tenant"`
+	Role      string `json:"role"`
+	Agent     string `json:"agent"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// verifyToken checks a HS256 signed JWT with the secret, JWTs are not accepted
+// when the secret is empty.
+func verifyToken(secret []byte, token string, now time.Time) (*principal, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("bearer tokens are not supported")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the bearer token is malformed")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Algorithm != "HS256" {
+		return nil, errors.New("the bearer token algorithm is not supported")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("the bearer token is malformed")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if subtle.ConstantTimeCompare(signature, mac.Sum(nil)) != 1 {
+		return nil, errors.New("the bearer token signature is not valid")
+	}
+
+	var claims tokenClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return nil, errors.New("the bearer token is malformed")
+	}
+	if claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("the bearer token has expired")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return nil, errors.New("the bearer token is not valid yet")
+	}
+	if claims.Tenant == "" {
+		return nil, errors.New("the bearer token tenant must be present")
+	}
+	if !validRole(claims.Role) {
+		return nil, fmt.Errorf("the bearer token role %s is not supported", claims.Role)
+	}
+	if claims.Role == RoleAgent && claims.Agent == "" {
+		return nil, errors.New("the bearer token agent must be present")
+	}
+	return &principal{
+		Subject: claims.Subject,
+		Tenant:  claims.Tenant,
+		Role:    claims.Role,
+		Agent:   claims.Agent,
+	}, nil
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// requestPrincipal returns who the request was authenticated as.
+func requestPrincipal(request *http.Request) *principal {
+	p, _ := request.Context().Value(principalKey{}).(*principal)
+	return p
+}
+
+// authorize only allows requests authenticated with one of the roles.
+func (s *Server) authorize(handler http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		p, err := s.authenticate(request)
+		if err != nil {
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="task-distributer"`)
+			formatError(writer, request, newAPIError(http.StatusUnauthorized, codeUnauthorized, "Unauthorized %s", err.Error()))
+			return
+		}
+		allowed := false
+		for _, role := range roles {
+			if p.Role == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			formatError(writer, request, newAPIError(http.StatusForbidden, codeForbidden, "Role %s is not allowed", p.Role))
+			return
+		}
+		ctx := context.WithValue(request.Context(), principalKey{}, p)
+		handler(writer, request.WithContext(ctx))
+	}
+}
+
+// authorizeAgent only allows admins, or the agent in the route's id, access to
+// the agent's tasks.
+func (s *Server) authorizeAgent(handler http.HandlerFunc) http.HandlerFunc {
+	return s.authorize(func(writer http.ResponseWriter, request *http.Request) {
+		p := requestPrincipal(request)
+		if p.Role == RoleAgent && p.Agent != pathParam(request, "id") {
+			formatError(writer, request, newAPIError(http.StatusForbidden, codeForbidden, "Agents may only access their own tasks"))
+			return
+		}
+		handler(writer, request)
+	}, RoleAdmin, RoleAgent)
+}
+
+// issueAPIKey creates a new key with the role in the tenant.  Agent keys must
+// be for an agent of the tenant.
+func (s *Server) issueAPIKey(ctx context.Context, tenant, name, role, agentID string) (apiKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return apiKey{}, errors.New("name field must be present")
+	}
+	if !validRole(role) {
+		return apiKey{}, fmt.Errorf("role %s is not supported", role)
+	}
+	switch {
+	case role == RoleAgent && agentID == "":
+		return apiKey{}, errors.New("agent field must be present for the agent role")
+	case role != RoleAgent && agentID != "":
+		return apiKey{}, errors.New("agent field is only supported for the agent role")
+	case agentID != "":
+		if _, err := s.store.Agents(ctx, tenant, []string{agentID}); err != nil {
+			return apiKey{}, fmt.Errorf("agent %s is not present", agentID)
+		}
+	}
+
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		return apiKey{}, err
+	}
+	k := apiKey{
+		ID:         xid.New().String(),
+		Tenant:     tenant,
+		Name:       name,
+		Role:       role,
+		Agent:      agentID,
+		Key:        key,
+		CreateTime: time.Now(),
+		hash:       hash,
+	}
+	if err := s.store.CreateAPIKey(ctx, k); err != nil {
+		return apiKey{}, err
+	}
+	return k, nil
+}
@@ -0,0 +1,187 @@
+package distributer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func signTestToken(secret []byte, header, claims string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_verifyToken(t *testing.T) {
+	secret := []byte("secret")
+
+	now := time.Unix(1557100000, 0)
+	header := `{"alg":"HS256","typ":"JWT"}`
+	type args struct {
+		token string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *principal
+		wantErr bool
+	}{
+		{
+			name: "Submitter",
+			args: args{
+				token: signTestToken(secret, header, `{"tenant":"support","sub":"ops","role":"submitter","exp":1557200000}`),
+			},
+			want: &principal{
+				Subject: "ops",
+				Tenant:  "support",
+				Role:    RoleSubmitter,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Agent",
+			args: args{
+				token: signTestToken(secret, header, `{"tenant":"support","sub":"jazz","role":"agent","agent":"1003","exp":1557200000}`),
+			},
+			want: &principal{
+				Subject: "jazz",
+				Tenant:  "support",
+				Role:    RoleAgent,
+				Agent:   "1003",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Agent without agent",
+			args: args{
+				token: signTestToken(secret, header, `{"tenant":"support","sub":"jazz","role":"agent","exp":1557200000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "No tenant",
+			args: args{
+				token: signTestToken(secret, header, `{"sub":"ops","role":"submitter","exp":1557200000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Expired",
+			args: args{
+				token: signTestToken(secret, header, `{"tenant":"support","sub":"ops","role":"submitter","exp":1557000000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Wrong secret",
+			args: args{
+				token: signTestToken([]byte("other"), header, `{"tenant":"support","sub":"ops","role":"admin","exp":1557200000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Algorithm none",
+			args: args{
+				token: signTestToken(secret, `{"alg":"none"}`, `{"tenant":"support","sub":"ops","role":"admin","exp":1557200000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Unknown role",
+			args: args{
+				token: signTestToken(secret, header, `{"tenant":"support","sub":"ops","role":"root","exp":1557200000}`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyToken(secret, tt.args.token, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("verifyToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_authorizeAgent(t *testing.T) {
+	secret := []byte("secret")
+	s, err := NewServer(newMemoryStore(), Config{JWTSecret: secret})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	bearer := func(claims string) string {
+		return "Bearer " + signTestToken(secret, `{"alg":"HS256","typ":"JWT"}`, fmt.Sprintf(claims, exp))
+	}
+	r := newRouter()
+	r.handle(http.MethodGet, "/v1/agent/{id}/tasks", s.authorizeAgent(func(writer http.ResponseWriter, request *http.Request) {}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		target        string
+		wantStatus    int
+	}{
+		{
+			name:       "No credentials",
+			target:     "/v1/agent/1003/tasks",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "Own tasks",
+			authorization: bearer(`{"tenant":"support","sub":"jazz","role":"agent","agent":"1003","exp":%d}`),
+			target:        "/v1/agent/1003/tasks",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:          "Other agent's tasks",
+			authorization: bearer(`{"tenant":"support","sub":"jazz","role":"agent","agent":"1003","exp":%d}`),
+			target:        "/v1/agent/1000/tasks",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "Admin",
+			authorization: bearer(`{"tenant":"support","sub":"ops","role":"admin","exp":%d}`),
+			target:        "/v1/agent/1000/tasks",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:          "Submitter",
+			authorization: bearer(`{"tenant":"support","sub":"ops","role":"submitter","exp":%d}`),
+			target:        "/v1/agent/1000/tasks",
+			wantStatus:    http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("authorizeAgent() status = %v, want %v", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
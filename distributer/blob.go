@@ -0,0 +1,180 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errBlobNotFound is returned by BlobStore.Get when there is no blob with the
+// key.
+var errBlobNotFound = errors.New("blob is not present")
+
+// BlobStore keeps the contents of the task attachments by key.  The keys are
+// made of path segments of letters, digits, underscores and dashes, like
+// support/bj7rmmrk7c874r7vb8ng/bj7rn2rk7c874r7vb8o0.  A BlobStore is opened
+// with OpenBlobStore, or can be any other storage set in the Config.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get returns the blob, or an error wrapping errBlobNotFound when there
+	// is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// validBlobKey reports whether the key is only made of segments that are safe
+// as file names and in URLs.
+func validBlobKey(key string) bool {
+	for _, segment := range strings.Split(key, "/") {
+		if !validCode(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenBlobStore opens the blob store for the URL.  A file://<dir> URL, or a
+// plain directory, keeps the blobs in files under the directory, which is the
+// default of an empty URL.  An s3://<bucket> URL keeps them in the bucket of
+// an S3 compatible service, with the endpoint and region query parameters,
+// like s3://attachments?endpoint=https://s3.eu-west-1.amazonaws.com&region=eu-west-1,
+// and the credentials in $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY.
+func OpenBlobStore(rawURL string, getenv func(string) string) (BlobStore, error) {
+	switch {
+	case rawURL == "":
+		return newLocalBlobStore(defaultBlobDir), nil
+	case strings.HasPrefix(rawURL, "file://"):
+		return newLocalBlobStore(strings.TrimPrefix(rawURL, "file://")), nil
+	case strings.HasPrefix(rawURL, "s3://"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		query := u.Query()
+		return newS3BlobStore(s3Config{
+			Endpoint:  query.Get("endpoint"),
+			Region:    query.Get("region"),
+			Bucket:    u.Host,
+			AccessKey: getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: getenv("AWS_SECRET_ACCESS_KEY"),
+		})
+	case strings.Contains(rawURL, "://"):
+		return nil, fmt.Errorf("blob store %s is not supported, use file:// or s3://", rawURL)
+	default:
+		return newLocalBlobStore(rawURL), nil
+	}
+}
+
+// defaultBlobDir is the directory of the attachments when no blob store is
+// configured.
+const defaultBlobDir = "attachments"
+
+// localBlobStore is the BlobStore keeping each blob in a file under its
+// directory.
+type localBlobStore struct {
+	dir string
+}
+
+func newLocalBlobStore(dir string) *localBlobStore {
+	return &localBlobStore{dir: dir}
+}
+
+func (s *localBlobStore) path(key string) (string, error) {
+	if !validBlobKey(key) {
+		return "", fmt.Errorf("blob key %s is not valid", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file that is renamed once it is complete,
+// so a failed write never leaves part of a blob.
+func (s *localBlobStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *localBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errBlobNotFound, key)
+	}
+	return data, err
+}
+
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// tracedBlobStore starts a span for each call of the blob store it wraps.
+type tracedBlobStore struct {
+	blobs BlobStore
+}
+
+// traceBlobStore returns the blob store with a span for each call, or the
+// blob store itself when there is no tracer.
+func traceBlobStore(blobs BlobStore, tracer *Tracer) BlobStore {
+	if tracer == nil {
+		return blobs
+	}
+	return &tracedBlobStore{blobs: blobs}
+}
+
+func (s *tracedBlobStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	ctx, sp := startSpan(ctx, "blob.Put", spanKindClient)
+	defer sp.finish()
+	sp.setAttributes("blob.key", key)
+	err := s.blobs.Put(ctx, key, contentType, data)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, sp := startSpan(ctx, "blob.Get", spanKindClient)
+	defer sp.finish()
+	sp.setAttributes("blob.key", key)
+	v, err := s.blobs.Get(ctx, key)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedBlobStore) Delete(ctx context.Context, key string) error {
+	ctx, sp := startSpan(ctx, "blob.Delete", spanKindClient)
+	defer sp.finish()
+	sp.setAttributes("blob.key", key)
+	err := s.blobs.Delete(ctx, key)
+	sp.setError(err)
+	return err
+}
@@ -0,0 +1,193 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RunCommand runs one of the operator commands instead of serving requests,
+// like
+//
+//	task-distributer migrate up
+func (s *Server) RunCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "apikey":
+		return s.apiKeyCommand(ctx, args[1:])
+	case "tenant":
+		return s.tenantCommand(ctx, args[1:])
+	case "migrate":
+		return s.migrateCommand(ctx, args[1:])
+	default:
+		return fmt.Errorf("command %s is not supported", args[0])
+	}
+}
+
+// apiKeyCommand issues, lists and revokes API keys.  This is how the first
+// admin key of a tenant is issued.
+func (s *Server) apiKeyCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: apikey issue|list|revoke")
+	}
+	flags := flag.NewFlagSet("apikey "+args[0], flag.ContinueOnError)
+	tenant := flags.String("tenant", DefaultTenant, "tenant of the keys")
+	switch args[0] {
+	case "issue":
+		name := flags.String("name", "", "name of who the key is for")
+		role := flags.String("role", RoleSubmitter, "role of the key: admin, submitter or agent")
+		agentID := flags.String("agent", "", "agent id for the agent role")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		key, err := s.issueAPIKey(ctx, *tenant, *name, *role, *agentID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("id:     %s\ntenant: %s\nrole:   %s\nkey:    %s\n", key.ID, key.Tenant, key.Role, key.Key)
+		fmt.Println("The key is not stored and can not be shown again.")
+		return nil
+	case "list":
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		keys, err := s.store.APIKeys(ctx, *tenant)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tROLE\tAGENT\tCREATED\tREVOKED")
+		for _, key := range keys {
+			revoked := ""
+			if !key.RevokeTime.IsZero() {
+				revoked = key.RevokeTime.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Role, key.Agent, key.CreateTime.Format(time.RFC3339), revoked)
+		}
+		return w.Flush()
+	case "revoke":
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New("usage: apikey revoke [-tenant <tenant>] <id>")
+		}
+		id := flags.Arg(0)
+		revoked, err := s.store.RevokeAPIKey(ctx, *tenant, id)
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return fmt.Errorf("API key %s is not present", id)
+		}
+		fmt.Printf("API key %s revoked\n", id)
+		return nil
+	default:
+		return fmt.Errorf("apikey command %s is not supported", args[0])
+	}
+}
+
+// tenantCommand creates and lists the tenants.
+func (s *Server) tenantCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: tenant create|list")
+	}
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("tenant create", flag.ContinueOnError)
+		id := flags.String("id", "", "id of the tenant, like support")
+		name := flags.String("name", "", "name of the tenant, like Support Team")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *id == "" || len(*id) > 100 || strings.TrimSpace(*name) == "" {
+			return errors.New("usage: tenant create -id <id> -name <name>")
+		}
+		// The id is part of the blob keys of the tenant's attachments.
+		if !validCode(*id) {
+			return fmt.Errorf("tenant id %s must only have letters, digits, _ and -", *id)
+		}
+		t := tenant{
+			ID:         *id,
+			Name:       *name,
+			CreateTime: time.Now(),
+		}
+		if err := s.store.CreateTenant(ctx, t); err != nil {
+			return err
+		}
+		fmt.Printf("Tenant %s created\n", t.ID)
+		return nil
+	case "list":
+		tenants, err := s.store.Tenants(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tCREATED")
+		for _, t := range tenants {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.ID, t.Name, t.CreateTime.Format(time.RFC3339))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("tenant command %s is not supported", args[0])
+	}
+}
+
+// migrateCommand applies, reverts and lists the schema migrations.
+func (s *Server) migrateCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up [version]|down [steps]|status")
+	}
+	m, err := storeMigrator(s.store)
+	if err != nil {
+		return err
+	}
+	count := 0
+	if len(args) > 1 {
+		if count, err = strconv.Atoi(args[1]); err != nil || count < 1 {
+			return fmt.Errorf("migrate %s %s must be a positive number", args[0], args[1])
+		}
+	}
+	switch args[0] {
+	case "up":
+		done, err := m.up(ctx, count)
+		for _, mig := range done {
+			fmt.Printf("Applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("The schema is up to date")
+		}
+		return err
+	case "down":
+		if count == 0 {
+			count = 1
+		}
+		done, err := m.down(ctx, count)
+		for _, mig := range done {
+			fmt.Printf("Reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if !status.AppliedTime.IsZero() {
+				applied = status.AppliedTime.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("migrate command %s is not supported", args[0])
+	}
+}
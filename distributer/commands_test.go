@@ -0,0 +1,56 @@
+package distributer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/xid"
+)
+
+func Test_Server_tenantCommand(t *testing.T) {
+	s, err := NewServer(newTestStore(t), Config{})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{
+			name: "Create tenant",
+			id:   "support-team_2",
+		},
+		{
+			name:    "Id with a space",
+			id:      "support team",
+			wantErr: true,
+		},
+		{
+			name:    "Id with a dot",
+			id:      "support.team",
+			wantErr: true,
+		},
+		{
+			name:    "Id with a slash",
+			id:      "support/team",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.RunCommand(context.Background(), []string{"tenant", "create", "-id", tt.id, "-name", "Support"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Server.RunCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The tenant's attachments can be kept in the blob store.
+			a := attachment{ID: xid.New().String(), Task: xid.New().String()}
+			if key := a.blobKey(tt.id); !validBlobKey(key) {
+				t.Errorf("attachment.blobKey() = %s, want a valid blob key", key)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package distributer
+
+import "time"
+
+// comment is a note on a task, left by an agent or a submitter, for the
+// database and HTTP response.
+type comment struct {
+	ID   string `json:"id"`
+	Task string `json:"task"`
+	// Author is the subject of the API key or token that left the comment,
+	// and Role its role.
+	Author     string    `json:"author"`
+	Role       string    `json:"role"`
+	Agent      string    `json:"agent,omitempty"`
+	Body       string    `json:"body"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// commentPayload is the body of a new comment.
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+// validate normalizes the comment and returns its invalid fields.
+func (p *commentPayload) validate() []fieldError {
+	v := &validator{}
+	p.Body = v.text("body", p.Body, maxCommentLength)
+	return v.invalid
+}
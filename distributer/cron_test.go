@@ -0,0 +1,62 @@
+package distributer
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "Every minute", expr: "* * * * *"},
+		{name: "Lists, ranges and steps", expr: "*/15 9-17 1,15 * mon-fri"},
+		{name: "Names", expr: "0 9 * jan-mar SUN"},
+		{name: "Sunday as 7", expr: "0 9 * * 7"},
+		{name: "Shorthand", expr: "@daily"},
+		{name: "Too few fields", expr: "0 9 * *", wantErr: true},
+		{name: "Minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "Reversed range", expr: "0 17-9 * * *", wantErr: true},
+		{name: "Invalid step", expr: "*/0 * * * *", wantErr: true},
+		{name: "Unknown name", expr: "0 9 * * someday", wantErr: true},
+		{name: "Never matches", expr: "0 0 30 2 *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCron(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("parseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_cronSchedule_next(t *testing.T) {
+	// A Wednesday.
+	after := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{name: "Next minute", expr: "* * * * *", want: time.Date(2024, 1, 31, 10, 8, 0, 0, time.UTC)},
+		{name: "Step", expr: "*/15 * * * *", want: time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{name: "Next day", expr: "0 9 * * *", want: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "Weekday", expr: "30 8 * * mon", want: time.Date(2024, 2, 5, 8, 30, 0, 0, time.UTC)},
+		{name: "Leap day", expr: "0 0 29 2 *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "Day of month or week", expr: "0 0 15 * fri", want: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "Next year", expr: "@yearly", want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron() error = %v", err)
+			}
+			if got := c.next(after); !got.Equal(tt.want) {
+				t.Errorf("cronSchedule.next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package distributer
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+// The dashboard is a single page that calls the APIs with the key the operator
+// enters, so serving it does not need one.
+//
+//go:embed dashboard/*
+var dashboardFiles embed.FS
+
+// dashboardHandler will serve the dashboard's page, or the file of the file
+// parameter.  The page is only allowed to load its own files and call the
+// APIs of the same origin.
+func (s *Server) dashboardHandler(writer http.ResponseWriter, request *http.Request) {
+	name := pathParam(request, "file")
+	if name == "" {
+		name = "index.html"
+	}
+	files, _ := fs.Sub(dashboardFiles, "dashboard")
+	if _, err := fs.Stat(files, name); err != nil || path.Base(name) != name {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Route %s is not present", request.URL.Path))
+		return
+	}
+	writer.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.Header().Set("Cache-Control", "no-cache")
+	http.ServeFileFS(writer, request, files, name)
+}
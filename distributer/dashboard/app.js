@@ -0,0 +1,354 @@
+// The dashboard calls the APIs with the key the operator signs in with, which
+// is kept for the browser tab only, and refreshes the agents, queue and
+// completions every few seconds while it is live and visible.
+"use strict";
+
+const refreshInterval = 5000;
+const recentCompletions = 20;
+
+const state = {
+  key: sessionStorage.getItem("key") || "",
+  priorities: [],
+  timer: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// api calls the API and returns its JSON response, throwing the error message
+// of an unsuccessful one.
+async function api(method, path, body) {
+  const headers = { Authorization: "Bearer " + state.key, Accept: "application/json" };
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+  const response = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok || data.success === false) {
+    const error = new Error(describeError(data, response));
+    error.status = response.status;
+    throw error;
+  }
+  return data;
+}
+
+function describeError(data, response) {
+  const message = data.error_message || response.statusText;
+  const fields = (data.details || []).map((d) => d.message);
+  return fields.length > 0 ? message + ": " + fields.join(", ") : message;
+}
+
+function showMessage(text, isError) {
+  const message = $("message");
+  message.textContent = text;
+  message.className = isError ? "error" : "";
+  message.hidden = !text;
+}
+
+// element returns a new element with the text, or the child elements.
+function element(tag, content, className) {
+  const el = document.createElement(tag);
+  if (Array.isArray(content)) {
+    el.append(...content);
+  } else if (content !== undefined && content !== null) {
+    el.textContent = String(content);
+  }
+  if (className) {
+    el.className = className;
+  }
+  return el;
+}
+
+function row(cells) {
+  return element("tr", cells.map((cell) => (cell instanceof Node ? element("td", [cell]) : element("td", cell))));
+}
+
+function fillTable(id, rows, columns, empty) {
+  const body = $(id);
+  if (rows.length === 0) {
+    const cell = element("td", empty, "empty");
+    cell.colSpan = columns;
+    body.replaceChildren(element("tr", [cell]));
+    return;
+  }
+  body.replaceChildren(...rows);
+}
+
+function formatDuration(ms) {
+  const seconds = Math.max(0, Math.round(ms / 1000));
+  if (seconds < 60) {
+    return seconds + "s";
+  }
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) {
+    return minutes + "m " + (seconds % 60) + "s";
+  }
+  const hours = Math.floor(minutes / 60);
+  if (hours < 48) {
+    return hours + "h " + (minutes % 60) + "m";
+  }
+  return Math.floor(hours / 24) + "d " + (hours % 24) + "h";
+}
+
+function formatTime(value) {
+  return new Date(value).toLocaleString();
+}
+
+function priorityCell(priority) {
+  const top = state.priorities.length > 0 && state.priorities[0].priority === priority;
+  return element("span", priority, top ? "priority-top" : "");
+}
+
+function taskName(task) {
+  const name = element("span", task.name);
+  name.title = task.id;
+  return name;
+}
+
+// choices fills the container with a checkbox for each skill.
+function choices(id, skills) {
+  const checked = new Set(selected(id));
+  $(id).replaceChildren(
+    ...skills.map((s) => {
+      const box = element("input");
+      box.type = "checkbox";
+      box.value = s.skill;
+      box.checked = checked.has(s.skill);
+      const label = element("label", [box, " " + s.skill]);
+      label.title = s.description;
+      return label;
+    }),
+  );
+}
+
+function selected(id) {
+  return Array.from($(id).querySelectorAll("input:checked"), (box) => box.value);
+}
+
+function options(select, values, keep) {
+  const current = select.value;
+  const kept = Array.from(select.options).slice(0, keep);
+  select.replaceChildren(
+    ...kept,
+    ...values.map(([value, label]) => {
+      const option = element("option", label);
+      option.value = value;
+      return option;
+    }),
+  );
+  select.value = current;
+}
+
+// loadChoices loads the skills, priorities and templates of the forms.
+async function loadChoices() {
+  const [skills, priorities, templates] = await Promise.all([
+    api("GET", "/v1/skill"),
+    api("GET", "/v1/priority"),
+    api("GET", "/v1/template"),
+  ]);
+  state.priorities = priorities.priorities.slice().sort((a, b) => b.priority_level - a.priority_level);
+  for (const id of ["task-skills", "agent-skills", "update-skills"]) {
+    choices(id, skills.skills);
+  }
+  const form = $("task-form");
+  options(form.elements.priority, state.priorities.map((p) => [p.priority, p.priority]), 1);
+  options(form.elements.template, templates.templates.map((t) => [t.name, t.name]), 1);
+}
+
+// refresh loads the agents, queue and recent completions.
+async function refresh() {
+  const [agents, queued, completed] = await Promise.all([
+    api("GET", "/v1/agent"),
+    api("GET", "/v1/task?status=Assigned&limit=1000"),
+    api("GET", "/v1/task?status=Complete&limit=100"),
+  ]);
+  const now = Date.now();
+
+  fillTable(
+    "agents",
+    agents.agent_tasks.map((a) => {
+      const tasks = (a.tasks || []).map((t) =>
+        element("li", [taskName(t), " ", priorityCell(t.priority), " " + t.status]),
+      );
+      return row([a.id, a.first_name + " " + a.last_name, tasks.length, tasks.length > 0 ? element("ul", tasks) : ""]);
+    }),
+    4,
+    "No agents.",
+  );
+  options(
+    $("skills-form").elements.agent,
+    agents.agent_tasks.map((a) => [a.id, a.id + " " + a.first_name + " " + a.last_name]),
+    0,
+  );
+
+  const queue = queued.tasks.slice().sort((a, b) => new Date(a.start_time) - new Date(b.start_time));
+  fillTable(
+    "queue",
+    queue.map((t) => {
+      const cancel = element("button", "Cancel");
+      cancel.type = "button";
+      cancel.addEventListener("click", () => cancelTask(t));
+      return row([
+        taskName(t),
+        t.skills.join(", "),
+        priorityCell(t.priority),
+        t.assigned_agent,
+        formatDuration(now - new Date(t.start_time)),
+        cancel,
+      ]);
+    }),
+    6,
+    "No tasks are waiting.",
+  );
+
+  const done = completed.tasks
+    .slice()
+    .sort((a, b) => new Date(b.complete_time) - new Date(a.complete_time))
+    .slice(0, recentCompletions);
+  fillTable(
+    "completions",
+    done.map((t) =>
+      row([
+        taskName(t),
+        priorityCell(t.priority),
+        t.assigned_agent,
+        formatTime(t.complete_time),
+        formatDuration(new Date(t.complete_time) - new Date(t.start_time)),
+        t.outcome || "",
+      ]),
+    ),
+    6,
+    "No tasks have been completed.",
+  );
+
+  $("updated").textContent = "Updated " + new Date(now).toLocaleTimeString();
+}
+
+async function cancelTask(task) {
+  if (!confirm("Cancel " + task.name + "?")) {
+    return;
+  }
+  await run(async () => {
+    await api("POST", "/v1/task/" + encodeURIComponent(task.id) + "/cancel");
+    showMessage("Cancelled " + task.name + ".");
+    await refresh();
+  });
+}
+
+// run runs the action, showing its error, and signs out if the key is not
+// accepted.
+async function run(action) {
+  try {
+    await action();
+  } catch (error) {
+    if (error.status === 401) {
+      signOut();
+    }
+    showMessage(error.message, true);
+  }
+}
+
+function schedule() {
+  clearTimeout(state.timer);
+  if (!state.key || !$("live").checked) {
+    return;
+  }
+  state.timer = setTimeout(async () => {
+    if (!document.hidden) {
+      await run(refresh);
+    }
+    schedule();
+  }, refreshInterval);
+}
+
+async function signIn(key) {
+  state.key = key;
+  sessionStorage.setItem("key", key);
+  $("key-form").hidden = true;
+  $("session").hidden = false;
+  $("main").hidden = false;
+  showMessage("");
+  await run(async () => {
+    await loadChoices();
+    await refresh();
+  });
+  schedule();
+}
+
+function signOut() {
+  state.key = "";
+  sessionStorage.removeItem("key");
+  clearTimeout(state.timer);
+  $("key-form").hidden = false;
+  $("session").hidden = true;
+  $("main").hidden = true;
+}
+
+function onSubmit(id, action) {
+  $(id).addEventListener("submit", (event) => {
+    event.preventDefault();
+    run(() => action(event.target));
+  });
+}
+
+onSubmit("key-form", (form) => {
+  const key = $("key").value.trim();
+  form.reset();
+  return signIn(key);
+});
+
+onSubmit("task-form", async (form) => {
+  const body = {};
+  for (const field of ["name", "priority", "description"]) {
+    const value = form.elements[field].value.trim();
+    if (value) {
+      body[field] = value;
+    }
+  }
+  const skills = selected("task-skills");
+  if (skills.length > 0) {
+    body.skills = skills;
+  }
+  const template = form.elements.template.value;
+  const path = "/v1/task/create" + (template ? "?template=" + encodeURIComponent(template) : "");
+  const created = await api("POST", path, body);
+  form.reset();
+  showMessage("Created " + created.task.name + " for agent " + created.task.assigned_agent + ".");
+  await refresh();
+});
+
+onSubmit("agent-form", async (form) => {
+  const body = {
+    id: form.elements.id.value.trim(),
+    first_name: form.elements.first_name.value.trim(),
+    last_name: form.elements.last_name.value.trim(),
+    skills: selected("agent-skills"),
+  };
+  const created = await api("POST", "/v1/agent", body);
+  form.reset();
+  showMessage("Created agent " + created.agent.id + ".");
+  await refresh();
+});
+
+onSubmit("skills-form", async (form) => {
+  const agent = form.elements.agent.value;
+  const updated = await api("PUT", "/v1/agent/" + encodeURIComponent(agent) + "/skills", {
+    skills: selected("update-skills"),
+  });
+  showMessage("Agent " + updated.agent.id + " has " + (updated.agent.skills || []).join(", ") + ".");
+});
+
+$("sign-out").addEventListener("click", signOut);
+$("live").addEventListener("change", schedule);
+document.addEventListener("visibilitychange", () => {
+  if (!document.hidden && state.key && $("live").checked) {
+    run(refresh);
+  }
+});
+
+if (state.key) {
+  signIn(state.key);
+}
@@ -0,0 +1,62 @@
+package distributer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_Server_dashboardHandler(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantContentType string
+		want            string
+	}{
+		{
+			name:            "Page",
+			path:            "/dashboard",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html",
+			want:            "<title>Task Distributer</title>",
+		},
+		{
+			name:            "Script",
+			path:            "/dashboard/app.js",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/javascript",
+			want:            `api("GET", "/v1/agent")`,
+		},
+		{
+			name:            "Style",
+			path:            "/dashboard/style.css",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/css",
+		},
+		{
+			name:       "Unknown file",
+			path:       "/dashboard/missing.js",
+			wantStatus: http.StatusNotFound,
+			want:       `"code":"not_found"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, http.MethodGet, tt.path, "", "")
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("GET %s status = %v, want %v %s", tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.HasPrefix(resp.Header().Get("Content-Type"), tt.wantContentType) {
+				t.Errorf("GET %s Content-Type = %s, want %s", tt.path, resp.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			if !strings.Contains(resp.Body.String(), tt.want) {
+				t.Errorf("GET %s body = %s, want %s", tt.path, resp.Body.String(), tt.want)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(resp.Header().Get("Content-Security-Policy"), "default-src 'self'") {
+				t.Errorf("GET %s Content-Security-Policy = %s, want only the same origin", tt.path, resp.Header().Get("Content-Security-Policy"))
+			}
+		})
+	}
+}
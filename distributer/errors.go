@@ -0,0 +1,157 @@
+package distributer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// The codes of the API's errors.  They are stable, unlike the messages, so
+// clients can act on them.
+const (
+	codeInvalidPayload   = "invalid_payload"
+	codeInvalidParameter = "invalid_parameter"
+	codeValidationFailed = "validation_failed"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeAlreadyExists    = "already_exists"
+	codeInvalidState     = "invalid_state"
+	codeConcurrentUpdate = "concurrent_update"
+	codeNoAgentAvailable = "no_agent_available"
+	codePayloadTooLarge  = "payload_too_large"
+	codeUnsupportedMedia = "unsupported_media_type"
+	codeChecksumMismatch = "checksum_mismatch"
+	codeNotReady         = "not_ready"
+	codeInternal         = "internal_error"
+)
+
+// The codes of the invalid fields of a validation_failed error.
+const (
+	fieldRequired    = "required"
+	fieldTooLong     = "too_long"
+	fieldUnsupported = "unsupported"
+	fieldInvalid     = "invalid"
+)
+
+// problemContentType is the media type of RFC 7807 problem details, sent to
+// clients that accept it.
+const problemContentType = "application/problem+json"
+
+// fieldError is an invalid field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiError is an error response, with the HTTP status, the stable code and
+// the invalid fields of a validation error.
+type apiError struct {
+	status  int
+	code    string
+	message string
+	details []fieldError
+	// cause is logged for internal errors instead of being sent to the
+	// client.
+	cause error
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newAPIError(status int, code, format string, args ...interface{}) *apiError {
+	return &apiError{
+		status:  status,
+		code:    code,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// internalError is a failure of the server, the cause is logged and the
+// client only gets the message.
+func internalError(cause error, format string, args ...interface{}) *apiError {
+	e := newAPIError(http.StatusInternalServerError, codeInternal, format, args...)
+	e.cause = cause
+	return e
+}
+
+// validationError reports every invalid field of a request.
+func validationError(details ...fieldError) *apiError {
+	messages := make([]string, len(details))
+	for idx, d := range details {
+		messages[idx] = d.Message
+	}
+	e := newAPIError(http.StatusBadRequest, codeValidationFailed, "Invalid request %s", strings.Join(messages, ", "))
+	e.details = details
+	return e
+}
+
+// acceptsProblem reports whether the client asked for RFC 7807 problem
+// details.
+func acceptsProblem(request *http.Request) bool {
+	return request != nil && strings.Contains(request.Header.Get("Accept"), problemContentType)
+}
+
+// formatError writes the error response.  It is the usual
+// {"success":false,"error_message":...} with the code and invalid fields, or
+// RFC 7807 problem details when the client accepts application/problem+json.
+func formatError(writer http.ResponseWriter, request *http.Request, e *apiError) {
+	if e.cause != nil {
+		ctx := context.Background()
+		if request != nil {
+			ctx = request.Context()
+		}
+		ctxLogger(ctx).Log(ctx, slog.LevelError, e.message, "code", e.code, "error", e.cause)
+	}
+
+	contentType := "application/json"
+	var body interface{}
+	if acceptsProblem(request) {
+		contentType = problemContentType
+		body = struct {
+			Type     string       `json:"type"`
+			Title    string       `json:"title"`
+			Status   int          `json:"status"`
+			Detail   string       `json:"detail"`
+			Instance string       `json:"instance,omitempty"`
+			Code     string       `json:"code"`
+			Errors   []fieldError `json:"errors,omitempty"`
+		}{
+			Type:     "urn:task-distributer:error:" + e.code,
+			Title:    http.StatusText(e.status),
+			Status:   e.status,
+			Detail:   e.message,
+			Instance: request.URL.Path,
+			Code:     e.code,
+			Errors:   e.details,
+		}
+	} else {
+		body = struct {
+			Success      bool         `json:"success"`
+			ErrorMessage string       `json:"error_message"`
+			Code         string       `json:"code"`
+			Details      []fieldError `json:"details,omitempty"`
+		}{
+			Success:      false,
+			ErrorMessage: e.message,
+			Code:         e.code,
+			Details:      e.details,
+		}
+	}
+	resp, err := json.Marshal(body)
+	if err != nil {
+		contentType = "application/json"
+		e = internalError(err, "Unable to encode error")
+		resp = []byte(`{"success":false,"error_message":"Unable to encode error","code":"internal_error"}`)
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(e.status)
+	writer.Write(resp)
+}
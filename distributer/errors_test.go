@@ -0,0 +1,162 @@
+package distributer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_formatError(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		err             *apiError
+		wantStatus      int
+		wantContentType string
+		want            map[string]interface{}
+	}{
+		{
+			name:            "Validation error",
+			err:             validationError(fieldError{Field: "name", Code: fieldRequired, Message: "name field must be present"}, fieldError{Field: "priority", Code: fieldRequired, Message: "priority field must be present"}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "application/json",
+			want: map[string]interface{}{
+				"success":       false,
+				"error_message": "Invalid request name field must be present, priority field must be present",
+				"code":          "validation_failed",
+				"details": []interface{}{
+					map[string]interface{}{"field": "name", "code": "required", "message": "name field must be present"},
+					map[string]interface{}{"field": "priority", "code": "required", "message": "priority field must be present"},
+				},
+			},
+		},
+		{
+			name:            "Internal error hides the cause",
+			err:             internalError(errors.New("pq: connection refused"), "Unable to retrieve tasks"),
+			wantStatus:      http.StatusInternalServerError,
+			wantContentType: "application/json",
+			want: map[string]interface{}{
+				"success":       false,
+				"error_message": "Unable to retrieve tasks",
+				"code":          "internal_error",
+			},
+		},
+		{
+			name:            "Problem details",
+			accept:          "application/problem+json, application/json",
+			err:             newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", "bj7s9crv2l7ljuu409u0"),
+			wantStatus:      http.StatusNotFound,
+			wantContentType: problemContentType,
+			want: map[string]interface{}{
+				"type":     "urn:task-distributer:error:not_found",
+				"title":    "Not Found",
+				"status":   float64(http.StatusNotFound),
+				"detail":   "Task bj7s9crv2l7ljuu409u0 is not present",
+				"instance": "/v1/task/bj7s9crv2l7ljuu409u0",
+				"code":     "not_found",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/v1/task/bj7s9crv2l7ljuu409u0", nil)
+			if tt.accept != "" {
+				request.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+			formatError(recorder, request, tt.err)
+			if recorder.Code != tt.wantStatus || recorder.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("formatError() = %v %s, want %v %s", recorder.Code, recorder.Header().Get("Content-Type"), tt.wantStatus, tt.wantContentType)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatalf("formatError() body %s error = %v", recorder.Body.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Server_errorCodes(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Malformed payload",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"name":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeInvalidPayload,
+		},
+		{
+			name:       "Payload too large",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"name":"` + strings.Repeat("n", maxPayloadSize) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   codePayloadTooLarge,
+		},
+		{
+			name:       "Missing fields",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"skills":["skill1"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeValidationFailed,
+		},
+		{
+			name:       "No agent available",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"name":"Test Name","skills":["skill1","skill2","skill3"],"priority":"low"}`,
+			wantStatus: http.StatusConflict,
+			wantCode:   codeNoAgentAvailable,
+		},
+		{
+			name:       "Unauthorized",
+			method:     http.MethodGet,
+			path:       "/v1/task",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   codeUnauthorized,
+		},
+		{
+			name:       "Method not allowed",
+			method:     http.MethodDelete,
+			path:       "/v1/task/create",
+			key:        submitter,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCode:   codeMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, tt.method, tt.path, tt.key, tt.body)
+			var body struct {
+				Code string `json:"code"`
+			}
+			json.NewDecoder(strings.NewReader(resp.Body.String())).Decode(&body)
+			if resp.Code != tt.wantStatus || body.Code != tt.wantCode {
+				t.Errorf("%s %s = %v %s, want %v %s", tt.method, tt.path, resp.Code, resp.Body.String(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package distributer
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// The types of events recorded as a task moves through its statuses.
+const (
+	eventTaskAssigned  = "task.assigned"
+	eventTaskAccepted  = "task.accepted"
+	eventTaskStarted   = "task.started"
+	eventTaskCompleted = "task.completed"
+	eventTaskCancelled = "task.cancelled"
+)
+
+// statusEvents are the event types of the task statuses.
+var statusEvents = map[string]string{
+	statusAssigned:  eventTaskAssigned,
+	statusAccepted:  eventTaskAccepted,
+	statusStarted:   eventTaskStarted,
+	statusComplete:  eventTaskCompleted,
+	statusCancelled: eventTaskCancelled,
+}
+
+// event is the payload for the database and HTTP response.  The ids of a
+// tenant's events increase, so the events after one can be followed.
+type event struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	Task       string    `json:"task"`
+	Agent      string    `json:"agent,omitempty"`
+	Status     string    `json:"status"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// recordEvent records the task's change to its current status.  The change has
+// already been made, so the event is recorded even if the request is canceled
+// and a failure is only logged.
+func (s *Server) recordEvent(ctx context.Context, tenant string, t task) {
+	e := event{
+		Type:       statusEvents[t.Status],
+		Task:       t.ID,
+		Agent:      t.Agent,
+		Status:     t.Status,
+		CreateTime: time.Now(),
+	}
+	s.metrics.taskEvent(tenant, strings.TrimPrefix(e.Type, "task."), t)
+	if err := s.store.CreateEvent(context.WithoutCancel(ctx), tenant, e); err != nil {
+		ctxLogger(ctx).Error("unable to record event", "type", e.Type, "task", t.ID, "error", err)
+	}
+}
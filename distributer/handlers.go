@@ -0,0 +1,1321 @@
+package distributer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// createTaskHandler will attempt to create and distribute a task to an agent.
+// The template parameter fills in the fields the task does not have with the
+// defaults of the template.
+func (s *Server) createTaskHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	taskPayload, err := createPayload(request.Body)
+	if err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	if name := request.URL.Query().Get("template"); name != "" {
+		tp, err := s.store.Template(request.Context(), tenant, name)
+		if err != nil {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Template %s is not present", name))
+			return
+		}
+		tp.apply(taskPayload, time.Now())
+	}
+	invalid, err := taskPayload.validate(request.Context(), s.store, tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to validate task"))
+		return
+	}
+	if len(invalid) > 0 {
+		formatError(writer, request, validationError(invalid...))
+		return
+	}
+	if startAt := taskPayload.StartAt; startAt != nil && startAt.After(time.Now()) {
+		taskPayload.StartAt = nil
+		s.createSchedule(writer, request, schedulePayload{Task: *taskPayload, StartAt: startAt})
+		return
+	}
+	taskPayload.StartAt = nil
+	t, err := s.distribute(request.Context(), tenant, *taskPayload)
+	if err != nil {
+		if errors.Is(err, errNoAgent) {
+			formatError(writer, request, newAPIError(http.StatusConflict, codeNoAgentAvailable, "%s", err.Error()))
+			return
+		}
+		formatError(writer, request, internalError(err, "Unable to assign task"))
+		return
+	}
+	success := struct {
+		Success bool `json:"success"`
+		Task    task `json:"task"`
+	}{
+		Success: true,
+		Task:    *t,
+	}
+	formatResponse(writer, success)
+}
+
+// distribute assigns the task of the payload to an agent and records its
+// event.
+func (s *Server) distribute(ctx context.Context, tenant string, p payload) (*task, error) {
+	t := &task{
+		store:  s.store,
+		tenant: tenant,
+	}
+	requested := task{Priorty: p.Priorty, Skills: p.Skills}
+	s.metrics.taskEvent(tenant, "created", requested)
+	start := time.Now()
+	err := t.assignTask(ctx, p)
+	s.metrics.assignmentDuration.observe(time.Since(start).Seconds(), tenant)
+	if err != nil {
+		s.metrics.taskEvent(tenant, "rejected", requested)
+		return nil, err
+	}
+	s.recordEvent(ctx, tenant, *t)
+	return t, nil
+}
+
+// statusTaskHandler will return the current status of the task.
+func (s *Server) statusTaskHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	taskID := pathParam(request, "id")
+	t := &task{
+		store:  s.store,
+		tenant: tenant,
+	}
+	err := t.retrieve(request.Context(), taskID)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+		return
+	}
+	if t.Comments, err = s.store.Comments(request.Context(), tenant, taskID); err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve comments"))
+		return
+	}
+	if t.Attachments, err = s.store.Attachments(request.Context(), tenant, taskID); err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve attachments"))
+		return
+	}
+	success := struct {
+		Success bool `json:"success"`
+		Task    task `json:"task"`
+	}{
+		Success: true,
+		Task:    *t,
+	}
+	formatResponse(writer, success)
+}
+
+// completeTaskHandler sets a task that is not complete or cancelled as
+// completed, with the result in the optional body.
+func (s *Server) completeTaskHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	taskID := pathParam(request, "id")
+	cp, ok := completion(writer, request)
+	if !ok {
+		return
+	}
+	t := &task{
+		store:  s.store,
+		tenant: tenant,
+	}
+	if err := t.retrieve(request.Context(), taskID); err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+		return
+	}
+	if !completeTransition.allowed(t.Status) {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeInvalidState, "Task %s can not complete while %s", taskID, t.Status))
+		return
+	}
+	updated, err := s.store.TransitionTask(request.Context(), tenant, taskID, t.Agent, completeTransition.from, completeTransition.to, cp.taskResult)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to update task"))
+		return
+	}
+	if !updated {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeConcurrentUpdate, "Task %s was changed by another request", taskID))
+		return
+	}
+	t.Status = statusComplete
+	t.taskResult = cp.taskResult
+	s.recordEvent(request.Context(), tenant, *t)
+
+	success := struct {
+		Success bool `json:"success"`
+	}{
+		Success: true,
+	}
+	formatResponse(writer, success)
+}
+
+// listTaskHandler will list the tasks, newest first, optionally only those
+// with one of the statuses or of an agent.
+func (s *Server) listTaskHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	query := request.URL.Query()
+	limit, err := queryLimit(query.Get("limit"), 100, 1000)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid limit %s", err.Error()))
+		return
+	}
+	filter := taskFilter{
+		Agent: query.Get("agent"),
+		Limit: limit,
+	}
+	for _, status := range query["status"] {
+		filter.Statuses = append(filter.Statuses, strings.Split(status, ",")...)
+	}
+	for _, tag := range query["tag"] {
+		filter.Tags = append(filter.Tags, strings.Split(tag, ",")...)
+	}
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if !validMetadataKey(key) {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid metadata key %s", key))
+			return
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = map[string]string{}
+		}
+		filter.Metadata[key] = values[0]
+	}
+	tasks, err := s.store.Tasks(request.Context(), tenant, filter)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve tasks"))
+		return
+	}
+	success := struct {
+		Success bool   `json:"success"`
+		Tasks   []task `json:"tasks"`
+	}{
+		Success: true,
+		Tasks:   tasks,
+	}
+	formatResponse(writer, success)
+}
+
+// cancelTaskHandler cancels a task that is not complete, which frees the agent
+// for another task.
+func (s *Server) cancelTaskHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	taskID := pathParam(request, "id")
+	t := &task{
+		store:  s.store,
+		tenant: tenant,
+	}
+	if err := t.retrieve(request.Context(), taskID); err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+		return
+	}
+	if !cancelTransition.allowed(t.Status) {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeInvalidState, "Task %s can not cancel while %s", taskID, t.Status))
+		return
+	}
+	updated, err := s.store.TransitionTask(request.Context(), tenant, taskID, t.Agent, cancelTransition.from, cancelTransition.to, t.taskResult)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to update task"))
+		return
+	}
+	if !updated {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeConcurrentUpdate, "Task %s was changed by another request", taskID))
+		return
+	}
+	if err := t.retrieve(request.Context(), taskID); err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+		return
+	}
+	s.recordEvent(request.Context(), tenant, *t)
+	success := struct {
+		Success bool `json:"success"`
+		Task    task `json:"task"`
+	}{
+		Success: true,
+		Task:    *t,
+	}
+	formatResponse(writer, success)
+}
+
+// listEventHandler will list the task events after an event id, oldest first,
+// so the events can be followed.
+func (s *Server) listEventHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	query := request.URL.Query()
+	var after int64
+	if value := query.Get("after"); value != "" {
+		var err error
+		if after, err = strconv.ParseInt(value, 10, 64); err != nil || after < 0 {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid after %s must be an event id", value))
+			return
+		}
+	}
+	limit, err := queryLimit(query.Get("limit"), 100, 1000)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid limit %s", err.Error()))
+		return
+	}
+	events, err := s.store.Events(request.Context(), tenant, after, limit)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve events"))
+		return
+	}
+	success := struct {
+		Success bool    `json:"success"`
+		Events  []event `json:"events"`
+	}{
+		Success: true,
+		Events:  events,
+	}
+	formatResponse(writer, success)
+}
+
+// queryLimit returns the limit query parameter, or the default when it is
+// not present.
+func queryLimit(value string, def, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", value, max)
+	}
+	return limit, nil
+}
+
+// validMetadataKey reports whether the metadata key of a filter is made of
+// letters, digits, underscores and dashes, so it is safe in a JSON path.
+func validMetadataKey(key string) bool {
+	return len(key) <= 100 && validCode(key)
+}
+
+// listAgentHandler will list the agents and what they are currently working on
+func (s *Server) listAgentHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	ats, err := s.store.AgentTasks(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve agents"))
+		return
+	}
+
+	success := struct {
+		Success    bool         `json:"success"`
+		AgentTasks []agentTasks `json:"agent_tasks"`
+	}{
+		Success:    true,
+		AgentTasks: ats,
+	}
+	formatResponse(writer, success)
+}
+
+// agentReportHandler will report the workload and performance of each agent,
+// or the agent parameter, in each window of the period as JSON or CSV.
+func (s *Server) agentReportHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	query := request.URL.Query()
+	now := time.Now()
+	period, err := parseReportPeriod(query, now)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid period %s", err.Error()))
+		return
+	}
+	asCSV, err := reportFormat(query)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid %s", err.Error()))
+		return
+	}
+	ats, err := s.store.AgentTasks(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve agents"))
+		return
+	}
+	agentID := query.Get("agent")
+	agents := []agent{}
+	for _, at := range ats {
+		if agentID == "" || at.ID == agentID {
+			agents = append(agents, at.agent)
+		}
+	}
+	if agentID != "" && len(agents) == 0 {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Agent %s is not present", agentID))
+		return
+	}
+	tasks, err := s.store.Tasks(request.Context(), tenant, taskFilter{Agent: agentID, ActiveFrom: period.From, ActiveTo: period.To})
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve tasks"))
+		return
+	}
+	priorities, err := s.store.Priorities(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve priorities"))
+		return
+	}
+	levels := make(map[string]int, len(priorities))
+	for _, p := range priorities {
+		levels[p.Priority] = p.Level
+	}
+	reports := agentReports(agents, tasks, levels, period, now)
+	if asCSV {
+		rows := make([][]string, 0, len(reports))
+		for _, r := range reports {
+			rows = append(rows, r.row())
+		}
+		formatCSV(writer, "agents.csv", agentReportHeader, rows)
+		return
+	}
+	success := struct {
+		Success bool          `json:"success"`
+		From    time.Time     `json:"from"`
+		To      time.Time     `json:"to"`
+		Window  string        `json:"window"`
+		Agents  []agentReport `json:"agents"`
+	}{
+		Success: true,
+		From:    period.From,
+		To:      period.To,
+		Window:  period.Window.String(),
+		Agents:  reports,
+	}
+	formatResponse(writer, success)
+}
+
+// queueReportHandler will report the tasks created, completed and waiting of
+// each skill and priority in each window of the period as JSON or CSV.
+func (s *Server) queueReportHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	query := request.URL.Query()
+	now := time.Now()
+	period, err := parseReportPeriod(query, now)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid period %s", err.Error()))
+		return
+	}
+	asCSV, err := reportFormat(query)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid %s", err.Error()))
+		return
+	}
+	tasks, err := s.store.Tasks(request.Context(), tenant, taskFilter{ActiveFrom: period.From, ActiveTo: period.To})
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve tasks"))
+		return
+	}
+	// The events of the tasks open at the start of the period were recorded
+	// before it.
+	since := period.From
+	for _, t := range tasks {
+		if t.StartTime.Before(since) {
+			since = t.StartTime
+		}
+	}
+	events, err := s.store.EventsBetween(request.Context(), tenant, since, period.To)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve events"))
+		return
+	}
+	reports := queueReports(tasks, events, period, now)
+	if asCSV {
+		rows := make([][]string, 0, len(reports))
+		for _, r := range reports {
+			rows = append(rows, r.row())
+		}
+		formatCSV(writer, "queues.csv", queueReportHeader, rows)
+		return
+	}
+	success := struct {
+		Success bool          `json:"success"`
+		From    time.Time     `json:"from"`
+		To      time.Time     `json:"to"`
+		Window  string        `json:"window"`
+		Queues  []queueReport `json:"queues"`
+	}{
+		Success: true,
+		From:    period.From,
+		To:      period.To,
+		Window:  period.Window.String(),
+		Queues:  reports,
+	}
+	formatResponse(writer, success)
+}
+
+// skillReportHandler will report how many agents have each skill, or the
+// skills with at most the max_agents parameter, and the open tasks that
+// require it as JSON or CSV.
+func (s *Server) skillReportHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	query := request.URL.Query()
+	maxAgents := -1
+	if value := query.Get("max_agents"); value != "" {
+		var err error
+		if maxAgents, err = strconv.Atoi(value); err != nil || maxAgents < 0 {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid max_agents %s must be a number of at least 0", value))
+			return
+		}
+	}
+	asCSV, err := reportFormat(query)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidParameter, "Invalid %s", err.Error()))
+		return
+	}
+	skills, err := s.store.Skills(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve skills"))
+		return
+	}
+	agents, err := s.store.SkillAgents(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve agents"))
+		return
+	}
+	open, err := s.store.Tasks(request.Context(), tenant, taskFilter{Statuses: openStatuses})
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve tasks"))
+		return
+	}
+	coverages := skillCoverages(skills, agents, open, maxAgents)
+	if asCSV {
+		rows := make([][]string, 0, len(coverages))
+		for _, c := range coverages {
+			rows = append(rows, c.row())
+		}
+		formatCSV(writer, "skills.csv", skillCoverageHeader, rows)
+		return
+	}
+	success := struct {
+		Success bool            `json:"success"`
+		Skills  []skillCoverage `json:"skills"`
+	}{
+		Success: true,
+		Skills:  coverages,
+	}
+	formatResponse(writer, success)
+}
+
+// agentTasksHandler will list the tasks the agent is currently working on.
+func (s *Server) agentTasksHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	agentID := pathParam(request, "id")
+	if _, err := s.store.Agents(request.Context(), tenant, []string{agentID}); err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Agent %s is not present", agentID))
+		return
+	}
+	tasks, err := s.store.TasksByAgent(request.Context(), tenant, agentID)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve tasks"))
+		return
+	}
+	success := struct {
+		Success bool   `json:"success"`
+		Tasks   []task `json:"tasks"`
+	}{
+		Success: true,
+		Tasks:   tasks,
+	}
+	formatResponse(writer, success)
+}
+
+// agentTaskActionHandler returns a handler that will accept, start or complete
+// a task assigned to the agent.
+func (s *Server) agentTaskActionHandler(action string) http.HandlerFunc {
+	transition := agentActions[action]
+	return func(writer http.ResponseWriter, request *http.Request) {
+		tenant := requestPrincipal(request).Tenant
+		agentID := pathParam(request, "id")
+		taskID := pathParam(request, "task")
+		var result taskResult
+		if transition.to == statusComplete {
+			cp, ok := completion(writer, request)
+			if !ok {
+				return
+			}
+			result = cp.taskResult
+		}
+		t := &task{
+			store:  s.store,
+			tenant: tenant,
+		}
+		if err := t.retrieve(request.Context(), taskID); err != nil {
+			formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+			return
+		}
+		if t.Agent != agentID {
+			formatError(writer, request, newAPIError(http.StatusForbidden, codeForbidden, "Task %s is not assigned to agent %s", taskID, agentID))
+			return
+		}
+		if !transition.allowed(t.Status) {
+			formatError(writer, request, newAPIError(http.StatusConflict, codeInvalidState, "Task %s can not %s while %s", taskID, action, t.Status))
+			return
+		}
+		updated, err := s.store.TransitionTask(request.Context(), tenant, taskID, agentID, transition.from, transition.to, result)
+		if err != nil {
+			formatError(writer, request, internalError(err, "Unable to update task"))
+			return
+		}
+		if !updated {
+			formatError(writer, request, newAPIError(http.StatusConflict, codeConcurrentUpdate, "Task %s was changed by another request", taskID))
+			return
+		}
+		if err := t.retrieve(request.Context(), taskID); err != nil {
+			formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+			return
+		}
+		s.recordEvent(request.Context(), tenant, *t)
+		success := struct {
+			Success bool `json:"success"`
+			Task    task `json:"task"`
+		}{
+			Success: true,
+			Task:    *t,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// completion decodes and validates the optional result of completing a task,
+// writing the error when it is not valid.
+func completion(writer http.ResponseWriter, request *http.Request) (*completePayload, bool) {
+	cp, err := createCompletePayload(request.Body)
+	if err != nil {
+		formatError(writer, request, payloadError(err))
+		return nil, false
+	}
+	if invalid := cp.validate(); len(invalid) > 0 {
+		formatError(writer, request, validationError(invalid...))
+		return nil, false
+	}
+	return cp, true
+}
+
+// routeTask returns the task of a comments or attachments route, writing the
+// error when it is not present.  On the agent routes the task must be the
+// agent's.
+func (s *Server) routeTask(writer http.ResponseWriter, request *http.Request, agentRoute bool) (*task, bool) {
+	taskID := pathParam(request, "id")
+	if agentRoute {
+		taskID = pathParam(request, "task")
+	}
+	t := &task{
+		store:  s.store,
+		tenant: requestPrincipal(request).Tenant,
+	}
+	if err := t.retrieve(request.Context(), taskID); err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Task %s is not present", taskID))
+		return nil, false
+	}
+	if agentID := pathParam(request, "id"); agentRoute && t.Agent != agentID {
+		formatError(writer, request, newAPIError(http.StatusForbidden, codeForbidden, "Task %s is not assigned to agent %s", taskID, agentID))
+		return nil, false
+	}
+	return t, true
+}
+
+// listCommentsHandler returns a handler that will list the comments of a
+// task, oldest first.
+func (s *Server) listCommentsHandler(agentRoute bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		t, ok := s.routeTask(writer, request, agentRoute)
+		if !ok {
+			return
+		}
+		comments, err := s.store.Comments(request.Context(), t.tenant, t.ID)
+		if err != nil {
+			formatError(writer, request, internalError(err, "Unable to retrieve comments"))
+			return
+		}
+		success := struct {
+			Success  bool      `json:"success"`
+			Comments []comment `json:"comments"`
+		}{
+			Success:  true,
+			Comments: comments,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// createCommentHandler returns a handler that will add a comment to a task, by
+// who the request was authenticated as.
+func (s *Server) createCommentHandler(agentRoute bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var p commentPayload
+		if err := decodePayload(request.Body, &p); err != nil {
+			formatError(writer, request, payloadError(err))
+			return
+		}
+		if invalid := p.validate(); len(invalid) > 0 {
+			formatError(writer, request, validationError(invalid...))
+			return
+		}
+		t, ok := s.routeTask(writer, request, agentRoute)
+		if !ok {
+			return
+		}
+		author := requestPrincipal(request)
+		c := comment{
+			ID:         xid.New().String(),
+			Task:       t.ID,
+			Author:     author.Subject,
+			Role:       author.Role,
+			Agent:      author.Agent,
+			Body:       p.Body,
+			CreateTime: time.Now(),
+		}
+		if err := s.store.CreateComment(request.Context(), t.tenant, c); err != nil {
+			formatError(writer, request, internalError(err, "Unable to create comment"))
+			return
+		}
+		success := struct {
+			Success bool    `json:"success"`
+			Comment comment `json:"comment"`
+		}{
+			Success: true,
+			Comment: c,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// listAttachmentsHandler returns a handler that will list the attachments of
+// a task, oldest first.
+func (s *Server) listAttachmentsHandler(agentRoute bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		t, ok := s.routeTask(writer, request, agentRoute)
+		if !ok {
+			return
+		}
+		attachments, err := s.store.Attachments(request.Context(), t.tenant, t.ID)
+		if err != nil {
+			formatError(writer, request, internalError(err, "Unable to retrieve attachments"))
+			return
+		}
+		success := struct {
+			Success     bool         `json:"success"`
+			Attachments []attachment `json:"attachments"`
+		}{
+			Success:     true,
+			Attachments: attachments,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// uploadAttachmentHandler returns a handler that will attach the body to a
+// task, with the file name in the name parameter.  The Content-Type must be
+// one of the allowed types and match the contents, and the optional
+// X-Checksum-SHA256 header must be the hex SHA-256 of the body.
+func (s *Server) uploadAttachmentHandler(agentRoute bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		v := &validator{}
+		name := v.fileName("name", request.URL.Query().Get("name"))
+		if len(v.invalid) > 0 {
+			formatError(writer, request, validationError(v.invalid...))
+			return
+		}
+		contentType := mediaType(request.Header.Get("Content-Type"))
+		if !slices.Contains(s.config.AttachmentTypes, contentType) {
+			formatError(writer, request, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Content type %q is not allowed, use one of %s", contentType, strings.Join(s.config.AttachmentTypes, ", ")))
+			return
+		}
+		max := s.config.MaxAttachmentSize
+		if request.ContentLength > max {
+			formatError(writer, request, newAPIError(http.StatusRequestEntityTooLarge, codePayloadTooLarge, "Attachment must be at most %d bytes", max))
+			return
+		}
+		t, ok := s.routeTask(writer, request, agentRoute)
+		if !ok {
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(request.Body, max+1))
+		if err != nil {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidPayload, "Unable to read attachment %s", err.Error()))
+			return
+		}
+		if int64(len(data)) > max {
+			formatError(writer, request, newAPIError(http.StatusRequestEntityTooLarge, codePayloadTooLarge, "Attachment must be at most %d bytes", max))
+			return
+		}
+		if len(data) == 0 {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeInvalidPayload, "Attachment must not be empty"))
+			return
+		}
+		if !sniffedAs(data, contentType) {
+			formatError(writer, request, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Attachment contents are not %s", contentType))
+			return
+		}
+		sum := sha256.Sum256(data)
+		checksum := hex.EncodeToString(sum[:])
+		if expected := request.Header.Get(checksumHeader); expected != "" && !strings.EqualFold(expected, checksum) {
+			formatError(writer, request, newAPIError(http.StatusBadRequest, codeChecksumMismatch, "Attachment SHA-256 is %s, not %s", checksum, expected))
+			return
+		}
+		a := attachment{
+			ID:          xid.New().String(),
+			Task:        t.ID,
+			Name:        name,
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			SHA256:      checksum,
+			Author:      requestPrincipal(request).Subject,
+			CreateTime:  time.Now(),
+		}
+		if err := s.blobs.Put(request.Context(), a.blobKey(t.tenant), contentType, data); err != nil {
+			formatError(writer, request, internalError(err, "Unable to store attachment"))
+			return
+		}
+		if err := s.store.CreateAttachment(request.Context(), t.tenant, a); err != nil {
+			// The contents are not referenced without the attachment.
+			s.blobs.Delete(context.WithoutCancel(request.Context()), a.blobKey(t.tenant))
+			formatError(writer, request, internalError(err, "Unable to create attachment"))
+			return
+		}
+		success := struct {
+			Success    bool       `json:"success"`
+			Attachment attachment `json:"attachment"`
+		}{
+			Success:    true,
+			Attachment: a,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// downloadAttachmentHandler returns a handler that will send the contents of
+// an attachment, once their checksum is verified.
+func (s *Server) downloadAttachmentHandler(agentRoute bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		t, ok := s.routeTask(writer, request, agentRoute)
+		if !ok {
+			return
+		}
+		id := pathParam(request, "attachment")
+		a, err := s.store.Attachment(request.Context(), t.tenant, t.ID, id)
+		if err != nil {
+			formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Attachment %s is not present", id))
+			return
+		}
+		data, err := s.blobs.Get(request.Context(), a.blobKey(t.tenant))
+		if err != nil {
+			formatError(writer, request, internalError(err, "Unable to retrieve attachment %s", id))
+			return
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != a.SHA256 {
+			formatError(writer, request, internalError(fmt.Errorf("attachment %s has SHA-256 %x, not %s", id, sum, a.SHA256), "Attachment %s is corrupted", id))
+			return
+		}
+		header := writer.Header()
+		header.Set("Content-Type", a.ContentType)
+		header.Set("Content-Length", strconv.Itoa(len(data)))
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set(checksumHeader, a.SHA256)
+		header.Set("ETag", `"`+a.SHA256+`"`)
+		writer.Write(data)
+	}
+}
+
+// listScheduleHandler will list the schedules, oldest first.
+func (s *Server) listScheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	schedules, err := s.store.Schedules(request.Context(), requestPrincipal(request).Tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve schedules"))
+		return
+	}
+	success := struct {
+		Success   bool       `json:"success"`
+		Schedules []schedule `json:"schedules"`
+	}{
+		Success:   true,
+		Schedules: schedules,
+	}
+	formatResponse(writer, success)
+}
+
+// createScheduleHandler will add a schedule of a task, held until its start
+// time or recurring on a cron expression.
+func (s *Server) createScheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	var p schedulePayload
+	if err := decodePayload(request.Body, &p); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	invalid, err := p.validate(request.Context(), s.store, requestPrincipal(request).Tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to validate schedule"))
+		return
+	}
+	if len(invalid) > 0 {
+		formatError(writer, request, validationError(invalid...))
+		return
+	}
+	s.createSchedule(writer, request, p)
+}
+
+// createSchedule adds the schedule of the validated payload.
+func (s *Server) createSchedule(writer http.ResponseWriter, request *http.Request, p schedulePayload) {
+	principal := requestPrincipal(request)
+	now := time.Now().UTC().Truncate(time.Second)
+	sc := schedule{
+		ID:         xid.New().String(),
+		Task:       p.Task,
+		Cron:       p.Cron,
+		Author:     principal.Subject,
+		CreateTime: now,
+	}
+	if p.StartAt != nil {
+		startAt := p.StartAt.UTC().Truncate(time.Second)
+		sc.StartAt = &startAt
+	}
+	sc.NextRun = sc.firstRun(now)
+	if err := s.store.CreateSchedule(request.Context(), principal.Tenant, sc); err != nil {
+		formatError(writer, request, internalError(err, "Unable to create schedule"))
+		return
+	}
+	success := struct {
+		Success  bool     `json:"success"`
+		Schedule schedule `json:"schedule"`
+	}{
+		Success:  true,
+		Schedule: sc,
+	}
+	formatResponse(writer, success)
+}
+
+// scheduleHandler will return the schedule with its last run.
+func (s *Server) scheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	sc, ok := s.routeSchedule(writer, request)
+	if !ok {
+		return
+	}
+	success := struct {
+		Success  bool     `json:"success"`
+		Schedule schedule `json:"schedule"`
+	}{
+		Success:  true,
+		Schedule: sc,
+	}
+	formatResponse(writer, success)
+}
+
+// routeSchedule returns the schedule of the route, writing the error when it
+// is not present.
+func (s *Server) routeSchedule(writer http.ResponseWriter, request *http.Request) (schedule, bool) {
+	id := pathParam(request, "id")
+	sc, err := s.store.Schedule(request.Context(), requestPrincipal(request).Tenant, id)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Schedule %s is not present", id))
+		return schedule{}, false
+	}
+	return sc, true
+}
+
+// pauseScheduleHandler returns a handler that will pause, or resume, a
+// schedule.  A resumed schedule is due at the next time of its cron
+// expression, or its start time if its task was not distributed yet.
+func (s *Server) pauseScheduleHandler(paused bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sc, ok := s.routeSchedule(writer, request)
+		if !ok {
+			return
+		}
+		if !paused && sc.Paused {
+			sc.NextRun = sc.firstRun(time.Now().UTC().Truncate(time.Second))
+		}
+		sc.Paused = paused
+		updated, err := s.store.SetSchedulePaused(request.Context(), requestPrincipal(request).Tenant, sc.ID, sc.Paused, sc.NextRun)
+		if err != nil {
+			formatError(writer, request, internalError(err, "Unable to update schedule"))
+			return
+		}
+		if !updated {
+			formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Schedule %s is not present", sc.ID))
+			return
+		}
+		success := struct {
+			Success  bool     `json:"success"`
+			Schedule schedule `json:"schedule"`
+		}{
+			Success:  true,
+			Schedule: sc,
+		}
+		formatResponse(writer, success)
+	}
+}
+
+// deleteScheduleHandler will delete a schedule, the tasks it distributed are
+// not changed.
+func (s *Server) deleteScheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	id := pathParam(request, "id")
+	deleted, err := s.store.DeleteSchedule(request.Context(), requestPrincipal(request).Tenant, id)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to delete schedule"))
+		return
+	}
+	if !deleted {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Schedule %s is not present", id))
+		return
+	}
+	success := struct {
+		Success bool `json:"success"`
+	}{
+		Success: true,
+	}
+	formatResponse(writer, success)
+}
+
+// listTemplateHandler will list the templates, by name.
+func (s *Server) listTemplateHandler(writer http.ResponseWriter, request *http.Request) {
+	templates, err := s.store.Templates(request.Context(), requestPrincipal(request).Tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve templates"))
+		return
+	}
+	success := struct {
+		Success   bool       `json:"success"`
+		Templates []template `json:"templates"`
+	}{
+		Success:   true,
+		Templates: templates,
+	}
+	formatResponse(writer, success)
+}
+
+// createTemplateHandler will add a template of the defaults of a kind of
+// task.
+func (s *Server) createTemplateHandler(writer http.ResponseWriter, request *http.Request) {
+	principal := requestPrincipal(request)
+	var p templatePayload
+	if err := decodePayload(request.Body, &p); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	invalid, err := p.validate(request.Context(), s.store, principal.Tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to validate template"))
+		return
+	}
+	if len(invalid) > 0 {
+		formatError(writer, request, validationError(invalid...))
+		return
+	}
+	if _, err := s.store.Template(request.Context(), principal.Tenant, p.Name); err == nil {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeAlreadyExists, "Template %s is already present", p.Name))
+		return
+	}
+	tp := template{
+		Name:             p.Name,
+		templateDefaults: p.templateDefaults,
+		Author:           principal.Subject,
+		CreateTime:       time.Now().UTC().Truncate(time.Second),
+	}
+	if err := s.store.CreateTemplate(request.Context(), principal.Tenant, tp); err != nil {
+		formatError(writer, request, internalError(err, "Unable to create template"))
+		return
+	}
+	success := struct {
+		Success  bool     `json:"success"`
+		Template template `json:"template"`
+	}{
+		Success:  true,
+		Template: tp,
+	}
+	formatResponse(writer, success)
+}
+
+// templateHandler will return the template.
+func (s *Server) templateHandler(writer http.ResponseWriter, request *http.Request) {
+	name := pathParam(request, "name")
+	tp, err := s.store.Template(request.Context(), requestPrincipal(request).Tenant, name)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Template %s is not present", name))
+		return
+	}
+	success := struct {
+		Success  bool     `json:"success"`
+		Template template `json:"template"`
+	}{
+		Success:  true,
+		Template: tp,
+	}
+	formatResponse(writer, success)
+}
+
+// deleteTemplateHandler will delete a template, the tasks created with it are
+// not changed.
+func (s *Server) deleteTemplateHandler(writer http.ResponseWriter, request *http.Request) {
+	name := pathParam(request, "name")
+	deleted, err := s.store.DeleteTemplate(request.Context(), requestPrincipal(request).Tenant, name)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to delete template"))
+		return
+	}
+	if !deleted {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Template %s is not present", name))
+		return
+	}
+	success := struct {
+		Success bool `json:"success"`
+	}{
+		Success: true,
+	}
+	formatResponse(writer, success)
+}
+
+// createAgentHandler will add an agent with their skills.
+func (s *Server) createAgentHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	var a agent
+	if err := decodePayload(request.Body, &a); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	v := &validator{}
+	a.ID = v.text("id", a.ID, maxAgentIDLength)
+	a.FirstName = v.text("first_name", a.FirstName, maxAgentNameLength)
+	a.LastName = v.text("last_name", a.LastName, maxAgentNameLength)
+	a.Skills = v.skills("skills", a.Skills, false)
+	if err := v.knownSkills(request.Context(), s.store, tenant, "skills", a.Skills); err != nil {
+		formatError(writer, request, internalError(err, "Unable to validate agent"))
+		return
+	}
+	if len(v.invalid) > 0 {
+		formatError(writer, request, validationError(v.invalid...))
+		return
+	}
+	if _, err := s.store.Agents(request.Context(), tenant, []string{a.ID}); err == nil {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeAlreadyExists, "Agent %s is already present", a.ID))
+		return
+	}
+	if err := s.store.CreateAgent(request.Context(), tenant, a); err != nil {
+		formatError(writer, request, internalError(err, "Unable to create agent"))
+		return
+	}
+	success := struct {
+		Success bool  `json:"success"`
+		Agent   agent `json:"agent"`
+	}{
+		Success: true,
+		Agent:   a,
+	}
+	formatResponse(writer, success)
+}
+
+// updateAgentSkillsHandler will replace the skills of an agent.
+func (s *Server) updateAgentSkillsHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	agentID := pathParam(request, "id")
+	var p payload
+	if err := decodePayload(request.Body, &p); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	v := &validator{}
+	if p.Skills == nil {
+		v.add("skills", fieldRequired, "skills field must be present")
+	}
+	p.Skills = v.skills("skills", p.Skills, false)
+	if err := v.knownSkills(request.Context(), s.store, tenant, "skills", p.Skills); err != nil {
+		formatError(writer, request, internalError(err, "Unable to validate agent"))
+		return
+	}
+	if len(v.invalid) > 0 {
+		formatError(writer, request, validationError(v.invalid...))
+		return
+	}
+	agts, err := s.store.Agents(request.Context(), tenant, []string{agentID})
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Agent %s is not present", agentID))
+		return
+	}
+	if err := s.store.UpdateAgentSkills(request.Context(), tenant, agentID, p.Skills); err != nil {
+		formatError(writer, request, internalError(err, "Unable to update agent"))
+		return
+	}
+	a := agts[0]
+	a.Skills = p.Skills
+	success := struct {
+		Success bool  `json:"success"`
+		Agent   agent `json:"agent"`
+	}{
+		Success: true,
+		Agent:   a,
+	}
+	formatResponse(writer, success)
+}
+
+// listSkillHandler will list the skills an agent can have.
+func (s *Server) listSkillHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	skills, err := s.store.Skills(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve skills"))
+		return
+	}
+	success := struct {
+		Success bool    `json:"success"`
+		Skills  []skill `json:"skills"`
+	}{
+		Success: true,
+		Skills:  skills,
+	}
+	formatResponse(writer, success)
+}
+
+// createSkillHandler will add a skill.
+func (s *Server) createSkillHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	var sk skill
+	if err := decodePayload(request.Body, &sk); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	v := &validator{}
+	sk.Skill = v.text("skill", sk.Skill, maxSkillLength)
+	sk.Description = v.text("description", sk.Description, maxDescriptionLength)
+	if len(v.invalid) > 0 {
+		formatError(writer, request, validationError(v.invalid...))
+		return
+	}
+	if count, err := s.store.SkillCount(request.Context(), tenant, []string{sk.Skill}); err == nil && count > 0 {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeAlreadyExists, "Skill %s is already present", sk.Skill))
+		return
+	}
+	if err := s.store.CreateSkill(request.Context(), tenant, sk); err != nil {
+		formatError(writer, request, internalError(err, "Unable to create skill"))
+		return
+	}
+	success := struct {
+		Success bool  `json:"success"`
+		Skill   skill `json:"skill"`
+	}{
+		Success: true,
+		Skill:   sk,
+	}
+	formatResponse(writer, success)
+}
+
+// listPriorityHandler will list the priorities a task can have.
+func (s *Server) listPriorityHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	priorities, err := s.store.Priorities(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve priorities"))
+		return
+	}
+	success := struct {
+		Success    bool       `json:"success"`
+		Priorities []priority `json:"priorities"`
+	}{
+		Success:    true,
+		Priorities: priorities,
+	}
+	formatResponse(writer, success)
+}
+
+// createPriorityHandler will add a priority.
+func (s *Server) createPriorityHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	var p priority
+	if err := decodePayload(request.Body, &p); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	v := &validator{}
+	if p.Priority = v.text("priority", p.Priority, maxPriorityLength); len(v.invalid) > 0 {
+		formatError(writer, request, validationError(v.invalid...))
+		return
+	}
+	if _, err := s.store.PriorityLevel(request.Context(), tenant, p.Priority); err == nil {
+		formatError(writer, request, newAPIError(http.StatusConflict, codeAlreadyExists, "Priority %s is already present", p.Priority))
+		return
+	}
+	if err := s.store.CreatePriority(request.Context(), tenant, p); err != nil {
+		formatError(writer, request, internalError(err, "Unable to create priority"))
+		return
+	}
+	success := struct {
+		Success  bool     `json:"success"`
+		Priority priority `json:"priority"`
+	}{
+		Success:  true,
+		Priority: p,
+	}
+	formatResponse(writer, success)
+}
+
+// createAPIKeyHandler will issue an API key.  The key is only ever returned here.
+func (s *Server) createAPIKeyHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	var p apiKey
+	if err := decodePayload(request.Body, &p); err != nil {
+		formatError(writer, request, payloadError(err))
+		return
+	}
+	key, err := s.issueAPIKey(request.Context(), tenant, p.Name, p.Role, p.Agent)
+	if err != nil {
+		formatError(writer, request, newAPIError(http.StatusBadRequest, codeValidationFailed, "Unable to issue API key %s", err.Error()))
+		return
+	}
+	success := struct {
+		Success bool   `json:"success"`
+		APIKey  apiKey `json:"api_key"`
+	}{
+		Success: true,
+		APIKey:  key,
+	}
+	formatResponse(writer, success)
+}
+
+// listAPIKeyHandler will list the issued API keys, without the keys themselves.
+func (s *Server) listAPIKeyHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	keys, err := s.store.APIKeys(request.Context(), tenant)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to retrieve API keys"))
+		return
+	}
+	success := struct {
+		Success bool     `json:"success"`
+		APIKeys []apiKey `json:"api_keys"`
+	}{
+		Success: true,
+		APIKeys: keys,
+	}
+	formatResponse(writer, success)
+}
+
+// revokeAPIKeyHandler will revoke an API key.
+func (s *Server) revokeAPIKeyHandler(writer http.ResponseWriter, request *http.Request) {
+	tenant := requestPrincipal(request).Tenant
+	keyID := pathParam(request, "id")
+	revoked, err := s.store.RevokeAPIKey(request.Context(), tenant, keyID)
+	if err != nil {
+		formatError(writer, request, internalError(err, "Unable to revoke API key"))
+		return
+	}
+	if !revoked {
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "API key %s is not present", keyID))
+		return
+	}
+	success := struct {
+		Success bool `json:"success"`
+	}{
+		Success: true,
+	}
+	formatResponse(writer, success)
+}
@@ -0,0 +1,985 @@
+package distributer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server of a seeded memory store.
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer(newTestStore(t), Config{Blobs: newLocalBlobStore(t.TempDir()), MaxAttachmentSize: 1 << 10})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	return s
+}
+
+// serveTest sends the request to the server with the key and returns the
+// recorded response.
+func serveTest(s *Server, method, path, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		request.Header.Set("X-API-Key", key)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// testAPIKey issues a key in the default tenant of the server.
+func testAPIKey(t *testing.T, s *Server, role, agentID string) string {
+	k, err := s.issueAPIKey(context.Background(), DefaultTenant, "test", role, agentID)
+	if err != nil {
+		t.Fatalf("issueAPIKey() error = %v", err)
+	}
+	return k.Key
+}
+
+func Test_taskHandlers(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	agent1000 := testAPIKey(t, s, RoleAgent, "1000")
+	agent1003 := testAPIKey(t, s, RoleAgent, "1003")
+
+	resp := serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("create task status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	var created struct {
+		Task task `json:"task"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create task response %v", err)
+	}
+	if created.Task.Agent != "1000" || created.Task.Status != statusAssigned {
+		t.Fatalf("create task = %v, want assigned to 1000", created.Task)
+	}
+	taskPath := "/v1/agent/1000/tasks/" + created.Task.ID
+
+	resp = serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	var cancelled struct {
+		Task task `json:"task"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &cancelled); err != nil {
+		t.Fatalf("create task response %v", err)
+	}
+	if resp := serveTest(s, http.MethodPost, "/v1/task/"+cancelled.Task.ID+"/cancel", submitter, ""); resp.Code != http.StatusOK {
+		t.Fatalf("cancel task status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Missing key",
+			method:     http.MethodGet,
+			path:       "/v1/task/" + created.Task.ID,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Task status",
+			method:     http.MethodGet,
+			path:       "/v1/task/" + created.Task.ID,
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"Assigned"`,
+		},
+		{
+			name:       "Unknown task",
+			method:     http.MethodGet,
+			path:       "/v1/task/unknown",
+			key:        submitter,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Missing skill",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"name":"Test Name","skills":["skill9"],"priority":"low"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Create task with details",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			key:        submitter,
+			body:       `{"name":"Test Name","skills":["skill2"],"priority":"low","description":"Test Description","metadata":{"order":"12"},"tags":["billing"],"external_url":"https://example.com/tickets/12"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"description":"Test Description","metadata":{"order":"12"},"tags":["billing"],"external_url":"https://example.com/tickets/12"`,
+		},
+		{
+			name:       "List tasks by tag and metadata",
+			method:     http.MethodGet,
+			path:       "/v1/task?tag=billing&metadata.order=12",
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   `"tags":["billing"]`,
+		},
+		{
+			name:       "Invalid metadata key",
+			method:     http.MethodGet,
+			path:       "/v1/task?metadata.a.b=12",
+			key:        submitter,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Submitter can not create agents",
+			method:     http.MethodPost,
+			path:       "/v1/agent",
+			key:        submitter,
+			body:       `{"id":"2000","first_name":"Test","last_name":"Agent"}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "List agents",
+			method:     http.MethodGet,
+			path:       "/v1/agent",
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   created.Task.ID,
+		},
+		{
+			name:       "Other agent's tasks",
+			method:     http.MethodGet,
+			path:       "/v1/agent/1000/tasks",
+			key:        agent1003,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Accept",
+			method:     http.MethodPost,
+			path:       taskPath + "/accept",
+			key:        agent1000,
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"Accepted"`,
+		},
+		{
+			name:       "Accept twice",
+			method:     http.MethodPost,
+			path:       taskPath + "/accept",
+			key:        agent1000,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Start",
+			method:     http.MethodPost,
+			path:       taskPath + "/start",
+			key:        agent1000,
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"Started"`,
+		},
+		{
+			name:       "Comment",
+			method:     http.MethodPost,
+			path:       taskPath + "/comments",
+			key:        agent1000,
+			body:       `{"body":"Called the customer"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"role":"agent","agent":"1000","body":"Called the customer"`,
+		},
+		{
+			name:       "Comment another agent's task",
+			method:     http.MethodGet,
+			path:       "/v1/agent/1003/tasks/" + created.Task.ID + "/comments",
+			key:        agent1003,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Empty comment",
+			method:     http.MethodPost,
+			path:       "/v1/task/" + created.Task.ID + "/comments",
+			key:        submitter,
+			body:       `{"body":" "}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"field":"body","code":"required"`,
+		},
+		{
+			name:       "Submitter comment",
+			method:     http.MethodPost,
+			path:       "/v1/task/" + created.Task.ID + "/comments",
+			key:        submitter,
+			body:       `{"body":"Thanks"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"role":"submitter"`,
+		},
+		{
+			name:       "List comments",
+			method:     http.MethodGet,
+			path:       taskPath + "/comments",
+			key:        agent1000,
+			wantStatus: http.StatusOK,
+			wantBody:   `"body":"Thanks"`,
+		},
+		{
+			name:       "Complete with an invalid outcome",
+			method:     http.MethodPost,
+			path:       taskPath + "/complete",
+			key:        agent1000,
+			body:       `{"outcome":"not resolved"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"field":"outcome"`,
+		},
+		{
+			name:       "Complete with result",
+			method:     http.MethodPost,
+			path:       taskPath + "/complete",
+			key:        agent1000,
+			body:       `{"outcome":"resolved","result":"done","result_data":{"calls":1}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"outcome":"resolved","result":"done","result_data":{"calls":1}`,
+		},
+		{
+			name:       "Task status with result and comments",
+			method:     http.MethodGet,
+			path:       "/v1/task/" + created.Task.ID,
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   `"result_data":{"calls":1},"comments":[{`,
+		},
+		{
+			name:       "No open tasks",
+			method:     http.MethodGet,
+			path:       "/v1/agent/1000/tasks",
+			key:        agent1000,
+			wantStatus: http.StatusOK,
+			wantBody:   `"tasks":[]`,
+		},
+		{
+			name:       "Complete a completed task",
+			method:     http.MethodPost,
+			path:       "/v1/task/" + created.Task.ID + "/complete",
+			key:        submitter,
+			body:       `{"outcome":"failed"}`,
+			wantStatus: http.StatusConflict,
+			wantBody:   `"code":"invalid_state"`,
+		},
+		{
+			name:       "Complete a cancelled task",
+			method:     http.MethodPost,
+			path:       "/v1/task/" + cancelled.Task.ID + "/complete",
+			key:        submitter,
+			wantStatus: http.StatusConflict,
+			wantBody:   `"code":"invalid_state"`,
+		},
+		{
+			name:       "Cancel complete task",
+			method:     http.MethodPost,
+			path:       "/v1/task/" + created.Task.ID + "/cancel",
+			key:        submitter,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "List complete tasks",
+			method:     http.MethodGet,
+			path:       "/v1/task?status=Complete&agent=1000",
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":"` + created.Task.ID + `"`,
+		},
+		{
+			name:       "List tasks over the limit",
+			method:     http.MethodGet,
+			path:       "/v1/task?limit=1001",
+			key:        submitter,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Events after the first",
+			method:     http.MethodGet,
+			path:       "/v1/event?after=1",
+			key:        submitter,
+			wantStatus: http.StatusOK,
+			wantBody:   `"type":"task.accepted"`,
+		},
+		{
+			name:       "Agents can not list events",
+			method:     http.MethodGet,
+			path:       "/v1/event",
+			key:        agent1000,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, tt.method, tt.path, tt.key, tt.body)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %v, want %v %s", tt.method, tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %s", tt.method, tt.path, resp.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_attachmentHandlers(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	agent1000 := testAPIKey(t, s, RoleAgent, "1000")
+	agent1003 := testAPIKey(t, s, RoleAgent, "1003")
+
+	resp := serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	var created struct {
+		Task task `json:"task"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil || created.Task.Agent != "1000" {
+		t.Fatalf("create task = %s, want assigned to 1000", resp.Body.String())
+	}
+	taskPath := "/v1/task/" + created.Task.ID
+	agentPath := "/v1/agent/1000/tasks/" + created.Task.ID
+	notes := "order,12\n"
+	notesSum := sha256.Sum256([]byte(notes))
+	notesChecksum := hex.EncodeToString(notesSum[:])
+
+	upload := func(path, key, name, contentType, checksum, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path+"/attachments?name="+url.QueryEscape(name), strings.NewReader(body))
+		request.Header.Set("X-API-Key", key)
+		request.Header.Set("Content-Type", contentType)
+		if checksum != "" {
+			request.Header.Set(checksumHeader, checksum)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	resp = upload(agentPath, agent1000, "notes.csv", "text/csv", notesChecksum, notes)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("upload status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	var uploaded struct {
+		Attachment attachment `json:"attachment"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &uploaded); err != nil {
+		t.Fatalf("upload response %v", err)
+	}
+	if uploaded.Attachment.SHA256 != notesChecksum || uploaded.Attachment.Size != int64(len(notes)) {
+		t.Errorf("upload = %+v, want the size and checksum of the contents", uploaded.Attachment)
+	}
+
+	uploadTests := []struct {
+		name        string
+		path        string
+		key         string
+		fileName    string
+		contentType string
+		checksum    string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{
+			name:        "Missing name",
+			path:        taskPath,
+			key:         submitter,
+			contentType: "text/plain",
+			body:        "notes",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeValidationFailed,
+		},
+		{
+			name:        "Name with a directory",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "../notes.txt",
+			contentType: "text/plain",
+			body:        "notes",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeValidationFailed,
+		},
+		{
+			name:        "Content type not allowed",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "index.html",
+			contentType: "text/html",
+			body:        "<html></html>",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    codeUnsupportedMedia,
+		},
+		{
+			name:        "Contents are not the content type",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "scan.png",
+			contentType: "image/png",
+			body:        "notes",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    codeUnsupportedMedia,
+		},
+		{
+			name:        "Checksum mismatch",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "notes.txt",
+			contentType: "text/plain",
+			checksum:    notesChecksum,
+			body:        "other notes",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    codeChecksumMismatch,
+		},
+		{
+			name:        "Too large",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "notes.txt",
+			contentType: "text/plain",
+			body:        strings.Repeat("n", 1<<10+1),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    codePayloadTooLarge,
+		},
+		{
+			name:        "Another agent's task",
+			path:        "/v1/agent/1003/tasks/" + created.Task.ID,
+			key:         agent1003,
+			fileName:    "notes.txt",
+			contentType: "text/plain",
+			body:        "notes",
+			wantStatus:  http.StatusForbidden,
+			wantCode:    codeForbidden,
+		},
+		{
+			name:        "Submitter upload",
+			path:        taskPath,
+			key:         submitter,
+			fileName:    "notes.txt",
+			contentType: "text/plain; charset=utf-8",
+			body:        "notes",
+			wantStatus:  http.StatusOK,
+		},
+	}
+	for _, tt := range uploadTests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := upload(tt.path, tt.key, tt.fileName, tt.contentType, tt.checksum, tt.body)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("upload status = %v, want %v %s", resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantCode) {
+				t.Errorf("upload body = %s, want %s", resp.Body.String(), tt.wantCode)
+			}
+		})
+	}
+
+	downloadPath := taskPath + "/attachments/" + uploaded.Attachment.ID
+	resp = serveTest(s, http.MethodGet, downloadPath, submitter, "")
+	if resp.Code != http.StatusOK || resp.Body.String() != notes {
+		t.Errorf("download = %v %q, want %v %q", resp.Code, resp.Body.String(), http.StatusOK, notes)
+	}
+	if got := resp.Header().Get("Content-Disposition"); got != "attachment; filename=notes.csv" {
+		t.Errorf("download Content-Disposition = %q, want the file name", got)
+	}
+	if got := resp.Header().Get(checksumHeader); got != notesChecksum {
+		t.Errorf("download %s = %q, want %q", checksumHeader, got, notesChecksum)
+	}
+	resp = serveTest(s, http.MethodGet, taskPath+"/attachments/unknown", submitter, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("download unknown status = %v, want %v", resp.Code, http.StatusNotFound)
+	}
+
+	resp = serveTest(s, http.MethodGet, agentPath+"/attachments", agent1000, "")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"name":"notes.txt"`) {
+		t.Errorf("list attachments = %v %s, want both attachments", resp.Code, resp.Body.String())
+	}
+	resp = serveTest(s, http.MethodGet, taskPath, submitter, "")
+	if !strings.Contains(resp.Body.String(), `"attachments":[{`) {
+		t.Errorf("task status = %s, want the attachments", resp.Body.String())
+	}
+
+	key := uploaded.Attachment.blobKey(DefaultTenant)
+	if err := s.blobs.Put(context.Background(), key, "text/csv", []byte("tampered")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	resp = serveTest(s, http.MethodGet, downloadPath, submitter, "")
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("download corrupted status = %v, want %v", resp.Code, http.StatusInternalServerError)
+	}
+}
+
+func Test_scheduleHandlers(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	startAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	resp := serveTest(s, http.MethodPost, "/v1/schedule", submitter, `{"task":{"name":"Daily report","skills":["skill1"],"priority":"low"},"cron":"0 9 * * *"}`)
+	var created struct {
+		Schedule schedule `json:"schedule"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("create schedule = %v %s, %v", resp.Code, resp.Body.String(), err)
+	}
+	if created.Schedule.NextRun == nil || created.Schedule.NextRun.Hour() != 9 {
+		t.Errorf("create schedule next run = %v, want 9:00", created.Schedule.NextRun)
+	}
+	schedulePath := "/v1/schedule/" + created.Schedule.ID
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Create task held until its start time",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			body:       `{"name":"Later","skills":["skill1"],"priority":"low","start_at":"` + startAt + `"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"start_at":"` + startAt + `","paused":false,"next_run":"` + startAt + `"`,
+		},
+		{
+			name:       "Create task with a past start time",
+			method:     http.MethodPost,
+			path:       "/v1/task/create",
+			body:       `{"name":"Now","skills":["skill2"],"priority":"low","start_at":"2020-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"Assigned"`,
+		},
+		{
+			name:       "Invalid cron",
+			method:     http.MethodPost,
+			path:       "/v1/schedule",
+			body:       `{"task":{"name":"Report","skills":["skill1"],"priority":"low"},"cron":"0 25 * * *"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"field":"cron","code":"invalid"`,
+		},
+		{
+			name:       "List schedules",
+			method:     http.MethodGet,
+			path:       "/v1/schedule",
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"Later"`,
+		},
+		{
+			name:       "Pause",
+			method:     http.MethodPost,
+			path:       schedulePath + "/pause",
+			wantStatus: http.StatusOK,
+			wantBody:   `"paused":true`,
+		},
+		{
+			name:       "Resume",
+			method:     http.MethodPost,
+			path:       schedulePath + "/resume",
+			wantStatus: http.StatusOK,
+			wantBody:   `"paused":false,"next_run"`,
+		},
+		{
+			name:       "Delete",
+			method:     http.MethodDelete,
+			path:       schedulePath,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Deleted schedule",
+			method:     http.MethodGet,
+			path:       schedulePath,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Pause deleted schedule",
+			method:     http.MethodPost,
+			path:       schedulePath + "/pause",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, tt.method, tt.path, submitter, tt.body)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %v, want %v %s", tt.method, tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %s", tt.method, tt.path, resp.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_templateHandlers(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Create template",
+			method:     http.MethodPost,
+			path:       "/v1/template",
+			body:       `{"name":"callback","name_pattern":"Call back {metadata.customer}","skills":["skill1"],"priority":"high","metadata":{"queue":"billing"},"sla":"4h"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"callback","name_pattern":"Call back {metadata.customer}","skills":["skill1"],"priority":"high","metadata":{"queue":"billing"},"sla":"4h0m0s"`,
+		},
+		{
+			name:       "Create template twice",
+			method:     http.MethodPost,
+			path:       "/v1/template",
+			body:       `{"name":"callback"}`,
+			wantStatus: http.StatusConflict,
+			wantBody:   `"code":"already_exists"`,
+		},
+		{
+			name:       "Invalid template",
+			method:     http.MethodPost,
+			path:       "/v1/template",
+			body:       `{"name":"refund","skills":["skill9"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"field":"skills","code":"unsupported"`,
+		},
+		{
+			name:       "Create task with the template",
+			method:     http.MethodPost,
+			path:       "/v1/task/create?template=callback",
+			body:       `{"metadata":{"customer":"Jane"}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"Call back Jane","skills":["skill1"],"priority":"high"`,
+		},
+		{
+			name:       "Create task replacing the defaults",
+			method:     http.MethodPost,
+			path:       "/v1/task/create?template=callback",
+			body:       `{"name":"Refund","skills":["skill2"],"priority":"low","sla":"30m"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"metadata":{"queue":"billing"},"sla":"30m0s"`,
+		},
+		{
+			name:       "Create task with an unknown template",
+			method:     http.MethodPost,
+			path:       "/v1/task/create?template=unknown",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_parameter"`,
+		},
+		{
+			name:       "List templates",
+			method:     http.MethodGet,
+			path:       "/v1/template",
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"callback"`,
+		},
+		{
+			name:       "Delete template",
+			method:     http.MethodDelete,
+			path:       "/v1/template/callback",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Deleted template",
+			method:     http.MethodGet,
+			path:       "/v1/template/callback",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, tt.method, tt.path, submitter, tt.body)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %v, want %v %s", tt.method, tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %s", tt.method, tt.path, resp.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_reportHandlers(t *testing.T) {
+	s := newTestServer(t)
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	if resp := serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill2"],"priority":"low"}`); resp.Code != http.StatusOK {
+		t.Fatalf("POST /v1/task/create status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantBody    string
+		wantContent string
+	}{
+		{
+			name:        "Agent",
+			path:        "/v1/report/agents?agent=1001",
+			wantStatus:  http.StatusOK,
+			wantBody:    `"agent":"1001","first_name":"Ovaltine","last_name":"Jenkins"`,
+			wantContent: "application/json",
+		},
+		{
+			name:        "Assigned",
+			path:        "/v1/report/agents?agent=1001&window=24h",
+			wantStatus:  http.StatusOK,
+			wantBody:    `"assigned":1,"completed":0`,
+			wantContent: "application/json",
+		},
+		{
+			name:        "CSV",
+			path:        "/v1/report/agents?format=csv",
+			wantStatus:  http.StatusOK,
+			wantBody:    "agent,first_name,last_name,from,to,assigned,completed,avg_time_to_complete,p95_time_to_complete,preemptions,utilization,skills\n1000,Bighead,Burton,",
+			wantContent: "text/csv; charset=utf-8",
+		},
+		{
+			name:       "Invalid window",
+			path:       "/v1/report/agents?window=1s",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_parameter"`,
+		},
+		{
+			name:       "Invalid format",
+			path:       "/v1/report/agents?format=xml",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_parameter"`,
+		},
+		{
+			name:       "Unknown agent",
+			path:       "/v1/report/agents?agent=9999",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:        "Queues",
+			path:        "/v1/report/queues?window=24h",
+			wantStatus:  http.StatusOK,
+			wantBody:    `"skill":"skill2","priority":"low","created":1,"completed":0,"cancelled":0,"open":1,"queued":1`,
+			wantContent: "application/json",
+		},
+		{
+			name:        "Queues CSV",
+			path:        "/v1/report/queues?format=csv",
+			wantStatus:  http.StatusOK,
+			wantBody:    ",skill2,low,1,0,0,1,1,0,0,0\n",
+			wantContent: "text/csv; charset=utf-8",
+		},
+		{
+			name:       "Queues of an invalid period",
+			path:       "/v1/report/queues?from=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_parameter"`,
+		},
+		{
+			name:        "Skills",
+			path:        "/v1/report/skills",
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"skill":"skill2","description":"This is a awesome skill to have","agents":1,"open":1,"queued":1}`,
+			wantContent: "application/json",
+		},
+		{
+			name:        "Skills with few agents CSV",
+			path:        "/v1/report/skills?max_agents=1&format=csv",
+			wantStatus:  http.StatusOK,
+			wantBody:    "skill,description,agents,open,queued\nskill2,This is a awesome skill to have,1,1,1\n",
+			wantContent: "text/csv; charset=utf-8",
+		},
+		{
+			name:       "Invalid max agents",
+			path:       "/v1/report/skills?max_agents=-1",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_parameter"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, http.MethodGet, tt.path, submitter, "")
+			if resp.Code != tt.wantStatus {
+				t.Errorf("GET %s status = %v, want %v %s", tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s body = %s, want %s", tt.path, resp.Body.String(), tt.wantBody)
+			}
+			if tt.wantContent != "" && !strings.HasPrefix(resp.Header().Get("Content-Type"), tt.wantContent) {
+				t.Errorf("GET %s Content-Type = %s, want %s", tt.path, resp.Header().Get("Content-Type"), tt.wantContent)
+			}
+		})
+	}
+}
+
+func Test_adminHandlers(t *testing.T) {
+	s := newTestServer(t)
+	admin := testAPIKey(t, s, RoleAdmin, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Create skill",
+			method:     http.MethodPost,
+			path:       "/v1/skill",
+			body:       `{"skill":"skill4","description":"A new skill"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Duplicate skill",
+			method:     http.MethodPost,
+			path:       "/v1/skill",
+			body:       `{"skill":"skill4","description":"A new skill"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Create agent",
+			method:     http.MethodPost,
+			path:       "/v1/agent",
+			body:       `{"id":"2000","first_name":"Test","last_name":"Agent","skills":["skill4"]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Update unknown agent",
+			method:     http.MethodPut,
+			path:       "/v1/agent/9999/skills",
+			body:       `{"skills":["skill1"]}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Create priority",
+			method:     http.MethodPost,
+			path:       "/v1/priority",
+			body:       `{"priority":"urgent","priority_level":2}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "List priorities",
+			method:     http.MethodGet,
+			path:       "/v1/priority",
+			wantStatus: http.StatusOK,
+			wantBody:   `"urgent"`,
+		},
+		{
+			name:       "Issue agent key",
+			method:     http.MethodPost,
+			path:       "/v1/apikey",
+			body:       `{"name":"agent","role":"agent","agent":"2000"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"key":"td_`,
+		},
+		{
+			name:       "Revoke unknown key",
+			method:     http.MethodDelete,
+			path:       "/v1/apikey/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(s, tt.method, tt.path, admin, tt.body)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %v, want %v %s", tt.method, tt.path, resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %s", tt.method, tt.path, resp.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_NewServer(t *testing.T) {
+	first := httptest.NewServer(newTestServer(t))
+	defer first.Close()
+	second := httptest.NewServer(newTestServer(t))
+	defer second.Close()
+
+	key := testAPIKey(t, first.Config.Handler.(*Server), RoleAdmin, "")
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{
+			name:       "Server of the key",
+			url:        first.URL,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Server of another store",
+			url:        second.URL,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, tt.url+"/v1/skill", nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest() error = %v", err)
+			}
+			request.Header.Set("X-API-Key", key)
+			resp, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("GET /v1/skill error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET /v1/skill status = %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, err := NewServer(nil, Config{}); err == nil {
+		t.Errorf("NewServer() without a store error = nil, want an error")
+	}
+}
+
+func Test_Server_ListenAndServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe(ctx, addr)
+	}()
+	var resp *http.Response
+	for attempt := 0; attempt < 50; attempt++ {
+		if resp, err = http.Get("http://" + addr + "/v1/skill"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /v1/skill error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /v1/skill status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Server.ListenAndServe() error = %v, want a graceful shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server.ListenAndServe() did not return once the context was done")
+	}
+	if _, err := http.Get("http://" + addr + "/v1/skill"); err == nil {
+		t.Error("GET /v1/skill after the shutdown error = nil, want the connection refused")
+	}
+}
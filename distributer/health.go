@@ -0,0 +1,124 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// readyTimeout limits how long the readiness checks wait for the database.
+const readyTimeout = 2 * time.Second
+
+// BuildInfo identifies the build of the running server.
+type BuildInfo struct {
+	// Commit is the git commit the server was built from.
+	Commit string `json:"commit"`
+	// Time is when the server was built, in RFC 3339.
+	Time string `json:"build_time"`
+}
+
+// buildInfo fills in the commit and build time the go tool records in the
+// binary when they are not set.
+func buildInfo(b BuildInfo) BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && b.Commit == "":
+			b.Commit = setting.Value
+		case setting.Key == "vcs.time" && b.Time == "":
+			b.Time = setting.Value
+		}
+	}
+	return b
+}
+
+// healthzHandler reports the process is alive, it does not check the
+// database so a database outage does not restart every instance.
+func (s *Server) healthzHandler(writer http.ResponseWriter, request *http.Request) {
+	response := struct {
+		Success bool   `json:"success"`
+		Status  string `json:"status"`
+	}{
+		Success: true,
+		Status:  "ok",
+	}
+	formatResponse(writer, response)
+}
+
+// ready checks the server can serve requests: it is not shutting down, the
+// database is reachable and its schema is at least at the latest migration of
+// this build, a newer release may already have migrated it while this one is
+// still serving.  It returns the schema version, zero for the memory store.
+// The database errors are only logged, the returned error is safe to show
+// without authentication.
+func (s *Server) ready(ctx context.Context) (int, error) {
+	if s.draining.Load() {
+		return 0, errors.New("the server is shutting down")
+	}
+	db := storeDB(s.store)
+	if db == nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		ctxLogger(ctx).Warn("unable to reach the database", "error", err)
+		return 0, errors.New("the database is not reachable")
+	}
+	m, err := storeMigrator(s.store)
+	if err != nil {
+		return 0, err
+	}
+	version, err := m.version(ctx)
+	if err != nil {
+		ctxLogger(ctx).Warn("unable to read the schema version", "error", err)
+		return 0, errors.New("unable to read the schema version")
+	}
+	if version < m.latest() {
+		return version, fmt.Errorf("the schema is at version %d, want %d", version, m.latest())
+	}
+	return version, nil
+}
+
+// readyzHandler reports whether the server can serve requests, with a 503
+// Service Unavailable when it can not so the load balancer stops sending it
+// requests.
+func (s *Server) readyzHandler(writer http.ResponseWriter, request *http.Request) {
+	version, err := s.ready(request.Context())
+	if err != nil {
+		ctxLogger(request.Context()).Warn("not ready", "error", err)
+		formatError(writer, request, newAPIError(http.StatusServiceUnavailable, codeNotReady, "Not ready %s", err.Error()))
+		return
+	}
+	response := struct {
+		Success       bool   `json:"success"`
+		Status        string `json:"status"`
+		SchemaVersion int    `json:"schema_version,omitempty"`
+	}{
+		Success:       true,
+		Status:        "ready",
+		SchemaVersion: version,
+	}
+	formatResponse(writer, response)
+}
+
+// versionHandler returns the build of the server.
+func (s *Server) versionHandler(writer http.ResponseWriter, request *http.Request) {
+	response := struct {
+		Success bool `json:"success"`
+		BuildInfo
+		GoVersion string `json:"go_version"`
+	}{
+		Success:   true,
+		BuildInfo: s.build,
+		GoVersion: runtime.Version(),
+	}
+	formatResponse(writer, response)
+}
@@ -0,0 +1,133 @@
+package distributer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Server_healthHandlers(t *testing.T) {
+	sqlite := func(t *testing.T) *Server {
+		s, err := NewServer(newTestSQLiteStore(t), Config{})
+		if err != nil {
+			t.Fatalf("NewServer() error = %v", err)
+		}
+		return s
+	}
+	m, err := newMigrator(nil, dialectSQLite)
+	if err != nil {
+		t.Fatalf("newMigrator() error = %v", err)
+	}
+	latest := m.latest()
+	tests := []struct {
+		name       string
+		server     func(t *testing.T) *Server
+		path       string
+		wantStatus int
+		want       string
+		notWant    string
+	}{
+		{
+			name:       "Alive",
+			server:     newTestServer,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			want:       `"status":"ok"`,
+		},
+		{
+			name:       "Memory store ready",
+			server:     newTestServer,
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			want:       `"status":"ready"`,
+		},
+		{
+			name:       "Migrated database ready",
+			server:     sqlite,
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			want:       fmt.Sprintf(`"schema_version":%d`, latest),
+		},
+		{
+			name: "Schema behind",
+			server: func(t *testing.T) *Server {
+				s := sqlite(t)
+				m, err := storeMigrator(s.store)
+				if err != nil {
+					t.Fatalf("storeMigrator() error = %v", err)
+				}
+				if _, err := m.down(context.Background(), 1); err != nil {
+					t.Fatalf("migrator.down() error = %v", err)
+				}
+				return s
+			},
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			want:       fmt.Sprintf("schema is at version %d, want %d", latest-1, latest),
+		},
+		{
+			name: "Schema migrated by a newer release",
+			server: func(t *testing.T) *Server {
+				s := sqlite(t)
+				_, err := storeDB(s.store).Exec(`INSERT INTO SCHEMA_MIGRATIONS (VERSION, NAME, APPLIEDDATE) VALUES ($1, $2, $3)`, latest+1, "newer", time.Now().UTC())
+				if err != nil {
+					t.Fatalf("INSERT INTO SCHEMA_MIGRATIONS error = %v", err)
+				}
+				return s
+			},
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			want:       fmt.Sprintf(`"schema_version":%d`, latest+1),
+		},
+		{
+			name: "Database closed",
+			server: func(t *testing.T) *Server {
+				s := sqlite(t)
+				storeDB(s.store).Close()
+				return s
+			},
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			want:       "database is not reachable",
+			notWant:    "sql: database is closed",
+		},
+		{
+			name: "Shutting down",
+			server: func(t *testing.T) *Server {
+				s := newTestServer(t)
+				s.draining.Store(true)
+				return s
+			},
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			want:       "shutting down",
+		},
+		{
+			name: "Version",
+			server: func(t *testing.T) *Server {
+				s, err := NewServer(newTestStore(t), Config{Build: BuildInfo{Commit: "2ae1305", Time: "2026-10-19T09:00:00Z"}})
+				if err != nil {
+					t.Fatalf("NewServer() error = %v", err)
+				}
+				return s
+			},
+			path:       "/version",
+			wantStatus: http.StatusOK,
+			want:       `"commit":"2ae1305","build_time":"2026-10-19T09:00:00Z","go_version":"go`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveTest(tt.server(t), http.MethodGet, tt.path, "", "")
+			if resp.Code != tt.wantStatus || !strings.Contains(resp.Body.String(), tt.want) {
+				t.Errorf("GET %s = %v %s, want %v %s", tt.path, resp.Code, resp.Body.String(), tt.wantStatus, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(resp.Body.String(), tt.notWant) {
+				t.Errorf("GET %s = %s, want no %s", tt.path, resp.Body.String(), tt.notWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package distributer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// requestIDHeader is the header with the id of a request, which is taken from
+// the request when it is present and echoed in the response.
+const requestIDHeader = "X-Request-ID"
+
+// loggerKey is the request context key holding the request's logger.
+type loggerKey struct{}
+
+// NewLogger returns a logger writing to w at the level, debug, info, warn or
+// error, as json lines or as text.
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("log level %s is not supported", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log format %s is not supported, use json or text", format)
+	}
+}
+
+// withLogger returns the context with the logger.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// ctxLogger returns the logger of the request, which tags each line with the
+// request id, or the default logger outside of a request.
+func ctxLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// logQueryError logs a failed query of a store.  Canceled requests are only
+// logged at debug level since the client is gone.
+func logQueryError(ctx context.Context, op string, err error) {
+	level := slog.LevelError
+	if ctx.Err() != nil {
+		level = slog.LevelDebug
+	}
+	ctxLogger(ctx).Log(ctx, level, "query failed", "op", op, "error", err)
+}
+
+// statusRecorder records the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// validRequestID reports whether a client's request id can be used, it must
+// be short and printable so it can not forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool { return r < 0x21 || r > 0x7e }) < 0
+}
+
+// requestLogger tags the request with an id, echoed in the X-Request-ID
+// header, gives it a logger with the id and logs the request once it is done.
+func (s *Server) requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		id := request.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = xid.New().String()
+		}
+		writer.Header().Set(requestIDHeader, id)
+		logger := s.logger.With("request_id", id)
+		recorder := &statusRecorder{ResponseWriter: writer}
+		next.ServeHTTP(recorder, request.WithContext(withLogger(request.Context(), logger)))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(request.Context(), level, "request",
+			"method", request.Method,
+			"path", request.URL.Path,
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
+	})
+}
@@ -0,0 +1,133 @@
+package distributer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_NewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "JSON",
+			format: "json",
+			level:  "info",
+			want:   `"msg":"test"`,
+		},
+		{
+			name:   "Text",
+			format: "text",
+			level:  "debug",
+			want:   "msg=test",
+		},
+		{
+			name:   "Level above",
+			format: "json",
+			level:  "error",
+			want:   "",
+		},
+		{
+			name:    "Unknown format",
+			format:  "xml",
+			level:   "info",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown level",
+			format:  "json",
+			level:   "verbose",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := NewLogger(&buf, tt.format, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			logger.Info("test")
+			if !strings.Contains(buf.String(), tt.want) || (tt.want == "" && buf.Len() > 0) {
+				t.Errorf("NewLogger() wrote %s, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_Server_requestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", "debug")
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	s, err := NewServer(newTestStore(t), Config{Logger: logger})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+
+	tests := []struct {
+		name      string
+		requestID string
+		wantEcho  bool
+	}{
+		{
+			name:      "Client's id",
+			requestID: "client-id-1",
+			wantEcho:  true,
+		},
+		{
+			name:      "Generated id",
+			requestID: "",
+		},
+		{
+			name:      "Invalid id",
+			requestID: "forged\tline",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			request := httptest.NewRequest(http.MethodPost, "/v1/task/create", strings.NewReader(`{"name":"Test Name","skills":["skill1"],"priority":"low"}`))
+			request.Header.Set("X-API-Key", submitter)
+			if tt.requestID != "" {
+				request.Header.Set(requestIDHeader, tt.requestID)
+			}
+			resp := httptest.NewRecorder()
+			s.ServeHTTP(resp, request)
+			got := resp.Header().Get(requestIDHeader)
+			if got == "" || (got == tt.requestID) != tt.wantEcho {
+				t.Fatalf("%s = %q for %q, want echoed %v", requestIDHeader, got, tt.requestID, tt.wantEcho)
+			}
+
+			var reasons []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var entry map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("log line %s is not JSON %v", line, err)
+				}
+				if entry["request_id"] != got {
+					t.Errorf("log line %s request_id, want %s", line, got)
+				}
+				if reason, ok := entry["reason"].(string); ok {
+					reasons = append(reasons, reason)
+				}
+			}
+			if len(reasons) != 1 {
+				t.Errorf("assignment reasons = %v, want the reason the agent was chosen", reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,781 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*memoryStore)(nil)
+
+// memoryStore is the Store kept in memory, for tests and for running locally
+// without Postgres.  Nothing is kept once the process exits.
+type memoryStore struct {
+	mu          sync.RWMutex
+	tenants     map[string]tenant
+	skills      map[string]map[string]skill
+	priorities  map[string]map[string]priority
+	agents      map[string]map[string]agent
+	tasks       map[string][]task
+	events      map[string][]event
+	comments    map[string][]comment
+	attachments map[string][]attachment
+	schedules   map[string][]schedule
+	templates   map[string]map[string]template
+	apiKeys     []apiKey
+}
+
+// newMemoryStore returns an empty store with only the default tenant.
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		tenants:     map[string]tenant{},
+		skills:      map[string]map[string]skill{},
+		priorities:  map[string]map[string]priority{},
+		agents:      map[string]map[string]agent{},
+		tasks:       map[string][]task{},
+		events:      map[string][]event{},
+		comments:    map[string][]comment{},
+		attachments: map[string][]attachment{},
+		schedules:   map[string][]schedule{},
+		templates:   map[string]map[string]template{},
+	}
+	s.CreateTenant(context.Background(), tenant{
+		ID:         DefaultTenant,
+		Name:       "Default",
+		CreateTime: time.Now(),
+	})
+	return s
+}
+
+// seed adds the same skills, agents and priorities to the default tenant as
+// the seed data migration.
+func (s *memoryStore) seed() error {
+	ctx := context.Background()
+	for _, sk := range []skill{
+		{Skill: "skill1", Description: "This is a great skill to have"},
+		{Skill: "skill2", Description: "This is a awesome skill to have"},
+		{Skill: "skill3", Description: "This is a cool skill to have"},
+	} {
+		if err := s.CreateSkill(ctx, DefaultTenant, sk); err != nil {
+			return err
+		}
+	}
+	for _, a := range []agent{
+		{ID: "1000", FirstName: "Bighead", LastName: "Burton", Skills: []string{"skill1"}},
+		{ID: "1001", FirstName: "Ovaltine", LastName: "Jenkins", Skills: []string{"skill2", "skill3"}},
+		{ID: "1002", FirstName: "Ground", LastName: "Control", Skills: []string{"skill3"}},
+		{ID: "1003", FirstName: "Jazz", LastName: "Hands", Skills: []string{"skill1", "skill3"}},
+	} {
+		if err := s.CreateAgent(ctx, DefaultTenant, a); err != nil {
+			return err
+		}
+	}
+	for _, p := range []priority{
+		{Priority: "low", Level: 0},
+		{Priority: "high", Level: 1},
+	} {
+		if err := s.CreatePriority(ctx, DefaultTenant, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Tenants(ctx context.Context) ([]tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tenants := []tenant{}
+	for _, t := range s.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants, nil
+}
+
+func (s *memoryStore) CreateTenant(ctx context.Context, t tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.tenants[t.ID]; has {
+		return fmt.Errorf("tenant %s is already present", t.ID)
+	}
+	s.tenants[t.ID] = t
+	s.skills[t.ID] = map[string]skill{}
+	s.priorities[t.ID] = map[string]priority{}
+	s.agents[t.ID] = map[string]agent{}
+	return nil
+}
+
+func (s *memoryStore) SkillCount(ctx context.Context, tenant string, skills []string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	counted := map[string]bool{}
+	for _, sk := range skills {
+		if _, has := s.skills[tenant][sk]; has && !counted[sk] {
+			counted[sk] = true
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) Skills(ctx context.Context, tenant string) ([]skill, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	skills := []skill{}
+	for _, sk := range s.skills[tenant] {
+		skills = append(skills, sk)
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		return skills[i].Skill < skills[j].Skill
+	})
+	return skills, nil
+}
+
+func (s *memoryStore) SkillAgents(ctx context.Context, tenant string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]int{}
+	for _, sk := range s.skills[tenant] {
+		counts[sk.Skill] = 0
+	}
+	for _, a := range s.agents[tenant] {
+		for _, sk := range a.Skills {
+			counts[sk]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *memoryStore) CreateSkill(ctx context.Context, tenant string, sk skill) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skills, has := s.skills[tenant]
+	if !has {
+		return fmt.Errorf("tenant %s is not present", tenant)
+	}
+	if _, has := skills[sk.Skill]; has {
+		return fmt.Errorf("skill %s is already present", sk.Skill)
+	}
+	skills[sk.Skill] = sk
+	return nil
+}
+
+func (s *memoryStore) PriorityLevel(ctx context.Context, tenant, priority string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, has := s.priorities[tenant][priority]
+	if !has {
+		return -1, fmt.Errorf("priority %s is not present", priority)
+	}
+	return p.Level, nil
+}
+
+func (s *memoryStore) Priorities(ctx context.Context, tenant string) ([]priority, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	priorities := []priority{}
+	for _, p := range s.priorities[tenant] {
+		priorities = append(priorities, p)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		return priorities[i].Level < priorities[j].Level
+	})
+	return priorities, nil
+}
+
+func (s *memoryStore) CreatePriority(ctx context.Context, tenant string, p priority) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	priorities, has := s.priorities[tenant]
+	if !has {
+		return fmt.Errorf("tenant %s is not present", tenant)
+	}
+	if _, has := priorities[p.Priority]; has {
+		return fmt.Errorf("priority %s is already present", p.Priority)
+	}
+	priorities[p.Priority] = p
+	return nil
+}
+
+func (s *memoryStore) Agents(ctx context.Context, tenant string, ids []string) ([]agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var agents []agent
+	for _, id := range ids {
+		if a, has := s.agents[tenant][id]; has {
+			a.Skills = nil
+			agents = append(agents, a)
+		}
+	}
+	if len(agents) == 0 {
+		return nil, errors.New("no agents found")
+	}
+	sortAgents(agents)
+	return agents, nil
+}
+
+func (s *memoryStore) MatchingAgents(ctx context.Context, tenant string, skills []string) ([]agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var agents []agent
+	for _, a := range s.agents[tenant] {
+		if hasSkills(a.Skills, skills) {
+			a.Skills = nil
+			agents = append(agents, a)
+		}
+	}
+	if len(agents) == 0 {
+		return nil, errNoSkilledAgents
+	}
+	sortAgents(agents)
+	return agents, nil
+}
+
+func hasSkills(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sortAgents(agents []agent) {
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID < agents[j].ID
+	})
+}
+
+func (s *memoryStore) CreateAgent(ctx context.Context, tenant string, a agent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	agents, has := s.agents[tenant]
+	if !has {
+		return fmt.Errorf("tenant %s is not present", tenant)
+	}
+	if _, has := agents[a.ID]; has {
+		return fmt.Errorf("agent %s is already present", a.ID)
+	}
+	if err := s.checkSkills(tenant, a.Skills); err != nil {
+		return err
+	}
+	a.Skills = append([]string(nil), a.Skills...)
+	agents[a.ID] = a
+	return nil
+}
+
+func (s *memoryStore) UpdateAgentSkills(ctx context.Context, tenant, agentID string, skills []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, has := s.agents[tenant][agentID]
+	if !has {
+		return fmt.Errorf("agent %s is not present", agentID)
+	}
+	if err := s.checkSkills(tenant, skills); err != nil {
+		return err
+	}
+	a.Skills = append([]string(nil), skills...)
+	s.agents[tenant][agentID] = a
+	return nil
+}
+
+func (s *memoryStore) checkSkills(tenant string, skills []string) error {
+	for _, sk := range skills {
+		if _, has := s.skills[tenant][sk]; !has {
+			return fmt.Errorf("skill %s is not present", sk)
+		}
+	}
+	return nil
+}
+
+// open returns the open tasks of the agents, with the priority level of each.
+func (s *memoryStore) open(tenant string, agentIDs []string) []task {
+	ids := map[string]bool{}
+	for _, id := range agentIDs {
+		ids[id] = true
+	}
+	var tasks []task
+	for _, t := range s.tasks[tenant] {
+		if !ids[t.Agent] || !isOpen(t.Status) {
+			continue
+		}
+		t.priorityLevel = s.priorities[tenant][t.Priorty].Level
+		tasks = append(tasks, copyTask(t))
+	}
+	return tasks
+}
+
+func isOpen(status string) bool {
+	for _, open := range openStatuses {
+		if status == open {
+			return true
+		}
+	}
+	return false
+}
+
+func copyTask(t task) task {
+	t.Skills = append([]string(nil), t.Skills...)
+	t.taskResult = t.taskResult.copy()
+	t.taskDetails = t.taskDetails.copy()
+	t.store = nil
+	return t
+}
+
+func (s *memoryStore) OpenTasks(ctx context.Context, tenant string, agentIDs []string) (map[string][]task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	at := map[string][]task{}
+	for _, t := range s.open(tenant, agentIDs) {
+		at[t.Agent] = append(at[t.Agent], t)
+	}
+	return at, nil
+}
+
+func (s *memoryStore) RecentAgent(ctx context.Context, tenant string, agentIDs []string, priorityLevel int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	agentID := ""
+	var recent time.Time
+	for _, t := range s.open(tenant, agentIDs) {
+		if t.priorityLevel < priorityLevel && !t.StartTime.Before(recent) {
+			agentID = t.Agent
+			recent = t.StartTime
+		}
+	}
+	return agentID, nil
+}
+
+func (s *memoryStore) AgentTasks(ctx context.Context, tenant string) ([]agentTasks, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id := range s.agents[tenant] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tasks := map[string][]task{}
+	for _, t := range s.open(tenant, ids) {
+		t.priorityLevel = 0
+		tasks[t.Agent] = append(tasks[t.Agent], t)
+	}
+	lats := make([]agentTasks, len(ids))
+	for idx, id := range ids {
+		a := s.agents[tenant][id]
+		a.Skills = nil
+		lats[idx] = agentTasks{
+			agent: a,
+			Tasks: tasks[id],
+		}
+	}
+	return lats, nil
+}
+
+func (s *memoryStore) TasksByAgent(ctx context.Context, tenant, agentID string) ([]task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tasks := []task{}
+	for _, t := range s.open(tenant, []string{agentID}) {
+		t.priorityLevel = 0
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *memoryStore) Tasks(ctx context.Context, tenant string, filter taskFilter) ([]task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tasks := []task{}
+	for idx := len(s.tasks[tenant]) - 1; idx >= 0; idx-- {
+		t := s.tasks[tenant][idx]
+		if !filter.matches(t) {
+			continue
+		}
+		tasks = append(tasks, copyTask(t))
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.After(tasks[j].StartTime)
+	})
+	if filter.Limit > 0 && len(tasks) > filter.Limit {
+		tasks = tasks[:filter.Limit]
+	}
+	return tasks, nil
+}
+
+func (s *memoryStore) Task(ctx context.Context, tenant, id string) (task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tasks[tenant] {
+		if t.ID == id {
+			return copyTask(t), nil
+		}
+	}
+	return task{}, fmt.Errorf("unable to find task %s", id)
+}
+
+func (s *memoryStore) CreateTask(ctx context.Context, tenant string, t task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.agents[tenant][t.Agent]; !has {
+		return fmt.Errorf("agent %s is not present", t.Agent)
+	}
+	if _, has := s.priorities[tenant][t.Priorty]; !has {
+		return fmt.Errorf("priority %s is not present", t.Priorty)
+	}
+	t = copyTask(t)
+	t.priorityLevel = 0
+	s.tasks[tenant] = append(s.tasks[tenant], t)
+	return nil
+}
+
+// update calls fn with the task to change it, false is returned if the task
+// is not present.
+func (s *memoryStore) update(tenant, id string, fn func(t *task) bool) bool {
+	for idx := range s.tasks[tenant] {
+		if s.tasks[tenant][idx].ID == id {
+			return fn(&s.tasks[tenant][idx])
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) UpdateTaskStatus(ctx context.Context, tenant, id, status string, result taskResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update(tenant, id, func(t *task) bool {
+		t.Status = status
+		t.taskResult = result.copy()
+		t.CompleteTime = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (s *memoryStore) TransitionTask(ctx context.Context, tenant, id, agentID string, from []string, to string, result taskResult) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := s.update(tenant, id, func(t *task) bool {
+		transition := taskTransition{from: from, to: to}
+		if t.Agent != agentID || !transition.allowed(t.Status) {
+			return false
+		}
+		t.Status = to
+		t.taskResult = result.copy()
+		if transition.ends() {
+			t.CompleteTime = time.Now()
+		}
+		return true
+	})
+	return updated, nil
+}
+
+func (s *memoryStore) CreateComment(ctx context.Context, tenant string, c comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.update(tenant, c.Task, func(t *task) bool { return true }) {
+		return fmt.Errorf("task %s is not present", c.Task)
+	}
+	s.comments[tenant] = append(s.comments[tenant], c)
+	return nil
+}
+
+func (s *memoryStore) Comments(ctx context.Context, tenant, taskID string) ([]comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comments := []comment{}
+	for _, c := range s.comments[tenant] {
+		if c.Task == taskID {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (s *memoryStore) CreateAttachment(ctx context.Context, tenant string, a attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.update(tenant, a.Task, func(t *task) bool { return true }) {
+		return fmt.Errorf("task %s is not present", a.Task)
+	}
+	s.attachments[tenant] = append(s.attachments[tenant], a)
+	return nil
+}
+
+func (s *memoryStore) Attachments(ctx context.Context, tenant, taskID string) ([]attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attachments := []attachment{}
+	for _, a := range s.attachments[tenant] {
+		if a.Task == taskID {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (s *memoryStore) Attachment(ctx context.Context, tenant, taskID, id string) (attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, a := range s.attachments[tenant] {
+		if a.Task == taskID && a.ID == id {
+			return a, nil
+		}
+	}
+	return attachment{}, fmt.Errorf("attachment %s is not present", id)
+}
+
+func (s *memoryStore) CreateSchedule(ctx context.Context, tenant string, sc schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.schedules[tenant] {
+		if existing.ID == sc.ID {
+			return fmt.Errorf("schedule %s is already present", sc.ID)
+		}
+	}
+	sc.tenant = ""
+	s.schedules[tenant] = append(s.schedules[tenant], sc.copy())
+	return nil
+}
+
+func (s *memoryStore) Schedules(ctx context.Context, tenant string) ([]schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedules := make([]schedule, 0, len(s.schedules[tenant]))
+	for _, sc := range s.schedules[tenant] {
+		schedules = append(schedules, sc.copy())
+	}
+	return schedules, nil
+}
+
+func (s *memoryStore) Schedule(ctx context.Context, tenant, id string) (schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sc := range s.schedules[tenant] {
+		if sc.ID == id {
+			return sc.copy(), nil
+		}
+	}
+	return schedule{}, fmt.Errorf("schedule %s is not present", id)
+}
+
+// updateSchedule calls fn with the schedule, false is returned if it is not
+// present or fn does not update it.
+func (s *memoryStore) updateSchedule(tenant, id string, fn func(sc *schedule) bool) bool {
+	for idx := range s.schedules[tenant] {
+		if s.schedules[tenant][idx].ID == id {
+			return fn(&s.schedules[tenant][idx])
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) SetSchedulePaused(ctx context.Context, tenant, id string, paused bool, nextRun *time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateSchedule(tenant, id, func(sc *schedule) bool {
+		sc.Paused = paused
+		sc.NextRun = nextRun
+		return true
+	}), nil
+}
+
+func (s *memoryStore) DeleteSchedule(ctx context.Context, tenant, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for idx, sc := range s.schedules[tenant] {
+		if sc.ID == id {
+			s.schedules[tenant] = append(s.schedules[tenant][:idx:idx], s.schedules[tenant][idx+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStore) DueSchedules(ctx context.Context, now time.Time, limit int) ([]schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	due := []schedule{}
+	for tenant, schedules := range s.schedules {
+		for _, sc := range schedules {
+			if !sc.Paused && sc.NextRun != nil && !sc.NextRun.After(now) {
+				sc = sc.copy()
+				sc.tenant = tenant
+				due = append(due, sc)
+			}
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextRun.Before(*due[j].NextRun)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *memoryStore) ClaimSchedule(ctx context.Context, tenant, id string, due, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateSchedule(tenant, id, func(sc *schedule) bool {
+		if sc.Paused || sc.NextRun == nil || !sc.NextRun.Equal(due) {
+			return false
+		}
+		sc.NextRun = &until
+		return true
+	}), nil
+}
+
+func (s *memoryStore) RecordScheduleRun(ctx context.Context, tenant, id string, run scheduleRun, nextRun *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateSchedule(tenant, id, func(sc *schedule) bool {
+		sc.scheduleRun = run
+		sc.NextRun = nextRun
+		return true
+	})
+	return nil
+}
+
+func (s *memoryStore) CreateTemplate(ctx context.Context, tenant string, tp template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.templates[tenant][tp.Name]; has {
+		return fmt.Errorf("template %s is already present", tp.Name)
+	}
+	if s.templates[tenant] == nil {
+		s.templates[tenant] = map[string]template{}
+	}
+	s.templates[tenant][tp.Name] = tp.copy()
+	return nil
+}
+
+func (s *memoryStore) Templates(ctx context.Context, tenant string) ([]template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make([]template, 0, len(s.templates[tenant]))
+	for _, tp := range s.templates[tenant] {
+		templates = append(templates, tp.copy())
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (s *memoryStore) Template(ctx context.Context, tenant, name string) (template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tp, has := s.templates[tenant][name]
+	if !has {
+		return template{}, fmt.Errorf("template %s is not present", name)
+	}
+	return tp.copy(), nil
+}
+
+func (s *memoryStore) DeleteTemplate(ctx context.Context, tenant, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.templates[tenant][name]; !has {
+		return false, nil
+	}
+	delete(s.templates[tenant], name)
+	return true, nil
+}
+
+func (s *memoryStore) CreateEvent(ctx context.Context, tenant string, e event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.tenants[tenant]; !has {
+		return fmt.Errorf("tenant %s is not present", tenant)
+	}
+	e.ID = int64(len(s.events[tenant]) + 1)
+	s.events[tenant] = append(s.events[tenant], e)
+	return nil
+}
+
+func (s *memoryStore) Events(ctx context.Context, tenant string, after int64, limit int) ([]event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []event{}
+	for _, e := range s.events[tenant] {
+		if e.ID > after && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) EventsBetween(ctx context.Context, tenant string, from, to time.Time) ([]event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []event{}
+	for _, e := range s.events[tenant] {
+		if !e.CreateTime.Before(from) && e.CreateTime.Before(to) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) CreateAPIKey(ctx context.Context, key apiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, has := s.tenants[key.Tenant]; !has {
+		return fmt.Errorf("tenant %s is not present", key.Tenant)
+	}
+	if _, has := s.agents[key.Tenant][key.Agent]; key.Agent != "" && !has {
+		return fmt.Errorf("agent %s is not present", key.Agent)
+	}
+	key.Key = ""
+	s.apiKeys = append(s.apiKeys, key)
+	return nil
+}
+
+func (s *memoryStore) APIKeyByHash(ctx context.Context, hash string) (apiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.apiKeys {
+		if key.hash == hash {
+			return key, nil
+		}
+	}
+	return apiKey{}, errors.New("unable to find API key")
+}
+
+func (s *memoryStore) APIKeys(ctx context.Context, tenant string) ([]apiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []apiKey{}
+	for _, key := range s.apiKeys {
+		if key.Tenant == tenant {
+			key.hash = ""
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) RevokeAPIKey(ctx context.Context, tenant, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for idx, key := range s.apiKeys {
+		if key.Tenant == tenant && key.ID == id && key.RevokeTime.IsZero() {
+			s.apiKeys[idx].RevokeTime = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
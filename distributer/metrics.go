@@ -0,0 +1,346 @@
+package distributer
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of metrics, as named by the Prometheus text format.
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+// durationBuckets are the histogram buckets, in seconds, of the latencies.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a counter, gauge or histogram with a series for each set of label
+// values.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for a set of label values.
+type series struct {
+	labelValues []string
+	value       float64
+	// counts are the observations in each bucket of a histogram, and sum
+	// their sum.
+	counts []uint64
+	sum    float64
+}
+
+func newMetric(kind, name, help string, labels ...string) *metric {
+	m := &metric{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: map[string]*series{},
+	}
+	if kind == metricHistogram {
+		m.buckets = durationBuckets
+	}
+	return m
+}
+
+// get returns the series of the label values, the metric's mu must be held.
+func (m *metric) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	sr, has := m.series[key]
+	if !has {
+		sr = &series{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = sr
+	}
+	return sr
+}
+
+// add adds to a counter or gauge.
+func (m *metric) add(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labelValues).value += v
+}
+
+// set sets a gauge.
+func (m *metric) set(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labelValues).value = v
+}
+
+// observe adds an observation to a histogram.
+func (m *metric) observe(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sr := m.get(labelValues)
+	for idx, upper := range m.buckets {
+		if v <= upper {
+			sr.counts[idx]++
+		}
+	}
+	sr.value++
+	sr.sum += v
+}
+
+// reset removes every series, so a gauge collected when it is scraped only
+// has the current series.
+func (m *metric) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = map[string]*series{}
+}
+
+// write writes the metric in the Prometheus text format, only the series of
+// the tenant when it is not empty.  A metric without a tenant label is shared
+// by every tenant, so it is only written when every tenant's series are.
+func (m *metric) write(w io.Writer, tenantID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenantLabel := -1
+	for idx, label := range m.labels {
+		if label == "tenant" {
+			tenantLabel = idx
+		}
+	}
+	if tenantID != "" && tenantLabel < 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, helpEscaper.Replace(m.help), m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sr := m.series[key]
+		if tenantID != "" && sr.labelValues[tenantLabel] != tenantID {
+			continue
+		}
+		if m.kind != metricHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, sr.labelValues), formatValue(sr.value))
+			continue
+		}
+		bucketLabels := append(append([]string{}, m.labels...), "le")
+		bucketValues := append(append([]string{}, sr.labelValues...), "")
+		for idx, upper := range m.buckets {
+			bucketValues[len(bucketValues)-1] = formatValue(upper)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(bucketLabels, bucketValues), sr.counts[idx])
+		}
+		bucketValues[len(bucketValues)-1] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %s\n", m.name, formatLabels(bucketLabels, bucketValues), formatValue(sr.value))
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatLabels(m.labels, sr.labelValues), formatValue(sr.sum))
+		fmt.Fprintf(w, "%s_count%s %s\n", m.name, formatLabels(m.labels, sr.labelValues), formatValue(sr.value))
+	}
+}
+
+// labelEscaper escapes the label values, and helpEscaper the help text, in
+// which quotes are not escaped.
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for idx, name := range names {
+		pairs[idx] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[idx]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metrics are the metrics of a server.
+type metrics struct {
+	requests           *metric
+	requestDuration    *metric
+	tasks              *metric
+	taskSkills         *metric
+	assignmentDuration *metric
+	openTasks          *metric
+	agentOpenTasks     *metric
+	dbConnections      *metric
+	dbMaxOpen          *metric
+	dbWaits            *metric
+	dbWaitDuration     *metric
+	all                []*metric
+
+	// collectMu serializes the scrapes, which reset the collected gauges.
+	collectMu sync.Mutex
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		requests:           newMetric(metricCounter, "task_distributer_http_requests_total", "HTTP requests by route, method and status code.", "handler", "method", "code"),
+		requestDuration:    newMetric(metricHistogram, "task_distributer_http_request_duration_seconds", "Latency of the HTTP requests by route and method.", "handler", "method"),
+		tasks:              newMetric(metricCounter, "task_distributer_tasks_total", "Tasks by tenant, event, like created, assigned, completed or rejected, and priority.", "tenant", "event", "priority"),
+		taskSkills:         newMetric(metricCounter, "task_distributer_task_skills_total", "Tasks by tenant, event and each skill they require.", "tenant", "event", "skill"),
+		assignmentDuration: newMetric(metricHistogram, "task_distributer_assignment_duration_seconds", "Latency of finding an agent and assigning a task by tenant.", "tenant"),
+		openTasks:          newMetric(metricGauge, "task_distributer_open_tasks", "Tasks the agents have not completed, the queue depth, by tenant and status.", "tenant", "status"),
+		agentOpenTasks:     newMetric(metricGauge, "task_distributer_agent_open_tasks", "Tasks each agent has not completed.", "tenant", "agent"),
+		dbConnections:      newMetric(metricGauge, "task_distributer_db_connections", "Database pool connections by state, in use or idle.", "state"),
+		dbMaxOpen:          newMetric(metricGauge, "task_distributer_db_max_open_connections", "Most open connections of the database pool."),
+		dbWaits:            newMetric(metricCounter, "task_distributer_db_wait_total", "Times a query waited for a database connection."),
+		dbWaitDuration:     newMetric(metricCounter, "task_distributer_db_wait_seconds_total", "Time queries waited for a database connection."),
+	}
+	m.all = []*metric{m.requests, m.requestDuration, m.tasks, m.taskSkills, m.assignmentDuration, m.openTasks, m.agentOpenTasks, m.dbConnections, m.dbMaxOpen, m.dbWaits, m.dbWaitDuration}
+	return m
+}
+
+// taskEvent counts the tenant's task event by the task's priority and by each
+// of its skills.
+func (m *metrics) taskEvent(tenant, event string, t task) {
+	m.tasks.add(1, tenant, event, t.Priorty)
+	for _, sk := range t.Skills {
+		m.taskSkills.add(1, tenant, event, sk)
+	}
+}
+
+// routeKey is the request context key holding the matched route, which the
+// router sets for the metrics.
+type routeKey struct{}
+
+// setRoute records the pattern of the route matching the request.
+func setRoute(ctx context.Context, pattern string) {
+	if route, ok := ctx.Value(routeKey{}).(*string); ok {
+		*route = pattern
+	}
+}
+
+// requestRoute returns the pattern of the route matching the request, empty
+// when no route matches.
+func requestRoute(ctx context.Context) string {
+	if route, ok := ctx.Value(routeKey{}).(*string); ok && *route != "unmatched" {
+		return *route
+	}
+	return ""
+}
+
+// instrument counts the requests and their latency by route, requests that
+// do not match a route are counted as unmatched.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		route := "unmatched"
+		recorder := &statusRecorder{ResponseWriter: writer}
+		next.ServeHTTP(recorder, request.WithContext(context.WithValue(request.Context(), routeKey{}, &route)))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		s.metrics.requests.add(1, route, request.Method, strconv.Itoa(recorder.status))
+		s.metrics.requestDuration.observe(time.Since(start).Seconds(), route, request.Method)
+	})
+}
+
+// storeDB returns the database of the store, nil for the memory store.
+func storeDB(store Store) *sql.DB {
+	switch s := store.(type) {
+	case *postgresStore:
+		return s.db
+	case *sqliteStore:
+		return s.db
+	case *tracedStore:
+		return storeDB(s.store)
+	default:
+		return nil
+	}
+}
+
+// collect updates the gauges from the store, the tenant's open tasks, or
+// every tenant's when it is empty, and the database pool.
+func (s *Server) collect(ctx context.Context, tenantID string) error {
+	s.metrics.openTasks.reset()
+	s.metrics.agentOpenTasks.reset()
+	tenants := []tenant{{ID: tenantID}}
+	if tenantID == "" {
+		var err error
+		if tenants, err = s.store.Tenants(ctx); err != nil {
+			return err
+		}
+	}
+	for _, tn := range tenants {
+		ats, err := s.store.AgentTasks(ctx, tn.ID)
+		if err != nil {
+			return err
+		}
+		for _, at := range ats {
+			s.metrics.agentOpenTasks.set(float64(len(at.Tasks)), tn.ID, at.ID)
+			for _, status := range openStatuses {
+				s.metrics.openTasks.add(0, tn.ID, status)
+			}
+			for _, t := range at.Tasks {
+				s.metrics.openTasks.add(1, tn.ID, t.Status)
+			}
+		}
+	}
+
+	if db := storeDB(s.store); db != nil {
+		stats := db.Stats()
+		s.metrics.dbConnections.set(float64(stats.InUse), "in_use")
+		s.metrics.dbConnections.set(float64(stats.Idle), "idle")
+		s.metrics.dbMaxOpen.set(float64(stats.MaxOpenConnections))
+		s.metrics.dbWaits.set(float64(stats.WaitCount))
+		s.metrics.dbWaitDuration.set(stats.WaitDuration.Seconds())
+	}
+	return nil
+}
+
+// metricsHandler writes the metrics in the Prometheus text format.  The
+// metrics token, or anonymous metrics, scrape every metric and an admin only
+// the series of its own tenant, without the requests and database pool
+// metrics every tenant shares.
+func (s *Server) metricsHandler(writer http.ResponseWriter, request *http.Request) {
+	token := s.config.MetricsToken != "" && subtle.ConstantTimeCompare([]byte(credentials(request)), []byte(s.config.MetricsToken)) == 1
+	if !token && !s.config.AnonymousMetrics {
+		s.authorize(func(writer http.ResponseWriter, request *http.Request) {
+			s.writeMetrics(writer, request, requestPrincipal(request).Tenant)
+		}, RoleAdmin)(writer, request)
+		return
+	}
+	s.writeMetrics(writer, request, "")
+}
+
+// writeMetrics writes the series of the tenant, or every metric when it is
+// empty.
+func (s *Server) writeMetrics(writer http.ResponseWriter, request *http.Request, tenantID string) {
+	s.metrics.collectMu.Lock()
+	defer s.metrics.collectMu.Unlock()
+	if err := s.collect(request.Context(), tenantID); err != nil {
+		formatError(writer, request, internalError(err, "Unable to collect metrics"))
+		return
+	}
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range s.metrics.all {
+		m.write(writer, tenantID)
+	}
+}
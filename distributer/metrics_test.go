@@ -0,0 +1,280 @@
+package distributer
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_metric_write(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric *metric
+		update func(m *metric)
+		want   []string
+	}{
+		{
+			name:   "Counter",
+			metric: newMetric(metricCounter, "test_total", "A test counter.", "code"),
+			update: func(m *metric) {
+				m.add(1, "200")
+				m.add(2, "200")
+				m.add(1, `5"0"0`)
+			},
+			want: []string{
+				"# HELP test_total A test counter.",
+				"# TYPE test_total counter",
+				`test_total{code="200"} 3`,
+				`test_total{code="5\"0\"0"} 1`,
+			},
+		},
+		{
+			name:   "Histogram",
+			metric: newMetric(metricHistogram, "test_seconds", "A test histogram."),
+			update: func(m *metric) {
+				m.observe(0.02)
+				m.observe(3)
+			},
+			want: []string{
+				`test_seconds_bucket{le="0.01"} 0`,
+				`test_seconds_bucket{le="0.025"} 1`,
+				`test_seconds_bucket{le="5"} 2`,
+				`test_seconds_bucket{le="+Inf"} 2`,
+				`test_seconds_sum 3.02`,
+				`test_seconds_count 2`,
+			},
+		},
+		{
+			name:   "Reset gauge",
+			metric: newMetric(metricGauge, "test_open", "A test gauge.", "agent"),
+			update: func(m *metric) {
+				m.set(4, "1000")
+				m.reset()
+				m.set(1, "1003")
+			},
+			want: []string{
+				`test_open{agent="1003"} 1`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update(tt.metric)
+			var b strings.Builder
+			tt.metric.write(&b, "")
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want+"\n") {
+					t.Errorf("metric.write() = %s, want %s", b.String(), want)
+				}
+			}
+			if tt.name == "Reset gauge" && strings.Contains(b.String(), "1000") {
+				t.Errorf("metric.write() = %s, want the reset series removed", b.String())
+			}
+		})
+	}
+}
+
+func Test_Server_metricsHandler(t *testing.T) {
+	s, err := NewServer(newTestStore(t), Config{MetricsToken: "scrape"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	serveTest(s, http.MethodGet, "/v1/unknown", submitter, "")
+	if err := s.store.CreateTenant(context.Background(), tenant{ID: "other", Name: "Other"}); err != nil {
+		t.Fatalf("Store.CreateTenant() error = %v", err)
+	}
+	if err := s.store.CreateAgent(context.Background(), "other", agent{ID: "2000", FirstName: "Other", LastName: "Agent"}); err != nil {
+		t.Fatalf("Store.CreateAgent() error = %v", err)
+	}
+	s.metrics.taskEvent("other", "created", task{Priorty: "urgent", Skills: []string{"secret"}})
+
+	if resp := serveTest(s, http.MethodGet, "/metrics", "", ""); resp.Code != http.StatusUnauthorized {
+		t.Errorf("GET /metrics without a token status = %v, want %v", resp.Code, http.StatusUnauthorized)
+	}
+	if resp := serveTest(s, http.MethodGet, "/metrics", submitter, ""); resp.Code != http.StatusForbidden {
+		t.Errorf("GET /metrics with a submitter key status = %v, want %v", resp.Code, http.StatusForbidden)
+	}
+	resp := serveTest(s, http.MethodGet, "/metrics", testAPIKey(t, s, RoleAdmin, ""), "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /metrics with an admin key status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if !strings.Contains(resp.Body.String(), `task_distributer_tasks_total{tenant="default",event="created",priority="low"} 3`) || strings.Contains(resp.Body.String(), `tenant="other"`) {
+		t.Errorf("GET /metrics with an admin key = %s, want only the admin's tenant", resp.Body.String())
+	}
+	// The requests and database pool are shared by every tenant.
+	for _, shared := range []string{"task_distributer_http_requests_total", "task_distributer_http_request_duration_seconds", "task_distributer_db_"} {
+		if strings.Contains(resp.Body.String(), shared) {
+			t.Errorf("GET /metrics with an admin key = %s, want no %s", resp.Body.String(), shared)
+		}
+	}
+	resp = serveTest(s, http.MethodGet, "/metrics", "scrape", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	for _, want := range []string{
+		`task_distributer_http_requests_total{handler="/v1/task/create",method="POST",code="200"} 2`,
+		`task_distributer_http_requests_total{handler="/v1/task/create",method="POST",code="409"} 1`,
+		`task_distributer_http_requests_total{handler="unmatched",method="GET",code="404"} 1`,
+		`task_distributer_http_request_duration_seconds_count{handler="/v1/task/create",method="POST"} 3`,
+		`task_distributer_tasks_total{tenant="default",event="created",priority="low"} 3`,
+		`task_distributer_tasks_total{tenant="default",event="assigned",priority="low"} 2`,
+		`task_distributer_tasks_total{tenant="default",event="rejected",priority="low"} 1`,
+		`task_distributer_task_skills_total{tenant="default",event="assigned",skill="skill1"} 2`,
+		`task_distributer_assignment_duration_seconds_count{tenant="default"} 3`,
+		`task_distributer_open_tasks{tenant="default",status="Assigned"} 2`,
+		`task_distributer_agent_open_tasks{tenant="default",agent="1000"} 1`,
+		`task_distributer_agent_open_tasks{tenant="default",agent="1001"} 0`,
+		`task_distributer_agent_open_tasks{tenant="other",agent="2000"} 0`,
+		`task_distributer_task_skills_total{tenant="other",event="created",skill="secret"} 1`,
+	} {
+		if !strings.Contains(resp.Body.String(), want+"\n") {
+			t.Errorf("GET /metrics = %s, want %s", resp.Body.String(), want)
+		}
+	}
+}
+
+func Test_Server_metricsHandler_anonymous(t *testing.T) {
+	s, err := NewServer(newTestStore(t), Config{AnonymousMetrics: true})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	resp := serveTest(s, http.MethodGet, "/metrics", "", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if want := `task_distributer_agent_open_tasks{tenant="default",agent="1000"} 0`; !strings.Contains(resp.Body.String(), want+"\n") {
+		t.Errorf("GET /metrics = %s, want %s", resp.Body.String(), want)
+	}
+}
+
+func Test_metric_write_format(t *testing.T) {
+	counter := newMetric(metricCounter, "test_total", "A \"test\" counter\nof C:\\ paths.", "code")
+	counter.add(1, "a\\b\"c\nd")
+	histogram := newMetric(metricHistogram, "test_seconds", "A test histogram.", "handler")
+	histogram.buckets = []float64{0.1, 1}
+	for _, v := range []float64{0.0625, 0.5, 2} {
+		histogram.observe(v, "/v1/task")
+	}
+	tests := []struct {
+		name   string
+		metric *metric
+		want   string
+	}{
+		{
+			name:   "Escaped help and label values",
+			metric: counter,
+			want: `# HELP test_total A "test" counter\nof C:\\ paths.
+# TYPE test_total counter
+test_total{code="a\\b\"c\nd"} 1
+`,
+		},
+		{
+			name:   "Cumulative histogram buckets",
+			metric: histogram,
+			want: `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{handler="/v1/task",le="0.1"} 1
+test_seconds_bucket{handler="/v1/task",le="1"} 2
+test_seconds_bucket{handler="/v1/task",le="+Inf"} 3
+test_seconds_sum{handler="/v1/task"} 2.5625
+test_seconds_count{handler="/v1/task"} 3
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.metric.write(&b, "")
+			if b.String() != tt.want {
+				t.Errorf("metric.write() = %s, want %s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+// sampleLine is a sample of the Prometheus text format, the metric name, the
+// labels with their escaped values and the value.
+var sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(?:\{((?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\[\\"n])*",?)*)\})? (\S+)$`)
+
+// Test_Server_metricsHandler_format checks every line of the metrics follows
+// the Prometheus text format: the HELP and TYPE of each metric once before
+// its samples, valid names, labels and values, and histograms with
+// cumulative buckets ending with +Inf at the count.
+func Test_Server_metricsHandler_format(t *testing.T) {
+	s, err := NewServer(newTestStore(t), Config{MetricsToken: "scrape"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+	serveTest(s, http.MethodPost, "/v1/task/create", submitter, `{"name":"Test Name","skills":["skill1"],"priority":"low"}`)
+	resp := serveTest(s, http.MethodGet, "/metrics", "scrape", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %v, want %v %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+
+	help, types := map[string]bool{}, map[string]string{}
+	// buckets are the last bucket of each histogram series, by its labels
+	// other than le.
+	buckets := map[string]float64{}
+	for _, line := range strings.Split(strings.TrimSuffix(resp.Body.String(), "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			switch {
+			case len(fields) != 4:
+				t.Errorf("GET /metrics line %q is not a HELP or TYPE comment", line)
+			case fields[1] == "HELP" && !help[fields[2]] && types[fields[2]] == "":
+				help[fields[2]] = true
+			case fields[1] == "TYPE" && help[fields[2]] && types[fields[2]] == "":
+				types[fields[2]] = fields[3]
+			default:
+				t.Errorf("GET /metrics line %q is not the first HELP then TYPE of %s", line, fields[2])
+			}
+			continue
+		}
+		match := sampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Errorf("GET /metrics line %q is not a sample", line)
+			continue
+		}
+		name, labels := match[1], match[2]
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			t.Errorf("GET /metrics line %q value is not a float", line)
+		}
+		family := name
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if base, ok := strings.CutSuffix(name, suffix); ok && types[base] == metricHistogram {
+				family = base
+			}
+		}
+		switch {
+		case types[family] == "":
+			t.Errorf("GET /metrics line %q is not after the TYPE of %s", line, family)
+		case types[family] != metricHistogram:
+		case strings.HasSuffix(name, "_bucket"):
+			series, le, _ := strings.Cut(labels, `le="`)
+			series = strings.TrimSuffix(series, ",")
+			if value < buckets[family+series] {
+				t.Errorf("GET /metrics line %q bucket is less than the one before", line)
+			}
+			buckets[family+series] = value
+			if le == `+Inf"` {
+				buckets[family+series+"+Inf"] = value
+			}
+		case strings.HasSuffix(name, "_count"):
+			if inf, ok := buckets[family+labels+"+Inf"]; !ok || value != inf {
+				t.Errorf("GET /metrics line %q count is not the +Inf bucket", line)
+			}
+		}
+	}
+	if types["task_distributer_http_request_duration_seconds"] != metricHistogram {
+		t.Errorf("GET /metrics types = %v, want the request duration histogram", types)
+	}
+}
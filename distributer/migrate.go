@@ -0,0 +1,299 @@
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles are the ordered schema migrations of each dialect, named like
+// postgres/0001_create_tables.up.sql and postgres/0001_create_tables.down.sql.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// The SQL dialects, each with its own migrations directory.
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+// migrationLockID is the Postgres advisory lock held while migrating, so only
+// one release can migrate at a time.
+const migrationLockID = 4242019
+
+// migration is a version of the schema.
+type migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// migrationStatus is a migration and when it was applied, if it has been.
+type migrationStatus struct {
+	migration
+	AppliedTime time.Time
+}
+
+// loadMigrations reads the migrations ordered by version.  Every migration must
+// have both an up and down file.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, file := range files {
+		name := file.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		sep := strings.Index(base, "_")
+		if sep < 1 {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>.%s.sql", name, direction)
+		}
+		version, err := strconv.Atoi(base[:sep])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s version must be a positive number", name)
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, has := byVersion[version]
+		if !has {
+			m = &migration{
+				Version: version,
+				Name:    base[sep+1:],
+			}
+			byVersion[version] = m
+		}
+		if m.Name != base[sep+1:] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, base[sep+1:])
+		}
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have an up and down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrator applies the migrations to the database, recording each applied
+// version in the schema_migrations table.
+type migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []migration
+}
+
+func newMigrator(db *sql.DB, dialect string) (*migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+	return &migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// storeMigrator returns the migrator for the database of the store, only the
+// SQL stores have migrations.
+func storeMigrator(store Store) (*migrator, error) {
+	switch s := store.(type) {
+	case *postgresStore:
+		return newMigrator(s.db, dialectPostgres)
+	case *sqliteStore:
+		return newMigrator(s.db, dialectSQLite)
+	case *tracedStore:
+		return storeMigrator(s.store)
+	default:
+		return nil, errors.New("migrations are only supported by the Postgres and SQLite stores")
+	}
+}
+
+// latest is the version of the schema once every migration is applied.
+func (m *migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// version returns the latest applied migration, without taking the lock so it
+// can be checked while a release is migrating.
+func (m *migrator) version(ctx context.Context) (int, error) {
+	var version int
+	err := m.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(VERSION), 0) FROM SCHEMA_MIGRATIONS`).Scan(&version)
+	return version, err
+}
+
+// locked runs fn on a connection holding the migration advisory lock.  SQLite
+// has no advisory locks, its database is only used by one process.
+func (m *migrator) locked(ctx context.Context, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == dialectPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("unable to lock migrations %s", err.Error())
+		}
+		// The lock is held by the session, which outlives a canceled context
+		// when the connection goes back to the pool.
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	stmt := `
+	CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS(
+		VERSION BIGINT NOT NULL,
+		NAME TEXT NOT NULL,
+		APPLIEDDATE TIMESTAMP NOT NULL,
+		PRIMARY KEY(VERSION)
+	)
+	`
+	if _, err := conn.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("unable to create schema_migrations %s", err.Error())
+	}
+	return fn(ctx, conn)
+}
+
+func (m *migrator) applied(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT VERSION, APPLIEDDATE FROM SCHEMA_MIGRATIONS`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var date time.Time
+		if err := rows.Scan(&version, &date); err != nil {
+			return nil, err
+		}
+		applied[version] = date
+	}
+	return applied, rows.Err()
+}
+
+// up applies every migration not yet applied, up to and including the target
+// version.  A target of zero applies all of them.
+func (m *migrator) up(ctx context.Context, target int) ([]migration, error) {
+	var done []migration
+	err := m.locked(ctx, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if _, has := applied[mig.Version]; has {
+				continue
+			}
+			err := m.apply(ctx, conn, mig.up, `INSERT INTO SCHEMA_MIGRATIONS (VERSION, NAME, APPLIEDDATE) VALUES ($1, $2, $3)`, mig.Version, mig.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed %s", mig.Version, mig.Name, err.Error())
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// down reverts the latest applied migrations, steps at a time.
+func (m *migrator) down(ctx context.Context, steps int) ([]migration, error) {
+	var done []migration
+	err := m.locked(ctx, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for idx := len(m.migrations) - 1; idx >= 0 && len(done) < steps; idx-- {
+			mig := m.migrations[idx]
+			if _, has := applied[mig.Version]; !has {
+				continue
+			}
+			err := m.apply(ctx, conn, mig.down, `DELETE FROM SCHEMA_MIGRATIONS WHERE VERSION = $1`, mig.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed %s", mig.Version, mig.Name, err.Error())
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// apply runs the migration and records it in a single transaction.
+func (m *migrator) apply(ctx context.Context, conn *sql.Conn, stmt, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// status returns every migration and when it was applied.
+func (m *migrator) status(ctx context.Context) ([]migrationStatus, error) {
+	var statuses []migrationStatus
+	err := m.locked(ctx, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			statuses = append(statuses, migrationStatus{
+				migration:   mig,
+				AppliedTime: applied[mig.Version],
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
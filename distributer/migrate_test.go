@@ -0,0 +1,269 @@
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []migration
+		wantErr bool
+	}{
+		{
+			name: "Ordered by version",
+			fsys: fstest.MapFS{
+				"migrations/0002_seed.up.sql":     {Data: []byte("INSERT")},
+				"migrations/0002_seed.down.sql":   {Data: []byte("DELETE")},
+				"migrations/0001_create.up.sql":   {Data: []byte("CREATE")},
+				"migrations/0001_create.down.sql": {Data: []byte("DROP")},
+				"migrations/README.md":            {Data: []byte("ignored")},
+			},
+			want: []migration{
+				{Version: 1, Name: "create", up: "CREATE", down: "DROP"},
+				{Version: 2, Name: "seed", up: "INSERT", down: "DELETE"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing down",
+			fsys: fstest.MapFS{
+				"migrations/0001_create.up.sql": {Data: []byte("CREATE")},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Duplicate version",
+			fsys: fstest.MapFS{
+				"migrations/0001_create.up.sql":   {Data: []byte("CREATE")},
+				"migrations/0001_create.down.sql": {Data: []byte("DROP")},
+				"migrations/0001_seed.up.sql":     {Data: []byte("INSERT")},
+				"migrations/0001_seed.down.sql":   {Data: []byte("DELETE")},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "No version",
+			fsys: fstest.MapFS{
+				"migrations/create.up.sql":   {Data: []byte("CREATE")},
+				"migrations/create.down.sql": {Data: []byte("DROP")},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.fsys, "migrations")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_embeddedMigrations(t *testing.T) {
+	for _, dialect := range []string{dialectPostgres, dialectSQLite} {
+		m, err := newMigrator(nil, dialect)
+		if err != nil {
+			t.Fatalf("newMigrator(%s) error = %v", dialect, err)
+		}
+		if len(m.migrations) == 0 {
+			t.Errorf("newMigrator(%s) has no migrations", dialect)
+		}
+		for idx, mig := range m.migrations {
+			if mig.Version != idx+1 {
+				t.Errorf("%s migration %s version = %d, want %d", dialect, mig.Name, mig.Version, idx+1)
+			}
+		}
+	}
+}
+
+func Test_migrator_up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	m := &migrator{
+		db:      db,
+		dialect: dialectPostgres,
+		migrations: []migration{
+			{Version: 1, Name: "create", up: "CREATE TABLE ONE", down: "DROP TABLE ONE"},
+			{Version: 2, Name: "seed", up: "INSERT INTO ONE", down: "DELETE FROM ONE"},
+		},
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT VERSION, APPLIEDDATE FROM SCHEMA_MIGRATIONS`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applieddate"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO ONE`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO SCHEMA_MIGRATIONS`).WithArgs(2, "seed", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := m.up(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("migrator.up() error = %v", err)
+	}
+	if len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("migrator.up() = %v, want version 2 applied", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("migrator.up() %v", err)
+	}
+}
+
+func Test_migrator_sqlite(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	m, err := storeMigrator(s)
+	if err != nil {
+		t.Fatalf("storeMigrator() error = %v", err)
+	}
+	done, err := m.down(context.Background(), m.latest())
+	if err != nil {
+		t.Fatalf("migrator.down() error = %v", err)
+	}
+	if len(done) != m.latest() {
+		t.Errorf("migrator.down() = %v, want every migration reverted", done)
+	}
+	done, err = m.up(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("migrator.up() error = %v", err)
+	}
+	if len(done) != m.latest() {
+		t.Errorf("migrator.up() = %v, want every migration applied", done)
+	}
+	statuses, err := m.status(context.Background())
+	if err != nil {
+		t.Fatalf("migrator.status() error = %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedTime.IsZero() {
+			t.Errorf("migration %d_%s is not applied", status.Version, status.Name)
+		}
+	}
+	if _, err := s.MatchingAgents(context.Background(), DefaultTenant, []string{"skill1"}); err != nil {
+		t.Errorf("Store.MatchingAgents() error = %v, want the seed data", err)
+	}
+}
+
+// baselineSchema is the schema init.sql created before tenants and migrations.
+const baselineSchema = `
+CREATE TABLE SKILLS(
+    SKILL VARCHAR(100) NOT NULL,
+    DESCRIPTION TEXT NOT NULL,
+    PRIMARY KEY(SKILL)
+);
+CREATE TABLE AGENTS(
+    ID VARCHAR(10) NOT NULL,
+    FIRSTNAME VARCHAR(100) NOT NULL,
+    LASTNAME VARCHAR(100) NOT NULL,
+    PRIMARY KEY(ID)
+);
+CREATE TABLE AGENTSKILLS(
+    ID VARCHAR(10) NOT NULL,
+    SKILL VARCHAR(100) REFERENCES SKILLS(SKILL),
+    AGENT VARCHAR(10) REFERENCES AGENTS(ID),
+    PRIMARY KEY(ID)
+);
+CREATE TABLE PRIORITIES(
+    PRIORITY VARCHAR(100) NOT NULL,
+    PRIORITY_LEVEL INT NOT NULL,
+    PRIMARY KEY(PRIORITY)
+);
+CREATE TABLE TASKS(
+    ID VARCHAR(100) NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    NAME TEXT NOT NULL,
+    SKILLS TEXT[],
+    PRIORITY VARCHAR(100) REFERENCES PRIORITIES(PRIORITY),
+    STATUS VARCHAR(100) NOT NULL,
+    COMPLETEDATE TIMESTAMP,
+    AGENT VARCHAR(10) REFERENCES AGENTS(ID)
+);
+INSERT INTO SKILLS (SKILL, DESCRIPTION) VALUES ('skill1', 'This is a great skill to have');
+INSERT INTO AGENTS (ID, FIRSTNAME, LASTNAME) VALUES ('1000', 'Bighead', 'Burton');
+INSERT INTO AGENTSKILLS (ID, SKILL, AGENT) VALUES ('2000', 'skill1', '1000');
+INSERT INTO PRIORITIES (PRIORITY, PRIORITY_LEVEL) VALUES ('low', 0);
+INSERT INTO TASKS (ID, CREATEDATE, NAME, SKILLS, PRIORITY, STATUS, AGENT) VALUES ('a', now(), 'Task', '{skill1}', 'low', 'assigned', '1000');
+`
+
+// Test_migrator_postgres_baseline migrates a database created by init.sql,
+// it needs a Postgres URL like postgres://localhost/test?sslmode=disable in
+// TEST_POSTGRES_URL and runs in a schema of its own.
+func Test_migrator_postgres_baseline(t *testing.T) {
+	databaseURL := os.Getenv("TEST_POSTGRES_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	ctx := context.Background()
+	admin, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("baseline_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, `CREATE SCHEMA `+schema); err != nil {
+		t.Fatalf("CREATE SCHEMA error = %v", err)
+	}
+	defer admin.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`)
+
+	sep := "?"
+	if strings.Contains(databaseURL, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("postgres", databaseURL+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, baselineSchema); err != nil {
+		t.Fatalf("baseline schema error = %v", err)
+	}
+
+	m, err := newMigrator(db, dialectPostgres)
+	if err != nil {
+		t.Fatalf("newMigrator() error = %v", err)
+	}
+	if _, err := m.up(ctx, 0); err != nil {
+		t.Fatalf("migrator.up() error = %v", err)
+	}
+	s := newPostgresStore(db)
+	agents, err := s.MatchingAgents(ctx, DefaultTenant, []string{"skill1"})
+	if err != nil || len(agents) != 1 || agents[0].ID != "1000" {
+		t.Errorf("Store.MatchingAgents() = %v, %v, want the baseline agent", agents, err)
+	}
+	tasks, err := s.Tasks(ctx, DefaultTenant, taskFilter{})
+	if err != nil || len(tasks) != 1 || tasks[0].ID != "a" || !reflect.DeepEqual(tasks[0].Skills, []string{"skill1"}) {
+		t.Errorf("Store.Tasks() = %v, %v, want the baseline task with its skills", tasks, err)
+	}
+
+	// Every migration can be reverted and applied again.
+	if _, err := m.down(ctx, m.latest()); err != nil {
+		t.Fatalf("migrator.down() error = %v", err)
+	}
+	if _, err := m.up(ctx, 0); err != nil {
+		t.Fatalf("migrator.up() again error = %v", err)
+	}
+}
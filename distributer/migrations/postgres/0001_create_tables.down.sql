@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS APIKEYS;
+DROP TABLE IF EXISTS TASKS;
+DROP TABLE IF EXISTS PRIORITIES;
+DROP TABLE IF EXISTS AGENTSKILLS;
+DROP TABLE IF EXISTS AGENTS;
+DROP TABLE IF EXISTS SKILLS;
+DROP TABLE IF EXISTS TENANTS;
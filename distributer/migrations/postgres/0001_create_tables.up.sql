@@ -0,0 +1,118 @@
+CREATE TABLE IF NOT EXISTS TENANTS(
+    ID VARCHAR(100) NOT NULL,
+    NAME TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(ID)
+);
+
+INSERT INTO TENANTS (ID, NAME, CREATEDATE) VALUES ('default', 'Default', now()) ON CONFLICT (ID) DO NOTHING;
+
+-- A database created by init.sql before tenants has these tables without a
+-- TENANT column, every row of them is moved to the default tenant.  The keys
+-- include the tenant so a tenant's tasks can only reference the tenant's own
+-- agents and priorities.
+DO $$
+BEGIN
+IF EXISTS(SELECT * FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'agents')
+AND NOT EXISTS(SELECT * FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'agents' AND column_name = 'tenant') THEN
+ALTER TABLE IF EXISTS APIKEYS DROP CONSTRAINT IF EXISTS apikeys_agent_fkey;
+ALTER TABLE AGENTSKILLS DROP CONSTRAINT IF EXISTS agentskills_skill_fkey;
+ALTER TABLE AGENTSKILLS DROP CONSTRAINT IF EXISTS agentskills_agent_fkey;
+ALTER TABLE TASKS DROP CONSTRAINT IF EXISTS tasks_priority_fkey;
+ALTER TABLE TASKS DROP CONSTRAINT IF EXISTS tasks_agent_fkey;
+
+ALTER TABLE SKILLS ADD COLUMN TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+ALTER TABLE AGENTS ADD COLUMN TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+ALTER TABLE AGENTSKILLS ADD COLUMN TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+ALTER TABLE PRIORITIES ADD COLUMN TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+ALTER TABLE TASKS ADD COLUMN TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+
+ALTER TABLE SKILLS DROP CONSTRAINT skills_pkey, ADD PRIMARY KEY(TENANT, SKILL);
+ALTER TABLE AGENTS DROP CONSTRAINT agents_pkey, ADD PRIMARY KEY(TENANT, ID);
+ALTER TABLE PRIORITIES DROP CONSTRAINT priorities_pkey, ADD PRIMARY KEY(TENANT, PRIORITY);
+
+ALTER TABLE AGENTSKILLS
+    ADD FOREIGN KEY(TENANT, SKILL) REFERENCES SKILLS(TENANT, SKILL),
+    ADD FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID);
+ALTER TABLE TASKS
+    ADD FOREIGN KEY(TENANT, PRIORITY) REFERENCES PRIORITIES(TENANT, PRIORITY),
+    ADD FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID);
+END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS SKILLS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    SKILL VARCHAR(100) NOT NULL,
+    DESCRIPTION TEXT NOT NULL,
+    PRIMARY KEY(TENANT, SKILL)
+);
+
+CREATE TABLE IF NOT EXISTS AGENTS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(10) NOT NULL,
+    FIRSTNAME VARCHAR(100) NOT NULL,
+    LASTNAME VARCHAR(100) NOT NULL,
+    PRIMARY KEY(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS AGENTSKILLS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(100) NOT NULL,
+    SKILL VARCHAR(100),
+    AGENT VARCHAR(10),
+    PRIMARY KEY(ID),
+    FOREIGN KEY(TENANT, SKILL) REFERENCES SKILLS(TENANT, SKILL),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS PRIORITIES(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    PRIORITY VARCHAR(100) NOT NULL,
+    PRIORITY_LEVEL INT NOT NULL,
+    PRIMARY KEY(TENANT, PRIORITY)
+);
+
+CREATE TABLE IF NOT EXISTS TASKS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(100) NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    NAME TEXT NOT NULL,
+    SKILLS TEXT[],
+    PRIORITY VARCHAR(100),
+    STATUS VARCHAR(100) NOT NULL,
+    COMPLETEDATE TIMESTAMP,
+    AGENT VARCHAR(10),
+    RESULT TEXT,
+    FOREIGN KEY(TENANT, PRIORITY) REFERENCES PRIORITIES(TENANT, PRIORITY),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS APIKEYS(
+    ID VARCHAR(100) NOT NULL,
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    NAME TEXT NOT NULL,
+    HASH VARCHAR(64) NOT NULL UNIQUE,
+    ROLE VARCHAR(20) NOT NULL,
+    AGENT VARCHAR(10),
+    CREATEDATE TIMESTAMP NOT NULL,
+    REVOKEDATE TIMESTAMP,
+    PRIMARY KEY(ID),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
+
+-- The init.sql tables have no RESULT, a shorter AGENTSKILLS ID and API keys
+-- without a tenant.
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS RESULT TEXT;
+
+ALTER TABLE AGENTSKILLS ALTER COLUMN ID TYPE VARCHAR(100);
+
+ALTER TABLE APIKEYS ADD COLUMN IF NOT EXISTS TENANT VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES TENANTS(ID);
+
+DO $$
+BEGIN
+IF NOT EXISTS(SELECT * FROM information_schema.table_constraints WHERE table_schema = current_schema() AND table_name = 'apikeys' AND constraint_name = 'apikeys_tenant_agent_fkey') THEN
+ALTER TABLE APIKEYS ADD CONSTRAINT apikeys_tenant_agent_fkey FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID);
+END IF;
+END
+$$;
@@ -0,0 +1,11 @@
+ALTER TABLE TASKS ADD COLUMN SKILLS TEXT[];
+
+UPDATE TASKS SET SKILLS = ARRAY(
+    SELECT SKILL FROM TASKSKILLS
+    WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID
+    ORDER BY SKILL
+);
+
+DROP TABLE IF EXISTS TASKSKILLS;
+
+ALTER TABLE TASKS DROP CONSTRAINT IF EXISTS tasks_pkey;
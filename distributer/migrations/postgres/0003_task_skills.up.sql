@@ -0,0 +1,17 @@
+ALTER TABLE TASKS ADD PRIMARY KEY (TENANT, ID);
+
+CREATE TABLE IF NOT EXISTS TASKSKILLS(
+    TENANT VARCHAR(100) NOT NULL,
+    TASK VARCHAR(100) NOT NULL,
+    SKILL VARCHAR(100) NOT NULL,
+    PRIMARY KEY(TENANT, TASK, SKILL),
+    FOREIGN KEY(TENANT, TASK) REFERENCES TASKS(TENANT, ID),
+    FOREIGN KEY(TENANT, SKILL) REFERENCES SKILLS(TENANT, SKILL)
+);
+
+INSERT INTO TASKSKILLS (TENANT, TASK, SKILL)
+SELECT DISTINCT TASKS.TENANT, TASKS.ID, SKILLS.SKILL
+FROM TASKS
+INNER JOIN SKILLS ON SKILLS.TENANT = TASKS.TENANT AND SKILLS.SKILL = ANY(TASKS.SKILLS);
+
+ALTER TABLE TASKS DROP COLUMN SKILLS;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS EVENTS(
+    ID BIGSERIAL NOT NULL,
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    TYPE VARCHAR(100) NOT NULL,
+    TASK VARCHAR(100) NOT NULL,
+    AGENT VARCHAR(10),
+    STATUS VARCHAR(100) NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(ID),
+    FOREIGN KEY(TENANT, TASK) REFERENCES TASKS(TENANT, ID)
+);
+
+CREATE INDEX IF NOT EXISTS EVENTS_TENANT_ID ON EVENTS(TENANT, ID);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS TASKS_TAGS;
+DROP INDEX IF EXISTS TASKS_METADATA;
+
+ALTER TABLE TASKS DROP COLUMN IF EXISTS EXTERNALURL;
+ALTER TABLE TASKS DROP COLUMN IF EXISTS TAGS;
+ALTER TABLE TASKS DROP COLUMN IF EXISTS METADATA;
+ALTER TABLE TASKS DROP COLUMN IF EXISTS DESCRIPTION;
@@ -0,0 +1,7 @@
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS DESCRIPTION TEXT;
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS METADATA JSONB;
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS TAGS TEXT[];
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS EXTERNALURL TEXT;
+
+CREATE INDEX IF NOT EXISTS TASKS_METADATA ON TASKS USING GIN (METADATA jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS TASKS_TAGS ON TASKS USING GIN (TAGS);
@@ -0,0 +1,17 @@
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS OUTCOME VARCHAR(50);
+ALTER TABLE TASKS ADD COLUMN IF NOT EXISTS RESULTDATA JSONB;
+
+CREATE TABLE IF NOT EXISTS TASKCOMMENTS(
+    TENANT VARCHAR(100) NOT NULL,
+    ID VARCHAR(100) NOT NULL,
+    TASK VARCHAR(100) NOT NULL,
+    AUTHOR TEXT NOT NULL,
+    ROLE VARCHAR(20) NOT NULL,
+    AGENT VARCHAR(10),
+    BODY TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(TENANT, ID),
+    FOREIGN KEY(TENANT, TASK) REFERENCES TASKS(TENANT, ID)
+);
+
+CREATE INDEX IF NOT EXISTS TASKCOMMENTS_TASK ON TASKCOMMENTS(TENANT, TASK, CREATEDATE);
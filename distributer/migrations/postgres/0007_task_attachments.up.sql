@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS TASKATTACHMENTS(
+    TENANT VARCHAR(100) NOT NULL,
+    ID VARCHAR(100) NOT NULL,
+    TASK VARCHAR(100) NOT NULL,
+    NAME TEXT NOT NULL,
+    CONTENTTYPE VARCHAR(100) NOT NULL,
+    SIZE BIGINT NOT NULL,
+    SHA256 CHAR(64) NOT NULL,
+    AUTHOR TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(TENANT, ID),
+    FOREIGN KEY(TENANT, TASK) REFERENCES TASKS(TENANT, ID)
+);
+
+CREATE INDEX IF NOT EXISTS TASKATTACHMENTS_TASK ON TASKATTACHMENTS(TENANT, TASK, CREATEDATE);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS SCHEDULES(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(100) NOT NULL,
+    TASK JSONB NOT NULL,
+    CRON VARCHAR(100),
+    STARTAT TIMESTAMP,
+    PAUSED BOOLEAN NOT NULL DEFAULT FALSE,
+    NEXTRUN TIMESTAMP,
+    LASTRUN TIMESTAMP,
+    LASTTASK VARCHAR(100),
+    LASTERROR TEXT,
+    AUTHOR TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(TENANT, ID)
+);
+
+CREATE INDEX IF NOT EXISTS SCHEDULES_NEXTRUN ON SCHEDULES(NEXTRUN) WHERE NOT PAUSED;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS TEMPLATES;
+
+ALTER TABLE TASKS DROP COLUMN IF EXISTS SLA;
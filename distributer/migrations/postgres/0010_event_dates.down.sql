@@ -0,0 +1 @@
+DROP INDEX IF EXISTS EVENTS_TENANT_CREATEDATE;
@@ -0,0 +1 @@
+UPDATE TASKS SET COMPLETEDATE = NULL WHERE STATUS = 'Cancelled';
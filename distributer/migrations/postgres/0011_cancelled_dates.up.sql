@@ -0,0 +1,5 @@
+UPDATE TASKS SET COMPLETEDATE = COALESCE(
+    (SELECT MAX(EVENTS.CREATEDATE) FROM EVENTS WHERE EVENTS.TENANT = TASKS.TENANT AND EVENTS.TASK = TASKS.ID AND EVENTS.TYPE = 'task.cancelled'),
+    TASKS.CREATEDATE
+)
+WHERE STATUS = 'Cancelled' AND COMPLETEDATE IS NULL;
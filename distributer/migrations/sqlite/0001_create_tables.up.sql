@@ -0,0 +1,77 @@
+CREATE TABLE IF NOT EXISTS TENANTS(
+    ID VARCHAR(100) NOT NULL,
+    NAME TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(ID)
+);
+
+INSERT OR IGNORE INTO TENANTS (ID, NAME, CREATEDATE) VALUES ('default', 'Default', CURRENT_TIMESTAMP);
+
+CREATE TABLE IF NOT EXISTS SKILLS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    SKILL VARCHAR(100) NOT NULL,
+    DESCRIPTION TEXT NOT NULL,
+    PRIMARY KEY(TENANT, SKILL)
+);
+
+CREATE TABLE IF NOT EXISTS AGENTS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(10) NOT NULL,
+    FIRSTNAME VARCHAR(100) NOT NULL,
+    LASTNAME VARCHAR(100) NOT NULL,
+    PRIMARY KEY(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS AGENTSKILLS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(100) NOT NULL,
+    SKILL VARCHAR(100),
+    AGENT VARCHAR(10),
+    PRIMARY KEY(ID),
+    FOREIGN KEY(TENANT, SKILL) REFERENCES SKILLS(TENANT, SKILL),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS PRIORITIES(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    PRIORITY VARCHAR(100) NOT NULL,
+    PRIORITY_LEVEL INT NOT NULL,
+    PRIMARY KEY(TENANT, PRIORITY)
+);
+
+CREATE TABLE IF NOT EXISTS TASKS(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    ID VARCHAR(100) NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    NAME TEXT NOT NULL,
+    PRIORITY VARCHAR(100),
+    STATUS VARCHAR(100) NOT NULL,
+    COMPLETEDATE TIMESTAMP,
+    AGENT VARCHAR(10),
+    RESULT TEXT,
+    PRIMARY KEY(TENANT, ID),
+    FOREIGN KEY(TENANT, PRIORITY) REFERENCES PRIORITIES(TENANT, PRIORITY),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
+
+CREATE TABLE IF NOT EXISTS TASKSKILLS(
+    TENANT VARCHAR(100) NOT NULL,
+    TASK VARCHAR(100) NOT NULL,
+    SKILL VARCHAR(100) NOT NULL,
+    PRIMARY KEY(TENANT, TASK, SKILL),
+    FOREIGN KEY(TENANT, TASK) REFERENCES TASKS(TENANT, ID),
+    FOREIGN KEY(TENANT, SKILL) REFERENCES SKILLS(TENANT, SKILL)
+);
+
+CREATE TABLE IF NOT EXISTS APIKEYS(
+    ID VARCHAR(100) NOT NULL,
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    NAME TEXT NOT NULL,
+    HASH VARCHAR(64) NOT NULL UNIQUE,
+    ROLE VARCHAR(20) NOT NULL,
+    AGENT VARCHAR(10),
+    CREATEDATE TIMESTAMP NOT NULL,
+    REVOKEDATE TIMESTAMP,
+    PRIMARY KEY(ID),
+    FOREIGN KEY(TENANT, AGENT) REFERENCES AGENTS(TENANT, ID)
+);
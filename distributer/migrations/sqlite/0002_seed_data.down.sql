@@ -0,0 +1,8 @@
+DELETE FROM AGENTSKILLS WHERE TENANT = 'default' AND ID IN ('2000', '2001', '2002', '2003', '2004', '2005');
+DELETE FROM AGENTS WHERE TENANT = 'default' AND ID IN ('1000', '1001', '1002', '1003')
+    AND NOT EXISTS(SELECT * FROM TASKS WHERE TASKS.TENANT = AGENTS.TENANT AND TASKS.AGENT = AGENTS.ID)
+    AND NOT EXISTS(SELECT * FROM APIKEYS WHERE APIKEYS.TENANT = AGENTS.TENANT AND APIKEYS.AGENT = AGENTS.ID);
+DELETE FROM SKILLS WHERE TENANT = 'default' AND SKILL IN ('skill1', 'skill2', 'skill3')
+    AND NOT EXISTS(SELECT * FROM AGENTSKILLS WHERE AGENTSKILLS.TENANT = SKILLS.TENANT AND AGENTSKILLS.SKILL = SKILLS.SKILL);
+DELETE FROM PRIORITIES WHERE TENANT = 'default' AND PRIORITY IN ('low', 'high')
+    AND NOT EXISTS(SELECT * FROM TASKS WHERE TASKS.TENANT = PRIORITIES.TENANT AND TASKS.PRIORITY = PRIORITIES.PRIORITY);
@@ -0,0 +1,34 @@
+INSERT INTO SKILLS
+    (TENANT, SKILL, DESCRIPTION)
+SELECT * FROM (VALUES
+    ('default', 'skill1', 'This is a great skill to have'),
+    ('default', 'skill2', 'This is a awesome skill to have'),
+    ('default', 'skill3', 'This is a cool skill to have'))
+WHERE NOT EXISTS(SELECT * FROM SKILLS);
+
+INSERT INTO AGENTS
+    (TENANT, ID, FIRSTNAME, LASTNAME)
+SELECT * FROM (VALUES
+    ('default', '1000', 'Bighead', 'Burton'),
+    ('default', '1001', 'Ovaltine', 'Jenkins'),
+    ('default', '1002', 'Ground', 'Control'),
+    ('default', '1003', 'Jazz', 'Hands'))
+WHERE NOT EXISTS(SELECT * FROM AGENTS);
+
+INSERT INTO AGENTSKILLS
+    (TENANT, ID, SKILL, AGENT)
+SELECT * FROM (VALUES
+    ('default', '2000', 'skill1', '1000'),
+    ('default', '2001', 'skill2', '1001'),
+    ('default', '2002', 'skill3', '1001'),
+    ('default', '2003', 'skill3', '1002'),
+    ('default', '2004', 'skill1', '1003'),
+    ('default', '2005', 'skill3', '1003'))
+WHERE NOT EXISTS(SELECT * FROM AGENTSKILLS);
+
+INSERT INTO PRIORITIES
+    (TENANT, PRIORITY, PRIORITY_LEVEL)
+SELECT * FROM (VALUES
+    ('default', 'low', 0),
+    ('default', 'high', 1))
+WHERE NOT EXISTS(SELECT * FROM PRIORITIES);
@@ -0,0 +1,4 @@
+ALTER TABLE TASKS DROP COLUMN EXTERNALURL;
+ALTER TABLE TASKS DROP COLUMN TAGS;
+ALTER TABLE TASKS DROP COLUMN METADATA;
+ALTER TABLE TASKS DROP COLUMN DESCRIPTION;
@@ -0,0 +1,4 @@
+ALTER TABLE TASKS ADD COLUMN DESCRIPTION TEXT;
+ALTER TABLE TASKS ADD COLUMN METADATA TEXT;
+ALTER TABLE TASKS ADD COLUMN TAGS TEXT;
+ALTER TABLE TASKS ADD COLUMN EXTERNALURL TEXT;
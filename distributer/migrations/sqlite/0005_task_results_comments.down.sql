@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS TASKCOMMENTS;
+
+ALTER TABLE TASKS DROP COLUMN RESULTDATA;
+ALTER TABLE TASKS DROP COLUMN OUTCOME;
@@ -0,0 +1,10 @@
+ALTER TABLE TASKS ADD COLUMN SLA VARCHAR(50);
+
+CREATE TABLE IF NOT EXISTS TEMPLATES(
+    TENANT VARCHAR(100) NOT NULL REFERENCES TENANTS(ID),
+    NAME VARCHAR(100) NOT NULL,
+    DEFAULTS TEXT NOT NULL,
+    AUTHOR TEXT NOT NULL,
+    CREATEDATE TIMESTAMP NOT NULL,
+    PRIMARY KEY(TENANT, NAME)
+);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS EVENTS_TENANT_CREATEDATE ON EVENTS(TENANT, CREATEDATE);
@@ -0,0 +1,1109 @@
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/xid"
+)
+
+var _ Store = (*postgresStore)(nil)
+
+// postgresStore is the Store backed by the Postgres database.
+type postgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(db *sql.DB) *postgresStore {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+// postgresTaskDetails selects the details of the task, scanned by a
+// taskDetailsScanner.
+const postgresTaskDetails = `Description, Metadata, array_to_json(Tags), ExternalURL, SLA`
+
+func (s *postgresStore) Tenants(ctx context.Context) ([]tenant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT ID, NAME, CREATEDATE FROM TENANTS ORDER BY ID`)
+	if err != nil {
+		logQueryError(ctx, "Tenants", err)
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := []tenant{}
+	for rows.Next() {
+		var t tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreateTime); err != nil {
+			return nil, errors.New("unable to retrieve tenants")
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+func (s *postgresStore) CreateTenant(ctx context.Context, t tenant) error {
+	stmt := `INSERT INTO TENANTS (ID, NAME, CREATEDATE) VALUES ($1, $2, $3)`
+	if _, err := s.db.ExecContext(ctx, stmt, t.ID, t.Name, t.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateTenant", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) SkillCount(ctx context.Context, tenant string, skills []string) (int, error) {
+	stmt := `SELECT COUNT(*) FROM SKILLS WHERE TENANT = $1 AND SKILL = ANY($2)`
+	row := s.db.QueryRowContext(ctx, stmt, tenant, pq.Array(skills))
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		logQueryError(ctx, "SkillCount", err)
+		return -1, err
+	}
+	return count, nil
+}
+
+func (s *postgresStore) Skills(ctx context.Context, tenant string) ([]skill, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT SKILL, DESCRIPTION FROM SKILLS WHERE TENANT = $1 ORDER BY SKILL`, tenant)
+	if err != nil {
+		logQueryError(ctx, "Skills", err)
+		return nil, err
+	}
+	defer rows.Close()
+	skills := []skill{}
+	for rows.Next() {
+		var sk skill
+		if err := rows.Scan(&sk.Skill, &sk.Description); err != nil {
+			return nil, errors.New("unable to retrieve skills")
+		}
+		skills = append(skills, sk)
+	}
+	return skills, nil
+}
+
+func (s *postgresStore) SkillAgents(ctx context.Context, tenant string) (map[string]int, error) {
+	stmt := `
+	SELECT SKILLS.SKILL, COUNT(AGENTSKILLS.AGENT)
+	FROM SKILLS
+	LEFT JOIN AGENTSKILLS ON AGENTSKILLS.TENANT = SKILLS.TENANT AND AGENTSKILLS.SKILL = SKILLS.SKILL
+	WHERE SKILLS.TENANT = $1
+	GROUP BY SKILLS.SKILL
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "SkillAgents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var sk string
+		var count int
+		if err := rows.Scan(&sk, &count); err != nil {
+			return nil, errors.New("unable to retrieve skills")
+		}
+		counts[sk] = count
+	}
+	return counts, nil
+}
+
+func (s *postgresStore) CreateSkill(ctx context.Context, tenant string, sk skill) error {
+	stmt := `INSERT INTO SKILLS (TENANT, SKILL, DESCRIPTION) VALUES ($1, $2, $3)`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, sk.Skill, sk.Description); err != nil {
+		logQueryError(ctx, "CreateSkill", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) PriorityLevel(ctx context.Context, tenant, priority string) (int, error) {
+	stmt := `SELECT PRIORITY_LEVEL FROM PRIORITIES WHERE TENANT = $1 AND PRIORITY = $2`
+	row := s.db.QueryRowContext(ctx, stmt, tenant, priority)
+	var level int
+	err := row.Scan(&level)
+	if err != nil {
+		logQueryError(ctx, "PriorityLevel", err)
+		return -1, err
+	}
+	return level, nil
+}
+
+func (s *postgresStore) Priorities(ctx context.Context, tenant string) ([]priority, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT PRIORITY, PRIORITY_LEVEL FROM PRIORITIES WHERE TENANT = $1 ORDER BY PRIORITY_LEVEL`, tenant)
+	if err != nil {
+		logQueryError(ctx, "Priorities", err)
+		return nil, err
+	}
+	defer rows.Close()
+	priorities := []priority{}
+	for rows.Next() {
+		var p priority
+		if err := rows.Scan(&p.Priority, &p.Level); err != nil {
+			return nil, errors.New("unable to retrieve priorities")
+		}
+		priorities = append(priorities, p)
+	}
+	return priorities, nil
+}
+
+func (s *postgresStore) CreatePriority(ctx context.Context, tenant string, p priority) error {
+	stmt := `INSERT INTO PRIORITIES (TENANT, PRIORITY, PRIORITY_LEVEL) VALUES ($1, $2, $3)`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, p.Priority, p.Level); err != nil {
+		logQueryError(ctx, "CreatePriority", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Agents(ctx context.Context, tenant string, ids []string) ([]agent, error) {
+	stmt := `SELECT ID, FIRSTNAME, LASTNAME FROM AGENTS WHERE TENANT = $1 AND ID = ANY($2)`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, pq.Array(ids))
+	if err != nil {
+		logQueryError(ctx, "Agents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var agents []agent
+	for rows.Next() {
+		var a agent
+		if err := rows.Scan(&a.ID, &a.FirstName, &a.LastName); err != nil {
+			return nil, errors.New("no agents found")
+		}
+		agents = append(agents, a)
+	}
+
+	if len(agents) == 0 {
+		return nil, errors.New("no agents found")
+	}
+
+	return agents, nil
+}
+
+func (s *postgresStore) MatchingAgents(ctx context.Context, tenant string, skills []string) ([]agent, error) {
+	stmt := `SELECT AGENT FROM AGENTSKILLS WHERE TENANT = $1 AND SKILL = ANY($2) GROUP BY AGENT HAVING COUNT(*) = $3`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, pq.Array(skills), len(skills))
+	if err != nil {
+		logQueryError(ctx, "MatchingAgents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errNoSkilledAgents
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return nil, errNoSkilledAgents
+	}
+
+	return s.Agents(ctx, tenant, ids)
+}
+
+func (s *postgresStore) CreateAgent(ctx context.Context, tenant string, a agent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt := `INSERT INTO AGENTS (TENANT, ID, FIRSTNAME, LASTNAME) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, stmt, tenant, a.ID, a.FirstName, a.LastName); err != nil {
+		logQueryError(ctx, "CreateAgent", err)
+		tx.Rollback()
+		return err
+	}
+	if err := insertAgentSkills(ctx, tx, tenant, a.ID, a.Skills); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) UpdateAgentSkills(ctx context.Context, tenant, agentID string, skills []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM AGENTSKILLS WHERE TENANT = $1 AND AGENT = $2`, tenant, agentID); err != nil {
+		logQueryError(ctx, "UpdateAgentSkills", err)
+		tx.Rollback()
+		return err
+	}
+	if err := insertAgentSkills(ctx, tx, tenant, agentID, skills); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertAgentSkills(ctx context.Context, tx *sql.Tx, tenant, agentID string, skills []string) error {
+	stmt := `INSERT INTO AGENTSKILLS (TENANT, ID, SKILL, AGENT) VALUES ($1, $2, $3, $4)`
+	for _, s := range skills {
+		if _, err := tx.ExecContext(ctx, stmt, tenant, xid.New().String(), s, agentID); err != nil {
+			logQueryError(ctx, "insertAgentSkills", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *postgresStore) OpenTasks(ctx context.Context, tenant string, agentIDs []string) (map[string][]task, error) {
+	stmt := `
+	SELECT
+	Id, Createdate, name, PRIORITIES.priority_level, agent
+	FROM tasks
+	INNER JOIN PRIORITIES ON tasks.tenant = PRIORITIES.tenant AND tasks.priority = PRIORITIES.priority
+	WHERE
+		tasks.tenant = $1
+	AND
+		agent = ANY($2)
+	AND
+		status = ANY($3)
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, pq.Array(agentIDs), pq.Array(openStatuses))
+	if err != nil {
+		logQueryError(ctx, "OpenTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+	at := map[string][]task{}
+	for rows.Next() {
+		var t task
+		if err := rows.Scan(&t.ID, &t.StartTime, &t.Name, &t.priorityLevel, &t.Agent); err != nil {
+			return nil, errors.New("no agents found")
+		}
+		at[t.Agent] = append(at[t.Agent], t)
+	}
+
+	return at, nil
+}
+
+func (s *postgresStore) RecentAgent(ctx context.Context, tenant string, agentIDs []string, priorityLevel int) (string, error) {
+	stmt := `
+	SELECT
+	Agent
+	FROM TASKS
+	INNER JOIN PRIORITIES ON TASKS.tenant = PRIORITIES.tenant AND TASKS.priority = PRIORITIES.priority
+	WHERE
+		TASKS.tenant = $1
+	AND
+		Agent = ANY($2)
+	AND
+		Status = ANY($3)
+	AND
+		PRIORITIES.priority_level < $4
+	ORDER BY Createdate DESC
+	LIMIT 1
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, pq.Array(agentIDs), pq.Array(openStatuses), priorityLevel)
+	if err != nil {
+		logQueryError(ctx, "RecentAgent", err)
+		return "", err
+	}
+	defer rows.Close()
+	agentID := ""
+	for rows.Next() {
+		if err := rows.Scan(&agentID); err != nil {
+			return "", errors.New("unable to find an agent to assign the task")
+		}
+		break
+	}
+
+	return agentID, nil
+}
+
+func (s *postgresStore) AgentTasks(ctx context.Context, tenant string) ([]agentTasks, error) {
+	stmt := `
+	SELECT
+	Id, FirstName, LastName
+	FROM
+	Agents
+	WHERE
+	Tenant = $1
+	`
+
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "AgentTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+	ats := map[string]agentTasks{}
+	for rows.Next() {
+		var a agent
+		if err := rows.Scan(&a.ID, &a.FirstName, &a.LastName); err != nil {
+			return nil, errors.New("unable to retrieve agents")
+		}
+		ats[a.ID] = agentTasks{
+			agent: a,
+		}
+	}
+
+	stmt = `
+	SELECT
+	Id, Name, Agent, Priority, ARRAY(SELECT SKILL FROM TASKSKILLS WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID ORDER BY SKILL), Createdate, Status, CompleteDate
+	FROM Tasks
+	WHERE
+		Tenant = $1
+	AND
+		Status = ANY($2)
+	`
+
+	rows, err = s.db.QueryContext(ctx, stmt, tenant, pq.Array(openStatuses))
+	if err != nil {
+		logQueryError(ctx, "AgentTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t task
+		var date pq.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Agent, &t.Priorty, pq.Array(&t.Skills), &t.StartTime, &t.Status, &date); err != nil {
+			logQueryError(ctx, "AgentTasks", err)
+			return nil, errors.New("unable to retrieve agent tasks")
+		}
+		if date.Valid {
+			t.CompleteTime = date.Time
+		}
+		lat := ats[t.Agent]
+		lat.Tasks = append(lat.Tasks, t)
+		ats[t.Agent] = lat
+	}
+
+	lats := make([]agentTasks, len(ats))
+	idx := 0
+	for _, lat := range ats {
+		lats[idx] = lat
+		idx++
+	}
+	return lats, nil
+}
+
+func (s *postgresStore) TasksByAgent(ctx context.Context, tenant, agentID string) ([]task, error) {
+	stmt := `
+	SELECT
+	Id, Name, Agent, Priority, ARRAY(SELECT SKILL FROM TASKSKILLS WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID ORDER BY SKILL), Createdate, Status, ` + postgresTaskDetails + `
+	FROM Tasks
+	WHERE
+		Tenant = $1
+	AND
+		Agent = $2
+	AND
+		Status = ANY($3)
+	ORDER BY Createdate
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, agentID, pq.Array(openStatuses))
+	if err != nil {
+		logQueryError(ctx, "TasksByAgent", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []task{}
+	for rows.Next() {
+		var t task
+		var details taskDetailsScanner
+		dest := append([]interface{}{&t.ID, &t.Name, &t.Agent, &t.Priorty, pq.Array(&t.Skills), &t.StartTime, &t.Status}, details.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			logQueryError(ctx, "TasksByAgent", err)
+			return nil, errors.New("unable to retrieve agent tasks")
+		}
+		if t.taskDetails, err = details.details(); err != nil {
+			logQueryError(ctx, "TasksByAgent", err)
+			return nil, errors.New("unable to retrieve agent tasks")
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *postgresStore) Tasks(ctx context.Context, tenant string, filter taskFilter) ([]task, error) {
+	stmt := `
+	SELECT
+	Id, Name, Agent, Priority, ARRAY(SELECT SKILL FROM TASKSKILLS WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID ORDER BY SKILL), Createdate, Status, CompleteDate, ` + taskResultColumns + `, ` + postgresTaskDetails + `
+	FROM Tasks
+	WHERE
+		Tenant = $1
+	`
+	args := []interface{}{tenant}
+	if len(filter.Statuses) > 0 {
+		args = append(args, pq.Array(filter.Statuses))
+		stmt += fmt.Sprintf(" AND Status = ANY($%d)", len(args))
+	}
+	if filter.Agent != "" {
+		args = append(args, filter.Agent)
+		stmt += fmt.Sprintf(" AND Agent = $%d", len(args))
+	}
+	// The dates are stored in UTC, without a time zone.
+	if !filter.ActiveTo.IsZero() {
+		args = append(args, filter.ActiveTo.UTC())
+		stmt += fmt.Sprintf(" AND Createdate < $%d", len(args))
+	}
+	if !filter.ActiveFrom.IsZero() {
+		args = append(args, filter.ActiveFrom.UTC())
+		stmt += fmt.Sprintf(" AND (CompleteDate IS NULL OR CompleteDate >= $%d)", len(args))
+	}
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags))
+		stmt += fmt.Sprintf(" AND Tags @> $%d", len(args))
+	}
+	if len(filter.Metadata) > 0 {
+		metadata, err := json.Marshal(filter.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(metadata))
+		stmt += fmt.Sprintf(" AND Metadata @> $%d", len(args))
+	}
+	stmt += " ORDER BY Createdate DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		logQueryError(ctx, "Tasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []task{}
+	for rows.Next() {
+		var t task
+		var date pq.NullTime
+		var result taskResultScanner
+		var details taskDetailsScanner
+		dest := append(append([]interface{}{&t.ID, &t.Name, &t.Agent, &t.Priorty, pq.Array(&t.Skills), &t.StartTime, &t.Status, &date}, result.dest()...), details.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			logQueryError(ctx, "Tasks", err)
+			return nil, errors.New("unable to retrieve tasks")
+		}
+		if t.taskDetails, err = details.details(); err != nil {
+			logQueryError(ctx, "Tasks", err)
+			return nil, errors.New("unable to retrieve tasks")
+		}
+		if date.Valid {
+			t.CompleteTime = date.Time
+		}
+		t.taskResult = result.taskResult()
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *postgresStore) Task(ctx context.Context, tenant, id string) (task, error) {
+	stmt := `
+	SELECT
+	Id, Name, Agent, Priority, ARRAY(SELECT SKILL FROM TASKSKILLS WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID ORDER BY SKILL), Createdate, Status, CompleteDate, ` + taskResultColumns + `, ` + postgresTaskDetails + `
+	FROM Tasks
+	WHERE
+		Tenant = $1
+	AND
+		Id = $2
+	`
+	var t task
+	var date pq.NullTime
+	var result taskResultScanner
+	var details taskDetailsScanner
+	dest := append(append([]interface{}{&t.ID, &t.Name, &t.Agent, &t.Priorty, pq.Array(&t.Skills), &t.StartTime, &t.Status, &date}, result.dest()...), details.dest()...)
+	err := s.db.QueryRowContext(ctx, stmt, tenant, id).Scan(dest...)
+	if err == nil {
+		t.taskDetails, err = details.details()
+	}
+	if err != nil {
+		logQueryError(ctx, "Task", err)
+		return task{}, fmt.Errorf("unable to find task %s", id)
+	}
+	if date.Valid {
+		t.CompleteTime = date.Time
+	}
+	t.taskResult = result.taskResult()
+	return t, nil
+}
+
+func (s *postgresStore) CreateTask(ctx context.Context, tenant string, t task) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO TASKS
+	(TENANT, ID, NAME, CREATEDATE, PRIORITY, STATUS, AGENT, DESCRIPTION, METADATA, TAGS, EXTERNALURL, SLA)
+	VALUES
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	description, metadata, _, externalURL, sla := t.taskDetails.columns()
+	if _, err := tx.ExecContext(ctx, stmt, tenant, t.ID, t.Name, t.StartTime.UTC(), t.Priorty, t.Status, t.Agent, description, metadata, pq.Array(t.Tags), externalURL, sla); err != nil {
+		logQueryError(ctx, "CreateTask", err)
+		tx.Rollback()
+		return err
+	}
+	stmt = `INSERT INTO TASKSKILLS (TENANT, TASK, SKILL) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	for _, sk := range t.Skills {
+		if _, err := tx.ExecContext(ctx, stmt, tenant, t.ID, sk); err != nil {
+			logQueryError(ctx, "CreateTask", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) UpdateTaskStatus(ctx context.Context, tenant, id, status string, result taskResult) error {
+	outcome, note, resultData := result.columns()
+	stmt := `
+	UPDATE Tasks
+	SET Status = $1, CompleteDate = $2, Outcome = $3, Result = $4, ResultData = $5
+	WHERE
+		Tenant = $6
+	AND
+		Id = $7
+	`
+	_, err := s.db.ExecContext(ctx, stmt, status, time.Now().UTC(), outcome, note, resultData, tenant, id)
+	if err != nil {
+		logQueryError(ctx, "UpdateTaskStatus", err)
+		return err
+	}
+	return nil
+
+}
+
+func (s *postgresStore) TransitionTask(ctx context.Context, tenant, id, agentID string, from []string, to string, result taskResult) (bool, error) {
+	var completeDate pq.NullTime
+	if (taskTransition{to: to}).ends() {
+		completeDate = pq.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	outcome, note, resultData := result.columns()
+
+	stmt := `
+	UPDATE Tasks
+	SET Status = $1, CompleteDate = $2, Outcome = $3, Result = $4, ResultData = $5
+	WHERE
+		Tenant = $6
+	AND
+		Id = $7
+	AND
+		Agent = $8
+	AND
+		Status = ANY($9)
+	`
+	res, err := s.db.ExecContext(ctx, stmt, to, completeDate, outcome, note, resultData, tenant, id, agentID, pq.Array(from))
+	if err != nil {
+		logQueryError(ctx, "TransitionTask", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) CreateComment(ctx context.Context, tenant string, c comment) error {
+	stmt := `
+	INSERT INTO TASKCOMMENTS
+		(TENANT, ID, TASK, AUTHOR, ROLE, AGENT, BODY, CREATEDATE)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	agentID := sql.NullString{String: c.Agent, Valid: c.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, c.ID, c.Task, c.Author, c.Role, agentID, c.Body, c.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateComment", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Comments(ctx context.Context, tenant, taskID string) ([]comment, error) {
+	stmt := `
+	SELECT ID, TASK, AUTHOR, ROLE, AGENT, BODY, CREATEDATE
+	FROM TASKCOMMENTS
+	WHERE TENANT = $1 AND TASK = $2
+	ORDER BY CREATEDATE, ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, taskID)
+	if err != nil {
+		logQueryError(ctx, "Comments", err)
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []comment{}
+	for rows.Next() {
+		var c comment
+		var agentID sql.NullString
+		if err := rows.Scan(&c.ID, &c.Task, &c.Author, &c.Role, &agentID, &c.Body, &c.CreateTime); err != nil {
+			logQueryError(ctx, "Comments", err)
+			return nil, errors.New("unable to retrieve comments")
+		}
+		c.Agent = agentID.String
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+func (s *postgresStore) CreateAttachment(ctx context.Context, tenant string, a attachment) error {
+	stmt := `
+	INSERT INTO TASKATTACHMENTS
+		(TENANT, ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, a.ID, a.Task, a.Name, a.ContentType, a.Size, a.SHA256, a.Author, a.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateAttachment", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Attachments(ctx context.Context, tenant, taskID string) ([]attachment, error) {
+	stmt := `
+	SELECT ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE
+	FROM TASKATTACHMENTS
+	WHERE TENANT = $1 AND TASK = $2
+	ORDER BY CREATEDATE, ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, taskID)
+	if err != nil {
+		logQueryError(ctx, "Attachments", err)
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := []attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			logQueryError(ctx, "Attachments", err)
+			return nil, errors.New("unable to retrieve attachments")
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+func (s *postgresStore) Attachment(ctx context.Context, tenant, taskID, id string) (attachment, error) {
+	stmt := `
+	SELECT ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE
+	FROM TASKATTACHMENTS
+	WHERE TENANT = $1 AND TASK = $2 AND ID = $3
+	`
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, stmt, tenant, taskID, id))
+	if err != nil {
+		logQueryError(ctx, "Attachment", err)
+		return attachment{}, fmt.Errorf("unable to find attachment %s", id)
+	}
+	return a, nil
+}
+
+func (s *postgresStore) CreateSchedule(ctx context.Context, tenant string, sc schedule) error {
+	stmt := `
+	INSERT INTO SCHEDULES
+		(TENANT, ID, TASK, CRON, STARTAT, PAUSED, NEXTRUN, AUTHOR, CREATEDATE)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	taskJSON, err := json.Marshal(sc.Task)
+	if err != nil {
+		return err
+	}
+	cron := sql.NullString{String: sc.Cron, Valid: sc.Cron != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, sc.ID, string(taskJSON), cron, nullTime(sc.StartAt), sc.Paused, nullTime(sc.NextRun), sc.Author, sc.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateSchedule", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Schedules(ctx context.Context, tenant string) ([]schedule, error) {
+	stmt := `SELECT ` + scheduleColumns + ` FROM SCHEDULES WHERE TENANT = $1 ORDER BY CREATEDATE, ID`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "Schedules", err)
+		return nil, err
+	}
+	defer rows.Close()
+	schedules := []schedule{}
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			logQueryError(ctx, "Schedules", err)
+			return nil, errors.New("unable to retrieve schedules")
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, nil
+}
+
+func (s *postgresStore) Schedule(ctx context.Context, tenant, id string) (schedule, error) {
+	stmt := `SELECT ` + scheduleColumns + ` FROM SCHEDULES WHERE TENANT = $1 AND ID = $2`
+	sc, err := scanSchedule(s.db.QueryRowContext(ctx, stmt, tenant, id))
+	if err != nil {
+		logQueryError(ctx, "Schedule", err)
+		return schedule{}, fmt.Errorf("unable to find schedule %s", id)
+	}
+	return sc, nil
+}
+
+func (s *postgresStore) SetSchedulePaused(ctx context.Context, tenant, id string, paused bool, nextRun *time.Time) (bool, error) {
+	stmt := `UPDATE SCHEDULES SET PAUSED = $1, NEXTRUN = $2 WHERE TENANT = $3 AND ID = $4`
+	res, err := s.db.ExecContext(ctx, stmt, paused, nullTime(nextRun), tenant, id)
+	if err != nil {
+		logQueryError(ctx, "SetSchedulePaused", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) DeleteSchedule(ctx context.Context, tenant, id string) (bool, error) {
+	stmt := `DELETE FROM SCHEDULES WHERE TENANT = $1 AND ID = $2`
+	res, err := s.db.ExecContext(ctx, stmt, tenant, id)
+	if err != nil {
+		logQueryError(ctx, "DeleteSchedule", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) DueSchedules(ctx context.Context, now time.Time, limit int) ([]schedule, error) {
+	stmt := `
+	SELECT TENANT, ` + scheduleColumns + `
+	FROM SCHEDULES
+	WHERE PAUSED = $1 AND NEXTRUN IS NOT NULL AND NEXTRUN <= $2
+	ORDER BY NEXTRUN
+	LIMIT $3
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, false, now.UTC(), limit)
+	if err != nil {
+		logQueryError(ctx, "DueSchedules", err)
+		return nil, err
+	}
+	defer rows.Close()
+	schedules := []schedule{}
+	for rows.Next() {
+		var tenant string
+		sc, err := scanSchedule(rows, &tenant)
+		if err != nil {
+			logQueryError(ctx, "DueSchedules", err)
+			return nil, errors.New("unable to retrieve schedules")
+		}
+		sc.tenant = tenant
+		schedules = append(schedules, sc)
+	}
+	return schedules, nil
+}
+
+func (s *postgresStore) ClaimSchedule(ctx context.Context, tenant, id string, due, until time.Time) (bool, error) {
+	stmt := `
+	UPDATE SCHEDULES
+	SET NEXTRUN = $1
+	WHERE TENANT = $2 AND ID = $3 AND NEXTRUN = $4 AND PAUSED = $5
+	`
+	res, err := s.db.ExecContext(ctx, stmt, until.UTC(), tenant, id, due.UTC(), false)
+	if err != nil {
+		logQueryError(ctx, "ClaimSchedule", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) RecordScheduleRun(ctx context.Context, tenant, id string, run scheduleRun, nextRun *time.Time) error {
+	stmt := `
+	UPDATE SCHEDULES
+	SET NEXTRUN = $1, LASTRUN = $2, LASTTASK = $3, LASTERROR = $4
+	WHERE TENANT = $5 AND ID = $6
+	`
+	lastTask := sql.NullString{String: run.LastTask, Valid: run.LastTask != ""}
+	lastError := sql.NullString{String: run.LastError, Valid: run.LastError != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, nullTime(nextRun), nullTime(run.LastRun), lastTask, lastError, tenant, id); err != nil {
+		logQueryError(ctx, "RecordScheduleRun", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) CreateTemplate(ctx context.Context, tenant string, tp template) error {
+	stmt := `INSERT INTO TEMPLATES (TENANT, NAME, DEFAULTS, AUTHOR, CREATEDATE) VALUES ($1, $2, $3, $4, $5)`
+	defaults, err := json.Marshal(tp.templateDefaults)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, tp.Name, string(defaults), tp.Author, tp.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateTemplate", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Templates(ctx context.Context, tenant string) ([]template, error) {
+	stmt := `SELECT ` + templateColumns + ` FROM TEMPLATES WHERE TENANT = $1 ORDER BY NAME`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "Templates", err)
+		return nil, err
+	}
+	defer rows.Close()
+	templates := []template{}
+	for rows.Next() {
+		tp, err := scanTemplate(rows)
+		if err != nil {
+			logQueryError(ctx, "Templates", err)
+			return nil, errors.New("unable to retrieve templates")
+		}
+		templates = append(templates, tp)
+	}
+	return templates, nil
+}
+
+func (s *postgresStore) Template(ctx context.Context, tenant, name string) (template, error) {
+	stmt := `SELECT ` + templateColumns + ` FROM TEMPLATES WHERE TENANT = $1 AND NAME = $2`
+	tp, err := scanTemplate(s.db.QueryRowContext(ctx, stmt, tenant, name))
+	if err != nil {
+		logQueryError(ctx, "Template", err)
+		return template{}, fmt.Errorf("unable to find template %s", name)
+	}
+	return tp, nil
+}
+
+func (s *postgresStore) DeleteTemplate(ctx context.Context, tenant, name string) (bool, error) {
+	stmt := `DELETE FROM TEMPLATES WHERE TENANT = $1 AND NAME = $2`
+	res, err := s.db.ExecContext(ctx, stmt, tenant, name)
+	if err != nil {
+		logQueryError(ctx, "DeleteTemplate", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) CreateEvent(ctx context.Context, tenant string, e event) error {
+	stmt := `
+	INSERT INTO EVENTS
+		(TENANT, TYPE, TASK, AGENT, STATUS, CREATEDATE)
+	VALUES
+		($1, $2, $3, $4, $5, $6)
+	`
+	agentID := sql.NullString{String: e.Agent, Valid: e.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, e.Type, e.Task, agentID, e.Status, e.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateEvent", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Events(ctx context.Context, tenant string, after int64, limit int) ([]event, error) {
+	stmt := `
+	SELECT ID, TYPE, TASK, AGENT, STATUS, CREATEDATE
+	FROM EVENTS
+	WHERE TENANT = $1 AND ID > $2
+	ORDER BY ID
+	LIMIT $3
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, after, limit)
+	if err != nil {
+		logQueryError(ctx, "Events", err)
+		return nil, err
+	}
+	defer rows.Close()
+	events := []event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve events")
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (s *postgresStore) EventsBetween(ctx context.Context, tenant string, from, to time.Time) ([]event, error) {
+	// The dates are stored in UTC, without a time zone.
+	stmt := `
+	SELECT ID, TYPE, TASK, AGENT, STATUS, CREATEDATE
+	FROM EVENTS
+	WHERE TENANT = $1 AND CREATEDATE >= $2 AND CREATEDATE < $3
+	ORDER BY ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, from.UTC(), to.UTC())
+	if err != nil {
+		logQueryError(ctx, "EventsBetween", err)
+		return nil, err
+	}
+	defer rows.Close()
+	events := []event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve events")
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func scanEvent(row interface{ Scan(...interface{}) error }) (event, error) {
+	var e event
+	var agentID sql.NullString
+	if err := row.Scan(&e.ID, &e.Type, &e.Task, &agentID, &e.Status, &e.CreateTime); err != nil {
+		return event{}, err
+	}
+	e.Agent = agentID.String
+	return e, nil
+}
+
+func scanAttachment(row interface{ Scan(...interface{}) error }) (attachment, error) {
+	var a attachment
+	err := row.Scan(&a.ID, &a.Task, &a.Name, &a.ContentType, &a.Size, &a.SHA256, &a.Author, &a.CreateTime)
+	return a, err
+}
+
+// scheduleColumns selects a schedule in the SQL stores, scanned by
+// scanSchedule.
+const scheduleColumns = `ID, TASK, CRON, STARTAT, PAUSED, NEXTRUN, LASTRUN, LASTTASK, LASTERROR, AUTHOR, CREATEDATE`
+
+// scanSchedule scans the scheduleColumns, after the extra columns selected
+// before them.
+func scanSchedule(row interface{ Scan(...interface{}) error }, extra ...interface{}) (schedule, error) {
+	var sc schedule
+	var taskJSON string
+	var cron, lastTask, lastError sql.NullString
+	var startAt, nextRun, lastRun sql.NullTime
+	dest := append(extra, &sc.ID, &taskJSON, &cron, &startAt, &sc.Paused, &nextRun, &lastRun, &lastTask, &lastError, &sc.Author, &sc.CreateTime)
+	if err := row.Scan(dest...); err != nil {
+		return schedule{}, err
+	}
+	if err := json.Unmarshal([]byte(taskJSON), &sc.Task); err != nil {
+		return schedule{}, err
+	}
+	sc.Cron = cron.String
+	sc.StartAt = timePointer(startAt)
+	sc.NextRun = timePointer(nextRun)
+	sc.LastRun = timePointer(lastRun)
+	sc.LastTask = lastTask.String
+	sc.LastError = lastError.String
+	return sc, nil
+}
+
+// templateColumns selects a template in the SQL stores, scanned by
+// scanTemplate.
+const templateColumns = `NAME, DEFAULTS, AUTHOR, CREATEDATE`
+
+func scanTemplate(row interface{ Scan(...interface{}) error }) (template, error) {
+	var tp template
+	var defaults string
+	if err := row.Scan(&tp.Name, &defaults, &tp.Author, &tp.CreateTime); err != nil {
+		return template{}, err
+	}
+	if err := json.Unmarshal([]byte(defaults), &tp.templateDefaults); err != nil {
+		return template{}, err
+	}
+	return tp, nil
+}
+
+// nullTime is the nullable column of an optional time, in UTC.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePointer(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func (s *postgresStore) CreateAPIKey(ctx context.Context, key apiKey) error {
+	stmt := `
+	INSERT INTO APIKEYS
+		(ID, TENANT, NAME, HASH, ROLE, AGENT, CREATEDATE)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+	`
+	agentID := sql.NullString{String: key.Agent, Valid: key.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, key.ID, key.Tenant, key.Name, key.hash, key.Role, agentID, key.CreateTime.UTC()); err != nil {
+		logQueryError(ctx, "CreateAPIKey", err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) APIKeyByHash(ctx context.Context, hash string) (apiKey, error) {
+	stmt := `SELECT ID, TENANT, NAME, ROLE, AGENT, CREATEDATE, REVOKEDATE FROM APIKEYS WHERE HASH = $1`
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, stmt, hash))
+	if err != nil {
+		return apiKey{}, errors.New("unable to find API key")
+	}
+	return key, nil
+}
+
+func (s *postgresStore) APIKeys(ctx context.Context, tenant string) ([]apiKey, error) {
+	stmt := `SELECT ID, TENANT, NAME, ROLE, AGENT, CREATEDATE, REVOKEDATE FROM APIKEYS WHERE TENANT = $1 ORDER BY CREATEDATE`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "APIKeys", err)
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []apiKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve API keys")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (apiKey, error) {
+	var key apiKey
+	var agentID sql.NullString
+	var revoked pq.NullTime
+	if err := row.Scan(&key.ID, &key.Tenant, &key.Name, &key.Role, &agentID, &key.CreateTime, &revoked); err != nil {
+		return apiKey{}, err
+	}
+	key.Agent = agentID.String
+	if revoked.Valid {
+		key.RevokeTime = revoked.Time
+	}
+	return key, nil
+}
+
+func (s *postgresStore) RevokeAPIKey(ctx context.Context, tenant, id string) (bool, error) {
+	stmt := `UPDATE APIKEYS SET REVOKEDATE = $1 WHERE TENANT = $2 AND ID = $3 AND REVOKEDATE IS NULL`
+	res, err := s.db.ExecContext(ctx, stmt, time.Now().UTC(), tenant, id)
+	if err != nil {
+		logQueryError(ctx, "RevokeAPIKey", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
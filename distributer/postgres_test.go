@@ -0,0 +1,112 @@
+package distributer
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+// utcTime matches a time argument at the instant, written in UTC.
+type utcTime struct {
+	time.Time
+}
+
+func (u utcTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Location() == time.UTC && t.Equal(u.Time)
+}
+
+func Test_postgresStore_utc(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	s := newPostgresStore(db)
+
+	// The dates are written in UTC, whatever the zone of the time.
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+	mock.ExpectExec(`INSERT INTO EVENTS`).
+		WithArgs(DefaultTenant, eventTaskAssigned, "a", sqlmock.AnyArg(), statusAssigned, utcTime{at}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := s.CreateEvent(context.Background(), DefaultTenant, event{Type: eventTaskAssigned, Task: "a", Status: statusAssigned, CreateTime: at}); err != nil {
+		t.Fatalf("postgresStore.CreateEvent() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("postgresStore %v", err)
+	}
+}
+
+// inZone runs the test with the local time zone of a server in the zone.
+func inZone(t *testing.T, zone *time.Location) {
+	local := time.Local
+	time.Local = zone
+	t.Cleanup(func() {
+		time.Local = local
+	})
+}
+
+func Test_postgresStore_Tasks_timeZone(t *testing.T) {
+	inZone(t, time.FixedZone("EST", -5*60*60))
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	s := newPostgresStore(db)
+
+	// The active range of the local times selects the tasks by their UTC
+	// dates.
+	from := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	to := from.Add(time.Hour)
+	created := from.Add(30 * time.Minute).UTC()
+	mock.ExpectQuery(`FROM Tasks`).
+		WithArgs(DefaultTenant, utcTime{to}, utcTime{from}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "agent", "priority", "skills", "createdate", "status", "completedate", "result", "outcome", "resultdata", "description", "metadata", "tags", "externalurl", "sla"}).
+			AddRow("a", "Task", "1000", "low", "{skill1}", created, statusAssigned, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+
+	tasks, err := s.Tasks(context.Background(), DefaultTenant, taskFilter{ActiveFrom: from, ActiveTo: to})
+	if err != nil {
+		t.Fatalf("postgresStore.Tasks() error = %v", err)
+	}
+	if len(tasks) != 1 || !tasks[0].StartTime.Equal(created) {
+		t.Errorf("postgresStore.Tasks() = %+v, want the task created at %s", tasks, created)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("postgresStore %v", err)
+	}
+}
+
+func Test_postgresStore_EventsBetween_timeZone(t *testing.T) {
+	inZone(t, time.FixedZone("EST", -5*60*60))
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	s := newPostgresStore(db)
+
+	// The report window of the local times selects the events by their UTC
+	// dates.
+	from := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	to := from.Add(time.Hour)
+	created := from.Add(30 * time.Minute).UTC()
+	mock.ExpectQuery(`FROM EVENTS`).
+		WithArgs(DefaultTenant, utcTime{from}, utcTime{to}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "task", "agent", "status", "createdate"}).AddRow(1, eventTaskAssigned, "a", nil, statusAssigned, created))
+
+	events, err := s.EventsBetween(context.Background(), DefaultTenant, from, to)
+	if err != nil {
+		t.Fatalf("postgresStore.EventsBetween() error = %v", err)
+	}
+	if len(events) != 1 || !events[0].CreateTime.Equal(created) {
+		t.Errorf("postgresStore.EventsBetween() = %+v, want the event created at %s", events, created)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("postgresStore %v", err)
+	}
+}
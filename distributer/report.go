@@ -0,0 +1,449 @@
+package distributer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The limits of a report's period.  A report of a long period in short
+// windows would be too large to return at once.
+const (
+	defaultReportPeriod = 7 * 24 * time.Hour
+	maxReportPeriod     = 366 * 24 * time.Hour
+	minReportWindow     = time.Minute
+	maxReportWindows    = 1000
+)
+
+// reportPeriod is the time range of a report, split into windows of the same
+// length starting at From.  The last window ends at To, so it can be shorter.
+type reportPeriod struct {
+	From   time.Time
+	To     time.Time
+	Window time.Duration
+}
+
+// window is a time range of a report, from the start until, but not
+// including, the end.
+type window struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+func (w window) contains(t time.Time) bool {
+	return !t.IsZero() && !t.Before(w.From) && t.Before(w.To)
+}
+
+// parseReportPeriod returns the period of the from, to and window parameters.
+// The period ends now and starts defaultReportPeriod before its end by
+// default, and is a single window when there is no window parameter.
+func parseReportPeriod(query url.Values, now time.Time) (reportPeriod, error) {
+	p := reportPeriod{To: now.UTC()}
+	var err error
+	if value := query.Get("to"); value != "" {
+		if p.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return reportPeriod{}, fmt.Errorf("to %s must be an RFC 3339 time", value)
+		}
+	}
+	p.From = p.To.Add(-defaultReportPeriod)
+	if value := query.Get("from"); value != "" {
+		if p.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return reportPeriod{}, fmt.Errorf("from %s must be an RFC 3339 time", value)
+		}
+	}
+	p.From, p.To = p.From.UTC(), p.To.UTC()
+	switch length := p.To.Sub(p.From); {
+	case length <= 0:
+		return reportPeriod{}, errors.New("from must be before to")
+	case length > maxReportPeriod:
+		return reportPeriod{}, fmt.Errorf("from and to must be at most %s apart", maxReportPeriod)
+	}
+	p.Window = p.To.Sub(p.From)
+	if value := query.Get("window"); value != "" {
+		if p.Window, err = time.ParseDuration(value); err != nil || p.Window < minReportWindow {
+			return reportPeriod{}, fmt.Errorf("window %s must be a duration of at least %s, like 24h", value, minReportWindow)
+		}
+		if count := (p.To.Sub(p.From) + p.Window - 1) / p.Window; count > maxReportWindows {
+			return reportPeriod{}, fmt.Errorf("window %s splits the period into more than %d windows", value, maxReportWindows)
+		}
+	}
+	return p, nil
+}
+
+// windows returns the windows of the period, oldest first.
+func (p reportPeriod) windows() []window {
+	var windows []window
+	for from := p.From; from.Before(p.To); from = from.Add(p.Window) {
+		to := from.Add(p.Window)
+		if to.After(p.To) {
+			to = p.To
+		}
+		windows = append(windows, window{From: from, To: to})
+	}
+	return windows
+}
+
+// agentReport is the workload and performance of an agent in a window.  The
+// times to complete are from when the tasks were assigned until they were
+// completed, in seconds.
+type agentReport struct {
+	Agent     string `json:"agent"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	window
+	// Assigned, and the skill mix, are of the tasks assigned in the window,
+	// Completed of the tasks completed in it.
+	Assigned          int     `json:"assigned"`
+	Completed         int     `json:"completed"`
+	AvgTimeToComplete float64 `json:"avg_time_to_complete"`
+	P95TimeToComplete float64 `json:"p95_time_to_complete"`
+	// Preemptions are the tasks assigned while the agent was working on a
+	// lower priority task.
+	Preemptions int `json:"preemptions"`
+	// Utilization is the share of the window, until now, the agent was
+	// working on at least one task.
+	Utilization float64        `json:"utilization"`
+	Skills      map[string]int `json:"skills"`
+}
+
+// agentReports returns the report of each agent in each window of the
+// period, by agent then window.  The tasks are those an agent had at some
+// time in the period, and levels are the levels of their priorities.
+func agentReports(agents []agent, tasks []task, levels map[string]int, p reportPeriod, now time.Time) []agentReport {
+	byAgent := map[string][]task{}
+	for _, t := range tasks {
+		byAgent[t.Agent] = append(byAgent[t.Agent], t)
+	}
+	reports := []agentReport{}
+	for _, a := range agents {
+		agentTasks := byAgent[a.ID]
+		sort.Slice(agentTasks, func(i, j int) bool {
+			return agentTasks[i].StartTime.Before(agentTasks[j].StartTime)
+		})
+		for _, w := range p.windows() {
+			r := agentReport{Agent: a.ID, FirstName: a.FirstName, LastName: a.LastName, window: w, Skills: map[string]int{}}
+			var durations []float64
+			for _, t := range agentTasks {
+				if w.contains(t.StartTime) {
+					r.Assigned++
+					for _, sk := range t.Skills {
+						r.Skills[sk]++
+					}
+					if preempted(t, agentTasks, levels) {
+						r.Preemptions++
+					}
+				}
+				if t.Status == statusComplete && w.contains(t.CompleteTime) {
+					r.Completed++
+					durations = append(durations, t.CompleteTime.Sub(t.StartTime).Seconds())
+				}
+			}
+			r.AvgTimeToComplete, r.P95TimeToComplete = mean(durations), percentile(durations, 95)
+			r.Utilization = utilization(agentTasks, w, now)
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+// preempted reports whether the task was assigned while the agent had an open
+// task of a lower priority.  The agent's tasks are sorted by when they were
+// assigned.
+func preempted(t task, agentTasks []task, levels map[string]int) bool {
+	for _, other := range agentTasks {
+		if !other.StartTime.Before(t.StartTime) {
+			return false
+		}
+		open := other.CompleteTime.IsZero() || other.CompleteTime.After(t.StartTime)
+		if open && levels[other.Priorty] < levels[t.Priorty] {
+			return true
+		}
+	}
+	return false
+}
+
+// utilization returns the share of the window, until now, covered by the
+// tasks, from when they were assigned until they were completed or
+// cancelled.  The tasks are sorted by when they were assigned.
+func utilization(tasks []task, w window, now time.Time) float64 {
+	if now.Before(w.To) {
+		w.To = now
+	}
+	if !w.To.After(w.From) {
+		return 0
+	}
+	var busy time.Duration
+	covered := w.From
+	for _, t := range tasks {
+		end := t.CompleteTime
+		if end.IsZero() || end.After(w.To) {
+			end = w.To
+		}
+		start := t.StartTime
+		if start.Before(covered) {
+			start = covered
+		}
+		if end.After(start) {
+			busy += end.Sub(start)
+			covered = end
+		}
+	}
+	return busy.Seconds() / w.To.Sub(w.From).Seconds()
+}
+
+// queueReport is the queue of the tasks with a skill and priority in a window.
+// A task with several skills is in the report of each of them.
+type queueReport struct {
+	window
+	Skill    string `json:"skill"`
+	Priority string `json:"priority"`
+	// Created, Completed and Cancelled are the tasks created, completed or
+	// cancelled in the window.
+	Created   int `json:"created"`
+	Completed int `json:"completed"`
+	Cancelled int `json:"cancelled"`
+	// Open are the tasks not completed or cancelled at the end of the window,
+	// or now, and Queued those of them an agent has not picked up yet.
+	Open   int `json:"open"`
+	Queued int `json:"queued"`
+	// The wait times are from when the tasks picked up in the window were
+	// created until their agent accepted, started or completed them, in
+	// seconds.
+	AvgWait float64 `json:"avg_wait"`
+	P95Wait float64 `json:"p95_wait"`
+	// Throughput is the tasks completed per hour of the window, until now.
+	Throughput float64 `json:"throughput"`
+}
+
+// queueKey is the skill and priority of a queue.
+type queueKey struct {
+	skill    string
+	priority string
+}
+
+// pickedUpTimes returns when each task was first accepted, started or
+// completed by its agent, from their events, which are oldest first.  A task
+// completed before its events were recorded was picked up when it was
+// completed.
+func pickedUpTimes(tasks []task, events []event) map[string]time.Time {
+	times := make(map[string]time.Time, len(tasks))
+	for _, e := range events {
+		switch e.Type {
+		case eventTaskAccepted, eventTaskStarted, eventTaskCompleted:
+			if _, ok := times[e.Task]; !ok {
+				times[e.Task] = e.CreateTime
+			}
+		}
+	}
+	for _, t := range tasks {
+		if _, ok := times[t.ID]; !ok && t.Status == statusComplete {
+			times[t.ID] = t.CompleteTime
+		}
+	}
+	return times
+}
+
+// queueReports returns the report of each skill and priority of the tasks in
+// each window of the period, by window then skill and priority.  The tasks
+// are those open at some time in the period, and the events those recorded
+// since the first of them was created.
+func queueReports(tasks []task, events []event, p reportPeriod, now time.Time) []queueReport {
+	pickedUp := pickedUpTimes(tasks, events)
+	keys := map[queueKey]bool{}
+	for _, t := range tasks {
+		for _, sk := range t.Skills {
+			keys[queueKey{skill: sk, priority: t.Priorty}] = true
+		}
+	}
+	sorted := make([]queueKey, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].skill != sorted[j].skill {
+			return sorted[i].skill < sorted[j].skill
+		}
+		return sorted[i].priority < sorted[j].priority
+	})
+	reports := []queueReport{}
+	for _, w := range p.windows() {
+		end := w.To
+		if now.Before(end) {
+			end = now
+		}
+		byKey := make(map[queueKey]*queueReport, len(sorted))
+		waits := map[queueKey][]float64{}
+		for _, key := range sorted {
+			byKey[key] = &queueReport{window: w, Skill: key.skill, Priority: key.priority}
+		}
+		for _, t := range tasks {
+			open := t.StartTime.Before(end) && (t.CompleteTime.IsZero() || !t.CompleteTime.Before(end))
+			for _, sk := range t.Skills {
+				key := queueKey{skill: sk, priority: t.Priorty}
+				r := byKey[key]
+				if w.contains(t.StartTime) {
+					r.Created++
+				}
+				if w.contains(t.CompleteTime) {
+					if t.Status == statusCancelled {
+						r.Cancelled++
+					} else {
+						r.Completed++
+					}
+				}
+				at, ok := pickedUp[t.ID]
+				if open {
+					r.Open++
+					if !ok || !at.Before(end) {
+						r.Queued++
+					}
+				}
+				if ok && w.contains(at) {
+					waits[key] = append(waits[key], at.Sub(t.StartTime).Seconds())
+				}
+			}
+		}
+		for _, key := range sorted {
+			r := byKey[key]
+			r.AvgWait, r.P95Wait = mean(waits[key]), percentile(waits[key], 95)
+			if hours := end.Sub(w.From).Hours(); hours > 0 {
+				r.Throughput = float64(r.Completed) / hours
+			}
+			reports = append(reports, *r)
+		}
+	}
+	return reports
+}
+
+// skillCoverage is how many agents have a skill, and how many of the open
+// tasks require it.
+type skillCoverage struct {
+	skill
+	Agents int `json:"agents"`
+	// Open are the tasks that require the skill the agents have not completed
+	// yet, and Queued those of them still waiting for their agent.
+	Open   int `json:"open"`
+	Queued int `json:"queued"`
+}
+
+// skillCoverages returns the coverage of the skills with at most maxAgents
+// agents, fewest agents first.  A negative maxAgents returns every skill.
+func skillCoverages(skills []skill, agents map[string]int, open []task, maxAgents int) []skillCoverage {
+	tasks, queued := map[string]int{}, map[string]int{}
+	for _, t := range open {
+		for _, sk := range t.Skills {
+			tasks[sk]++
+			if t.Status == statusAssigned {
+				queued[sk]++
+			}
+		}
+	}
+	coverages := []skillCoverage{}
+	for _, sk := range skills {
+		if maxAgents >= 0 && agents[sk.Skill] > maxAgents {
+			continue
+		}
+		coverages = append(coverages, skillCoverage{skill: sk, Agents: agents[sk.Skill], Open: tasks[sk.Skill], Queued: queued[sk.Skill]})
+	}
+	sort.SliceStable(coverages, func(i, j int) bool {
+		return coverages[i].Agents < coverages[j].Agents
+	})
+	return coverages
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest rank percentile of the values.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+var agentReportHeader = []string{"agent", "first_name", "last_name", "from", "to", "assigned", "completed", "avg_time_to_complete", "p95_time_to_complete", "preemptions", "utilization", "skills"}
+
+func (r agentReport) row() []string {
+	return []string{
+		r.Agent, r.FirstName, r.LastName, r.From.Format(time.RFC3339), r.To.Format(time.RFC3339),
+		strconv.Itoa(r.Assigned), strconv.Itoa(r.Completed),
+		formatFloat(r.AvgTimeToComplete), formatFloat(r.P95TimeToComplete),
+		strconv.Itoa(r.Preemptions), formatFloat(r.Utilization), formatCounts(r.Skills),
+	}
+}
+
+var queueReportHeader = []string{"from", "to", "skill", "priority", "created", "completed", "cancelled", "open", "queued", "avg_wait", "p95_wait", "throughput"}
+
+func (r queueReport) row() []string {
+	return []string{
+		r.From.Format(time.RFC3339), r.To.Format(time.RFC3339), r.Skill, r.Priority,
+		strconv.Itoa(r.Created), strconv.Itoa(r.Completed), strconv.Itoa(r.Cancelled), strconv.Itoa(r.Open), strconv.Itoa(r.Queued),
+		formatFloat(r.AvgWait), formatFloat(r.P95Wait), formatFloat(r.Throughput),
+	}
+}
+
+var skillCoverageHeader = []string{"skill", "description", "agents", "open", "queued"}
+
+func (c skillCoverage) row() []string {
+	return []string{c.Skill, c.Description, strconv.Itoa(c.Agents), strconv.Itoa(c.Open), strconv.Itoa(c.Queued)}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatCounts formats the counts as key=count pairs separated by
+// semicolons, by key, so they fit in a CSV column.
+func formatCounts(counts map[string]int) string {
+	pairs := make([]string, 0, len(counts))
+	for _, key := range sortedKeys(counts) {
+		pairs = append(pairs, key+"="+strconv.Itoa(counts[key]))
+	}
+	return strings.Join(pairs, ";")
+}
+
+// reportFormat returns whether the format parameter asks for CSV, rather than
+// the default of JSON.
+func reportFormat(query url.Values) (csv bool, err error) {
+	switch format := query.Get("format"); format {
+	case "", "json":
+		return false, nil
+	case "csv":
+		return true, nil
+	default:
+		return false, fmt.Errorf("format %s must be json or csv", format)
+	}
+}
+
+// formatCSV writes the rows as a CSV file with the header, downloaded as the
+// file name.
+func formatCSV(writer http.ResponseWriter, fileName string, header []string, rows [][]string) {
+	writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w := csv.NewWriter(writer)
+	w.Write(header)
+	w.WriteAll(rows)
+}
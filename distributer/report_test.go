@@ -0,0 +1,225 @@
+package distributer
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseReportPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 30, 15, 500, time.UTC)
+	tests := []struct {
+		name    string
+		query   string
+		want    reportPeriod
+		wantErr bool
+	}{
+		{
+			name: "Default",
+			want: reportPeriod{From: time.Date(2024, 3, 3, 12, 30, 15, 500, time.UTC), To: now, Window: defaultReportPeriod},
+		},
+		{
+			name:  "Windows",
+			query: "from=2024-03-01T00:00:00Z&to=2024-03-08T00:00:00%2B02:00&window=24h",
+			want:  reportPeriod{From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 7, 22, 0, 0, 0, time.UTC), Window: 24 * time.Hour},
+		},
+		{name: "Invalid from", query: "from=yesterday", wantErr: true},
+		{name: "Invalid to", query: "to=2024-03-08", wantErr: true},
+		{name: "From after to", query: "from=2024-03-08T00:00:00Z&to=2024-03-01T00:00:00Z", wantErr: true},
+		{name: "Too long", query: "from=2020-01-01T00:00:00Z&to=2024-01-01T00:00:00Z", wantErr: true},
+		{name: "Invalid window", query: "window=daily", wantErr: true},
+		{name: "Short window", query: "window=30s", wantErr: true},
+		{name: "Too many windows", query: "window=1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parseReportPeriod(query, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReportPeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseReportPeriod() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reportPeriod_windows(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	p := reportPeriod{From: from, To: from.Add(150 * time.Minute), Window: time.Hour}
+	want := []window{
+		{From: from, To: from.Add(time.Hour)},
+		{From: from.Add(time.Hour), To: from.Add(2 * time.Hour)},
+		{From: from.Add(2 * time.Hour), To: from.Add(150 * time.Minute)},
+	}
+	if got := p.windows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("reportPeriod.windows() = %v, want %v", got, want)
+	}
+}
+
+func Test_agentReports(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return from.Add(time.Duration(minutes) * time.Minute)
+	}
+	agents := []agent{
+		{ID: "1000", FirstName: "Bighead", LastName: "Burton"},
+		{ID: "1001", FirstName: "Ovaltine", LastName: "Jenkins"},
+	}
+	tasks := []task{
+		{ID: "c", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: at(90)},
+		{ID: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusComplete, StartTime: at(0), CompleteTime: at(30)},
+		{ID: "b", Agent: "1000", Priorty: "high", Skills: []string{"skill2"}, Status: statusComplete, StartTime: at(10), CompleteTime: at(20)},
+		{ID: "d", Agent: "1000", Priorty: "high", Skills: []string{"skill1"}, Status: statusCancelled, StartTime: at(40), CompleteTime: at(55)},
+	}
+	levels := map[string]int{"low": 0, "high": 1}
+	p := reportPeriod{From: from, To: at(120), Window: time.Hour}
+	first, second := window{From: from, To: at(60)}, window{From: at(60), To: at(120)}
+	want := []agentReport{
+		{
+			Agent: "1000", FirstName: "Bighead", LastName: "Burton", window: first,
+			Assigned: 3, Completed: 2, AvgTimeToComplete: 1200, P95TimeToComplete: 1800, Preemptions: 1, Utilization: 0.75,
+			Skills: map[string]int{"skill1": 2, "skill2": 1},
+		},
+		{
+			Agent: "1000", FirstName: "Bighead", LastName: "Burton", window: second,
+			Assigned: 1, Utilization: 0.25, Skills: map[string]int{"skill1": 1},
+		},
+		{Agent: "1001", FirstName: "Ovaltine", LastName: "Jenkins", window: first, Skills: map[string]int{}},
+		{Agent: "1001", FirstName: "Ovaltine", LastName: "Jenkins", window: second, Skills: map[string]int{}},
+	}
+	// The second window is only reported until now.
+	got := agentReports(agents, tasks, levels, p, at(100))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("agentReports() = %+v, want %+v", got, want)
+	}
+	if row := got[0].row(); row[len(row)-1] != "skill1=2;skill2=1" || row[8] != "1800" {
+		t.Errorf("agentReport.row() = %v, want the p95 and skill counts formatted", row)
+	}
+}
+
+func Test_percentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{name: "No values", p: 95},
+		{name: "One value", values: []float64{4}, p: 95, want: 4},
+		{name: "Nearest rank", values: []float64{5, 1, 4, 2, 3}, p: 50, want: 3},
+		{name: "Highest", values: []float64{5, 1, 4, 2, 3}, p: 95, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_queueReports(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return from.Add(time.Duration(minutes) * time.Minute)
+	}
+	tasks := []task{
+		{ID: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusComplete, StartTime: at(0), CompleteTime: at(30)},
+		{ID: "b", Agent: "1003", Priorty: "high", Skills: []string{"skill1", "skill2"}, Status: statusCancelled, StartTime: at(20), CompleteTime: at(50)},
+		{ID: "c", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: at(70)},
+		{ID: "d", Agent: "1003", Priorty: "low", Skills: []string{"skill1"}, Status: statusStarted, StartTime: at(-30)},
+	}
+	events := []event{
+		{Type: eventTaskAccepted, Task: "a", CreateTime: at(10)},
+		{Type: eventTaskStarted, Task: "d", CreateTime: at(80)},
+	}
+	p := reportPeriod{From: from, To: at(120), Window: time.Hour}
+	first, second := window{From: from, To: at(60)}, window{From: at(60), To: at(120)}
+	want := []queueReport{
+		{window: first, Skill: "skill1", Priority: "high", Created: 1, Cancelled: 1},
+		{window: first, Skill: "skill1", Priority: "low", Created: 1, Completed: 1, Open: 1, Queued: 1, AvgWait: 600, P95Wait: 600, Throughput: 1},
+		{window: first, Skill: "skill2", Priority: "high", Created: 1, Cancelled: 1},
+		{window: second, Skill: "skill1", Priority: "high"},
+		{window: second, Skill: "skill1", Priority: "low", Created: 1, Open: 2, Queued: 1, AvgWait: 6600, P95Wait: 6600},
+		{window: second, Skill: "skill2", Priority: "high"},
+	}
+	if got := queueReports(tasks, events, p, at(100)); !reflect.DeepEqual(got, want) {
+		t.Errorf("queueReports() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_skillCoverages(t *testing.T) {
+	skills := []skill{{Skill: "skill1"}, {Skill: "skill2"}, {Skill: "skill3"}}
+	agents := map[string]int{"skill1": 2, "skill2": 1}
+	open := []task{
+		{ID: "a", Skills: []string{"skill1"}, Status: statusAssigned},
+		{ID: "b", Skills: []string{"skill1", "skill3"}, Status: statusStarted},
+	}
+	tests := []struct {
+		name      string
+		maxAgents int
+		want      []skillCoverage
+	}{
+		{
+			name:      "Every skill",
+			maxAgents: -1,
+			want: []skillCoverage{
+				{skill: skill{Skill: "skill3"}, Open: 1},
+				{skill: skill{Skill: "skill2"}, Agents: 1},
+				{skill: skill{Skill: "skill1"}, Agents: 2, Open: 2, Queued: 1},
+			},
+		},
+		{
+			name:      "Few agents",
+			maxAgents: 1,
+			want: []skillCoverage{
+				{skill: skill{Skill: "skill3"}, Open: 1},
+				{skill: skill{Skill: "skill2"}, Agents: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skillCoverages(skills, agents, open, tt.maxAgents); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("skillCoverages() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Store_reports(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			ctx := context.Background()
+			if err := s.CreateSkill(ctx, DefaultTenant, skill{Skill: "skill4", Description: "A new skill"}); err != nil {
+				t.Fatalf("Store.CreateSkill() error = %v", err)
+			}
+			want := map[string]int{"skill1": 2, "skill2": 1, "skill3": 3, "skill4": 0}
+			if got, err := s.SkillAgents(ctx, DefaultTenant); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("Store.SkillAgents() = %v, %v, want %v", got, err, want)
+			}
+
+			now := time.Now()
+			if err := s.CreateTask(ctx, DefaultTenant, task{ID: "a", Name: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: now}); err != nil {
+				t.Fatalf("Store.CreateTask() error = %v", err)
+			}
+			for _, e := range []event{
+				{Type: eventTaskAssigned, Task: "a", Agent: "1000", Status: statusAssigned, CreateTime: now.Add(-time.Hour)},
+				{Type: eventTaskAccepted, Task: "a", Agent: "1000", Status: statusAccepted, CreateTime: now},
+			} {
+				if err := s.CreateEvent(ctx, DefaultTenant, e); err != nil {
+					t.Fatalf("Store.CreateEvent() error = %v", err)
+				}
+			}
+			events, err := s.EventsBetween(ctx, DefaultTenant, now.Add(-time.Minute), now.Add(time.Minute))
+			if err != nil || len(events) != 1 || events[0].Type != eventTaskAccepted || !events[0].CreateTime.Equal(now) {
+				t.Errorf("Store.EventsBetween() = %+v, %v, want the accepted event", events, err)
+			}
+		})
+	}
+}
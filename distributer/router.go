@@ -0,0 +1,124 @@
+package distributer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// pathParamsKey is the request context key holding the path parameters.
+type pathParamsKey struct{}
+
+// route is a method and path pattern, like /v1/task/{id}, for a handler.
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// router matches requests to the routes by method and path.  Unknown paths
+// are a 404 and a known path with an unsupported method is a 405.
+type router struct {
+	routes []route
+}
+
+func newRouter() *router {
+	return &router{}
+}
+
+// handle adds a handler for the method and pattern.  Segments of the pattern
+// in braces are path parameters, retrieved with pathParam.
+func (rt *router) handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		pattern:  pattern,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	segments := splitPath(request.URL.Path)
+
+	var match *route
+	var matchParams map[string]string
+	matchStatic := -1
+	allowed := map[string]bool{}
+	for idx := range rt.routes {
+		r := &rt.routes[idx]
+		params, static, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+		allowed[r.method] = true
+		if r.method != request.Method || static <= matchStatic {
+			continue
+		}
+		match = r
+		matchParams = params
+		matchStatic = static
+	}
+
+	switch {
+	case match != nil:
+		setRoute(request.Context(), match.pattern)
+		ctx := context.WithValue(request.Context(), pathParamsKey{}, matchParams)
+		match.handler(writer, request.WithContext(ctx))
+	case len(allowed) > 0:
+		methods := make([]string, 0, len(allowed))
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		writer.Header().Set("Allow", strings.Join(methods, ", "))
+		formatError(writer, request, newAPIError(http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method is not supported %s", request.Method))
+	default:
+		formatError(writer, request, newAPIError(http.StatusNotFound, codeNotFound, "Route %s is not present", request.URL.Path))
+	}
+}
+
+// match returns the path parameters and the number of static segments if the
+// route matches the path segments.
+func (r *route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	static := 0
+	for idx, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[idx] == "" {
+				return nil, 0, false
+			}
+			params[segment[1:len(segment)-1]] = segments[idx]
+			continue
+		}
+		if segment != segments[idx] {
+			return nil, 0, false
+		}
+		static++
+	}
+	return params, static, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// pathParam returns the named path parameter of the matched route.
+func pathParam(request *http.Request, name string) string {
+	params, _ := request.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// deprecated marks the handler's route as a deprecated alias of the successor.
+func deprecated(successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Deprecation", "true")
+		writer.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		handler(writer, request)
+	}
+}
@@ -0,0 +1,94 @@
+package distributer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_router_ServeHTTP(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("X-Route", name)
+			writer.Header().Set("X-Id", pathParam(request, "id"))
+		}
+	}
+	r := newRouter()
+	r.handle(http.MethodPost, "/v1/task/create", handler("create"))
+	r.handle(http.MethodGet, "/v1/task/{id}", handler("status"))
+	r.handle(http.MethodPost, "/v1/task/{id}/complete", handler("complete"))
+	r.handle(http.MethodGet, "/v1/task/complete/{id}", deprecated("/v1/task/{id}/complete", handler("complete")))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantRoute  string
+		wantID     string
+		wantAllow  string
+	}{
+		{
+			name:       "Path parameter",
+			method:     http.MethodGet,
+			target:     "/v1/task/bj7rmmrk7c874r7vb8ng",
+			wantStatus: http.StatusOK,
+			wantRoute:  "status",
+			wantID:     "bj7rmmrk7c874r7vb8ng",
+		},
+		{
+			name:       "Query string",
+			method:     http.MethodGet,
+			target:     "/v1/task/bj7rmmrk7c874r7vb8ng?verbose=true",
+			wantStatus: http.StatusOK,
+			wantRoute:  "status",
+			wantID:     "bj7rmmrk7c874r7vb8ng",
+		},
+		{
+			name:       "Static route",
+			method:     http.MethodPost,
+			target:     "/v1/task/create",
+			wantStatus: http.StatusOK,
+			wantRoute:  "create",
+		},
+		{
+			name:       "Deprecated alias",
+			method:     http.MethodGet,
+			target:     "/v1/task/complete/bj7rmmrk7c874r7vb8ng",
+			wantStatus: http.StatusOK,
+			wantRoute:  "complete",
+			wantID:     "bj7rmmrk7c874r7vb8ng",
+		},
+		{
+			name:       "Method not allowed",
+			method:     http.MethodGet,
+			target:     "/v1/task/bj7rmmrk7c874r7vb8ng/complete",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "POST",
+		},
+		{
+			name:       "Not found",
+			method:     http.MethodGet,
+			target:     "/v1/skill",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("router.ServeHTTP() status = %v, want %v", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("X-Route"); got != tt.wantRoute {
+				t.Errorf("router.ServeHTTP() route = %v, want %v", got, tt.wantRoute)
+			}
+			if got := recorder.Header().Get("X-Id"); got != tt.wantID {
+				t.Errorf("router.ServeHTTP() id = %v, want %v", got, tt.wantID)
+			}
+			if got := recorder.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("router.ServeHTTP() allow = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package distributer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Config is the bucket of an S3 compatible service and its credentials.
+type s3Config struct {
+	// Endpoint is the base URL of the service, the bucket is in the path so
+	// it also works with services without virtual host buckets.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// s3BlobStore is the BlobStore keeping each blob as an object of an S3
+// compatible bucket, signing the requests with AWS Signature Version 4.
+type s3BlobStore struct {
+	config s3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func newS3BlobStore(config s3Config) (*s3BlobStore, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3.amazonaws.com"
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("s3 bucket must be present")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3 credentials must be present")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("s3 endpoint %s is not valid: %w", config.Endpoint, err)
+	}
+	return &s3BlobStore{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}, nil
+}
+
+// do sends a signed request for the object of the key.
+func (s *s3BlobStore) do(ctx context.Context, method, key string, header http.Header, body []byte) (*http.Response, error) {
+	if !validBlobKey(key) {
+		return nil, fmt.Errorf("blob key %s is not valid", key)
+	}
+	target := strings.TrimSuffix(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + key
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	signV4(request, body, s.config.Region, "s3", s.config.AccessKey, s.config.SecretKey, s.now())
+	return s.client.Do(request)
+}
+
+// s3Error returns the error of a failed response, with the start of the body
+// that explains it.
+func s3Error(op string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s returned %s %s", op, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(ctx, http.MethodPut, key, header, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *s3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", errBlobNotFound, key)
+	default:
+		return nil, s3Error("get", resp)
+	}
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error("delete", resp)
+	}
+	return nil
+}
+
+// signV4 signs the request with AWS Signature Version 4, in the Authorization
+// header.  The host, the content type and every x-amz- header are signed.
+func signV4(request *http.Request, body []byte, region, service, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
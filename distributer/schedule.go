@@ -0,0 +1,185 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// schedule holds a task until it is due, at its start time, or distributes a
+// new task each time its cron expression matches.  A schedule without a cron
+// expression is done once its task is distributed, and has no next run.
+type schedule struct {
+	ID string `json:"id"`
+	// Task is the payload of the tasks the schedule distributes.
+	Task    payload    `json:"task"`
+	Cron    string     `json:"cron,omitempty"`
+	StartAt *time.Time `json:"start_at,omitempty"`
+	Paused  bool       `json:"paused"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	scheduleRun
+	// Author is the subject of the API key or token that created it.
+	Author     string    `json:"author"`
+	CreateTime time.Time `json:"create_time"`
+	// tenant is only set by DueSchedules, which returns the schedules of
+	// every tenant.
+	tenant string
+}
+
+// copy returns the schedule without sharing the task's skills, tags or
+// metadata.
+func (sc schedule) copy() schedule {
+	sc.Task.Skills = append([]string(nil), sc.Task.Skills...)
+	sc.Task.taskDetails = sc.Task.taskDetails.copy()
+	return sc
+}
+
+// scheduleRun is the outcome of the last time the schedule was due, the task
+// it distributed or why no task was.
+type scheduleRun struct {
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastTask  string     `json:"last_task,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// nextRun returns when the schedule is due after the time, which is nil for a
+// schedule without a cron expression once its task is distributed.
+func (sc schedule) nextRun(after time.Time) *time.Time {
+	if sc.Cron == "" {
+		return nil
+	}
+	c, err := parseCron(sc.Cron)
+	if err != nil {
+		return nil
+	}
+	if sc.StartAt != nil && sc.StartAt.After(after) {
+		after = sc.StartAt.Add(-time.Second)
+	}
+	next := c.next(after)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// firstRun returns when a new, or resumed, schedule is due, which is its start
+// time or the first time its cron expression matches after it.
+func (sc schedule) firstRun(now time.Time) *time.Time {
+	if sc.Cron == "" {
+		if sc.LastTask != "" {
+			return nil
+		}
+		start := sc.StartAt.UTC().Truncate(time.Second)
+		return &start
+	}
+	return sc.nextRun(now)
+}
+
+// schedulePayload is the body of a new schedule, which needs a cron
+// expression, a start time or both.
+type schedulePayload struct {
+	Task    payload    `json:"task"`
+	Cron    string     `json:"cron,omitempty"`
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// validate normalizes the schedule and its task and returns every invalid
+// field, the task's under task.  The error is only returned when the skills
+// or priorities can not be retrieved.
+func (p *schedulePayload) validate(ctx context.Context, store Store, tenant string) ([]fieldError, error) {
+	v := &validator{}
+	invalid, err := p.Task.validate(ctx, store, tenant)
+	if err != nil {
+		return nil, err
+	}
+	for _, fe := range invalid {
+		fe.Field = "task." + fe.Field
+		v.invalid = append(v.invalid, fe)
+	}
+	if p.Task.StartAt != nil {
+		v.add("task.start_at", fieldUnsupported, "task.start_at field is not supported, use start_at")
+	}
+	p.Cron = v.optionalText("cron", p.Cron, maxCronLength)
+	switch {
+	case p.Cron == "" && p.StartAt == nil:
+		v.add("cron", fieldRequired, "cron or start_at field must be present")
+	case p.Cron != "" && !v.has("cron"):
+		if _, err := parseCron(p.Cron); err != nil {
+			v.add("cron", fieldInvalid, "cron field must be a cron expression, %s", err.Error())
+		}
+	}
+	return v.invalid, nil
+}
+
+// maxDueSchedules is the most schedules distributed at each interval, the
+// rest are distributed at the next one.
+const maxDueSchedules = 100
+
+// RunScheduler distributes the tasks of the schedules as they are due, every
+// ScheduleInterval, until the context is done.  ListenAndServe runs it, a
+// Server embedded in another service must run it for the schedules to be
+// distributed.
+func (s *Server) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.config.ScheduleInterval)
+	defer ticker.Stop()
+	for {
+		s.runDueSchedules(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueSchedules distributes the task of each schedule due at the time.
+func (s *Server) runDueSchedules(ctx context.Context, now time.Time) {
+	now = now.UTC().Truncate(time.Second)
+	due, err := s.store.DueSchedules(ctx, now, maxDueSchedules)
+	if err != nil {
+		s.logger.Error("unable to retrieve due schedules", "error", err)
+		return
+	}
+	for _, sc := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runSchedule(ctx, sc, now)
+	}
+}
+
+// runSchedule distributes the task of the due schedule once it is claimed, so
+// it is only distributed once when servers share the store.  When no agent is
+// available the task is retried at the next interval, a recurring schedule
+// then skips the times it missed.
+func (s *Server) runSchedule(ctx context.Context, sc schedule, now time.Time) {
+	logger := s.logger.With("tenant", sc.tenant, "schedule", sc.ID)
+	ctx = withLogger(ctx, logger)
+	retry := now.Add(s.config.ScheduleInterval)
+	claimed, err := s.store.ClaimSchedule(ctx, sc.tenant, sc.ID, *sc.NextRun, retry)
+	if err != nil {
+		logger.Error("unable to claim schedule", "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	run := scheduleRun{LastRun: &now}
+	next := &retry
+	t, err := s.distribute(ctx, sc.tenant, sc.Task)
+	switch {
+	case errors.Is(err, errNoAgent):
+		logger.Info("no agent available for scheduled task, retrying", "retry", retry)
+		run.LastError = err.Error()
+	case err != nil:
+		logger.Error("unable to distribute scheduled task", "error", err)
+		run.LastError = err.Error()
+	default:
+		logger.Info("distributed scheduled task", "task", t.ID, "agent", t.Agent)
+		run.LastTask = t.ID
+		next = sc.nextRun(now)
+	}
+	if err := s.store.RecordScheduleRun(context.WithoutCancel(ctx), sc.tenant, sc.ID, run, next); err != nil {
+		logger.Error("unable to record schedule run", "error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package distributer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_schedulePayload_validate(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	task := payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low"}
+	tests := []struct {
+		name        string
+		payload     schedulePayload
+		wantInvalid []string
+	}{
+		{name: "Cron", payload: schedulePayload{Task: task, Cron: "0 9 * * mon-fri"}},
+		{name: "Start time", payload: schedulePayload{Task: task, StartAt: &future}},
+		{name: "Cron after a start time", payload: schedulePayload{Task: task, Cron: "@hourly", StartAt: &future}},
+		{name: "No cron or start time", payload: schedulePayload{Task: task}, wantInvalid: []string{"cron"}},
+		{name: "Invalid cron", payload: schedulePayload{Task: task, Cron: "every day"}, wantInvalid: []string{"cron"}},
+		{
+			name:        "Invalid task",
+			payload:     schedulePayload{Task: payload{Skills: []string{"skill9"}, Priorty: "low", StartAt: &future}, Cron: "@daily"},
+			wantInvalid: []string{"task.name", "task.skills", "task.start_at"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalid, err := tt.payload.validate(context.Background(), newTestStore(t), DefaultTenant)
+			if err != nil {
+				t.Fatalf("schedulePayload.validate() error = %v", err)
+			}
+			var got []string
+			for _, fe := range invalid {
+				got = append(got, fe.Field)
+			}
+			if len(got) != len(tt.wantInvalid) {
+				t.Fatalf("schedulePayload.validate() = %v, want %v", got, tt.wantInvalid)
+			}
+			for idx := range got {
+				if got[idx] != tt.wantInvalid[idx] {
+					t.Errorf("schedulePayload.validate() = %v, want %v", got, tt.wantInvalid)
+				}
+			}
+		})
+	}
+}
+
+func Test_Store_Schedules(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			due := now.Add(-time.Minute)
+			later := now.Add(time.Hour)
+			schedules := []schedule{
+				{ID: "a", Task: payload{Name: "Daily", Skills: []string{"skill1"}, Priorty: "low", taskDetails: taskDetails{Tags: []string{"billing"}}}, Cron: "@daily", NextRun: &due, Author: "key1", CreateTime: now},
+				{ID: "b", Task: payload{Name: "Later", Skills: []string{"skill2"}, Priorty: "high"}, StartAt: &later, NextRun: &later, Author: "key1", CreateTime: now.Add(time.Second)},
+			}
+			for _, sc := range schedules {
+				if err := s.CreateSchedule(ctx, DefaultTenant, sc); err != nil {
+					t.Fatalf("Store.CreateSchedule() error = %v", err)
+				}
+			}
+			got, err := s.Schedules(ctx, DefaultTenant)
+			if err != nil || len(got) != 2 || got[0].ID != "a" || got[0].Task.Tags[0] != "billing" || got[1].StartAt == nil || !got[1].StartAt.Equal(later) {
+				t.Errorf("Store.Schedules() = %+v, %v, want both schedules oldest first", got, err)
+			}
+			if _, err := s.Schedule(ctx, DefaultTenant, "unknown"); err == nil {
+				t.Errorf("Store.Schedule() of an unknown schedule error = nil, want an error")
+			}
+
+			dueSchedules, err := s.DueSchedules(ctx, now, 10)
+			if err != nil || len(dueSchedules) != 1 || dueSchedules[0].ID != "a" || dueSchedules[0].tenant != DefaultTenant {
+				t.Fatalf("Store.DueSchedules() = %+v, %v, want the due schedule of the tenant", dueSchedules, err)
+			}
+			retry := now.Add(15 * time.Second)
+			if claimed, err := s.ClaimSchedule(ctx, DefaultTenant, "a", due, retry); err != nil || !claimed {
+				t.Errorf("Store.ClaimSchedule() = %v, %v, want true", claimed, err)
+			}
+			if claimed, err := s.ClaimSchedule(ctx, DefaultTenant, "a", due, retry); err != nil || claimed {
+				t.Errorf("Store.ClaimSchedule() twice = %v, %v, want false", claimed, err)
+			}
+			if err := s.RecordScheduleRun(ctx, DefaultTenant, "a", scheduleRun{LastRun: &now, LastTask: "t1"}, &later); err != nil {
+				t.Errorf("Store.RecordScheduleRun() error = %v", err)
+			}
+			sc, err := s.Schedule(ctx, DefaultTenant, "a")
+			if err != nil || sc.LastTask != "t1" || sc.LastRun == nil || !sc.LastRun.Equal(now) || sc.NextRun == nil || !sc.NextRun.Equal(later) {
+				t.Errorf("Store.Schedule() = %+v, %v, want the recorded run", sc, err)
+			}
+
+			if updated, err := s.SetSchedulePaused(ctx, DefaultTenant, "b", true, &later); err != nil || !updated {
+				t.Errorf("Store.SetSchedulePaused() = %v, %v, want true", updated, err)
+			}
+			if dueSchedules, err := s.DueSchedules(ctx, later, 10); err != nil || len(dueSchedules) != 1 || dueSchedules[0].ID != "a" {
+				t.Errorf("Store.DueSchedules() = %+v, %v, want only the schedule that is not paused", dueSchedules, err)
+			}
+			if deleted, err := s.DeleteSchedule(ctx, DefaultTenant, "b"); err != nil || !deleted {
+				t.Errorf("Store.DeleteSchedule() = %v, %v, want true", deleted, err)
+			}
+			if deleted, err := s.DeleteSchedule(ctx, DefaultTenant, "b"); err != nil || deleted {
+				t.Errorf("Store.DeleteSchedule() twice = %v, %v, want false", deleted, err)
+			}
+		})
+	}
+}
+
+func Test_Server_runDueSchedules(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			store := ts.open(t)
+			s, err := NewServer(store, Config{ScheduleInterval: time.Minute})
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			due := now.Add(-time.Minute)
+			schedules := []schedule{
+				{ID: "once", Task: payload{Name: "Once", Skills: []string{"skill1"}, Priorty: "low"}, StartAt: &due, NextRun: &due, Author: "key1", CreateTime: now},
+				{ID: "hourly", Task: payload{Name: "Hourly", Skills: []string{"skill2"}, Priorty: "low"}, Cron: "@hourly", NextRun: &due, Author: "key1", CreateTime: now},
+				{ID: "unassignable", Task: payload{Name: "Unassignable", Skills: []string{"skill1", "skill2"}, Priorty: "low"}, StartAt: &due, NextRun: &due, Author: "key1", CreateTime: now},
+			}
+			for _, sc := range schedules {
+				if err := store.CreateSchedule(ctx, DefaultTenant, sc); err != nil {
+					t.Fatalf("Store.CreateSchedule() error = %v", err)
+				}
+			}
+			s.runDueSchedules(ctx, now)
+
+			once, _ := store.Schedule(ctx, DefaultTenant, "once")
+			if once.LastTask == "" || once.NextRun != nil {
+				t.Errorf("one time schedule = %+v, want done with its task", once)
+			}
+			tsk, err := store.Task(ctx, DefaultTenant, once.LastTask)
+			if err != nil || tsk.Name != "Once" || tsk.Status != statusAssigned {
+				t.Errorf("Store.Task() = %+v, %v, want the assigned task of the schedule", tsk, err)
+			}
+			hourly, _ := store.Schedule(ctx, DefaultTenant, "hourly")
+			if hourly.LastTask == "" || hourly.NextRun == nil || !hourly.NextRun.After(now) || hourly.NextRun.Minute() != 0 {
+				t.Errorf("recurring schedule = %+v, want its task and the next hour", hourly)
+			}
+			unassignable, _ := store.Schedule(ctx, DefaultTenant, "unassignable")
+			if unassignable.LastError == "" || unassignable.NextRun == nil || !unassignable.NextRun.Equal(now.Add(time.Minute)) {
+				t.Errorf("unassignable schedule = %+v, want retried at the next interval", unassignable)
+			}
+
+			s.runDueSchedules(ctx, now)
+			tasks, err := store.Tasks(ctx, DefaultTenant, taskFilter{})
+			if err != nil || len(tasks) != 2 {
+				t.Errorf("Store.Tasks() = %v, %v, want each due task distributed once", tasks, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+// Package distributer distributes tasks to the agents with the skills to work
+// on them, preempting the agent on the lowest priority task when every agent
+// is busy.  It can be run on its own or embedded in another service by
+// serving a Server.
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Config is the configuration of a Server.
+type Config struct {
+	// JWTSecret is the HMAC secret for bearer tokens, JWTs are not accepted
+	// when it is empty.
+	JWTSecret []byte
+	// Logger defaults to info level text on the standard error, NewLogger
+	// returns a json logger for production.
+	Logger *slog.Logger
+	// ReadTimeout, WriteTimeout and IdleTimeout limit the connections of
+	// ListenAndServe, they default to 10s, 30s and 2m.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long ListenAndServe waits for the requests in
+	// flight once it is stopped, it defaults to 25s which is within Heroku's
+	// 30s between SIGTERM and SIGKILL.
+	ShutdownTimeout time.Duration
+	// MetricsToken is the bearer token a scraper sends to /metrics for the
+	// metrics of every tenant.  An admin key or JWT may also scrape them,
+	// with only the gauges of its own tenant.
+	MetricsToken string
+	// AnonymousMetrics serves the metrics of every tenant to requests without
+	// any credentials, which exposes the tenant and agent ids to anyone who
+	// can reach the port.
+	AnonymousMetrics bool
+	// Tracer traces the requests and store calls, nothing is traced when it
+	// is nil.
+	Tracer *Tracer
+	// Build is served by /version, the commit and build time default to the
+	// ones the go tool records from git.
+	Build BuildInfo
+	// Blobs keeps the contents of the task attachments, it defaults to files
+	// under ./attachments.  OpenBlobStore opens a local or S3 blob store.
+	Blobs BlobStore
+	// MaxAttachmentSize is the largest attachment in bytes, it defaults to
+	// 10MB.
+	MaxAttachmentSize int64
+	// AttachmentTypes are the media types attachments can have, they default
+	// to PDF, JSON, ZIP, GIF, JPEG, PNG, CSV and plain text.
+	AttachmentTypes []string
+	// ScheduleInterval is how often the scheduler distributes the due
+	// schedules, and retries the tasks no agent was available for, it
+	// defaults to 15s.
+	ScheduleInterval time.Duration
+}
+
+// Server is the distributer's API, serving the requests with its own store.
+type Server struct {
+	store     Store
+	blobs     BlobStore
+	jwtSecret []byte
+	logger    *slog.Logger
+	handler   http.Handler
+	config    Config
+	metrics   *metrics
+	tracer    *Tracer
+	build     BuildInfo
+	// draining is set once ListenAndServe is shutting down, so /readyz fails
+	// while the requests in flight finish.
+	draining atomic.Bool
+}
+
+// NewServer returns a server of the store, which can be opened with
+// OpenStore.
+func NewServer(store Store, config Config) (*Server, error) {
+	if store == nil {
+		return nil, errors.New("store must be present")
+	}
+	s := &Server{
+		store:     traceStore(store, config.Tracer),
+		jwtSecret: config.JWTSecret,
+		logger:    config.Logger,
+		config:    config,
+		metrics:   newMetrics(),
+		tracer:    config.Tracer,
+		build:     buildInfo(config.Build),
+	}
+	if s.logger == nil {
+		s.logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if s.config.ReadTimeout == 0 {
+		s.config.ReadTimeout = 10 * time.Second
+	}
+	if s.config.WriteTimeout == 0 {
+		s.config.WriteTimeout = 30 * time.Second
+	}
+	if s.config.IdleTimeout == 0 {
+		s.config.IdleTimeout = 2 * time.Minute
+	}
+	if s.config.ShutdownTimeout == 0 {
+		s.config.ShutdownTimeout = 25 * time.Second
+	}
+	if s.config.Blobs == nil {
+		s.config.Blobs = newLocalBlobStore(defaultBlobDir)
+	}
+	s.blobs = traceBlobStore(s.config.Blobs, s.tracer)
+	if s.config.MaxAttachmentSize == 0 {
+		s.config.MaxAttachmentSize = defaultMaxAttachmentSize
+	}
+	if len(s.config.AttachmentTypes) == 0 {
+		s.config.AttachmentTypes = defaultAttachmentTypes
+	}
+	if s.config.ScheduleInterval == 0 {
+		s.config.ScheduleInterval = 15 * time.Second
+	}
+	s.handler = s.requestLogger(s.instrument(s.trace(s.routes())))
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	s.handler.ServeHTTP(writer, request)
+}
+
+// ListenAndServe serves the requests on the address, like :5000, until the
+// context is done.  It then stops accepting connections and waits up to the
+// ShutdownTimeout for the requests in flight, whose contexts are canceled if
+// they are still running when it returns.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	baseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      s,
+		ReadTimeout:  s.config.ReadTimeout,
+		WriteTimeout: s.config.WriteTimeout,
+		IdleTimeout:  s.config.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn),
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		s.logger.Info("listening", "addr", addr)
+		errs <- srv.ListenAndServe()
+	}()
+	schedulerCtx, stopScheduler := context.WithCancel(ctx)
+	defer stopScheduler()
+	go s.RunScheduler(schedulerCtx)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	s.draining.Store(true)
+	s.logger.Info("shutting down, waiting for requests in flight", "timeout", s.config.ShutdownTimeout)
+	shutdownCtx, stop := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("unable to shut down gracefully %s", err.Error())
+	}
+	return nil
+}
+
+// IssueAPIKey issues a new key with the role in the tenant and returns the key,
+// the key is not stored and can not be retrieved again.
+func (s *Server) IssueAPIKey(ctx context.Context, tenant, name, role, agentID string) (string, error) {
+	key, err := s.issueAPIKey(ctx, tenant, name, role, agentID)
+	if err != nil {
+		return "", err
+	}
+	return key.Key, nil
+}
+
+// formatResponse writes the successful response as JSON.
+func formatResponse(writer http.ResponseWriter, response interface{}) {
+	resp, err := json.Marshal(response)
+	if err != nil {
+		formatError(writer, nil, internalError(err, "Unable to encode response"))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(resp)
+}
+
+// OpenStore opens the store for the database URL.  A memory:// URL keeps
+// everything in memory, seeded like a new database, which is handy for local
+// runs without Postgres.  A sqlite://<file> URL keeps everything in the SQLite
+// file, which is migrated when it is opened.  Any other URL is Postgres.
+func OpenStore(ctx context.Context, databaseURL string) (Store, error) {
+	switch {
+	case strings.HasPrefix(databaseURL, "memory://"):
+		s := newMemoryStore()
+		if err := s.seed(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		db, err := sql.Open("sqlite3", sqliteDSN(strings.TrimPrefix(databaseURL, "sqlite://")))
+		if err != nil {
+			return nil, err
+		}
+		// SQLite only allows one writer, so every request shares a connection.
+		db.SetMaxOpenConns(1)
+		m, err := newMigrator(db, dialectSQLite)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := m.up(ctx, 0); err != nil {
+			return nil, err
+		}
+		return newSQLiteStore(db), nil
+	default:
+		db, err := sql.Open("postgres", databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return newPostgresStore(db), nil
+	}
+}
+
+// NewPostgresStore returns the store of an open Postgres database, which must
+// be migrated with the migrate command.
+func NewPostgresStore(db *sql.DB) Store {
+	return newPostgresStore(db)
+}
+
+// routes registers the handlers for the APIs and the roles allowed to use them.
+func (s *Server) routes() http.Handler {
+	r := newRouter()
+
+	r.handle(http.MethodGet, "/v1/task", s.authorize(s.listTaskHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/task/create", s.authorize(s.createTaskHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/task/{id}", s.authorize(s.statusTaskHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/task/{id}/complete", s.authorize(s.completeTaskHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/task/{id}/cancel", s.authorize(s.cancelTaskHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/task/{id}/comments", s.authorize(s.listCommentsHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/task/{id}/comments", s.authorize(s.createCommentHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/task/{id}/attachments", s.authorize(s.listAttachmentsHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/task/{id}/attachments", s.authorize(s.uploadAttachmentHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/task/{id}/attachments/{attachment}", s.authorize(s.downloadAttachmentHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/event", s.authorize(s.listEventHandler, RoleAdmin, RoleSubmitter))
+
+	r.handle(http.MethodGet, "/v1/schedule", s.authorize(s.listScheduleHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/schedule", s.authorize(s.createScheduleHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/schedule/{id}", s.authorize(s.scheduleHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/schedule/{id}/pause", s.authorize(s.pauseScheduleHandler(true), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/schedule/{id}/resume", s.authorize(s.pauseScheduleHandler(false), RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodDelete, "/v1/schedule/{id}", s.authorize(s.deleteScheduleHandler, RoleAdmin, RoleSubmitter))
+
+	r.handle(http.MethodGet, "/v1/template", s.authorize(s.listTemplateHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/template", s.authorize(s.createTemplateHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/template/{name}", s.authorize(s.templateHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodDelete, "/v1/template/{name}", s.authorize(s.deleteTemplateHandler, RoleAdmin, RoleSubmitter))
+
+	r.handle(http.MethodGet, "/v1/agent", s.authorize(s.listAgentHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/agent", s.authorize(s.createAgentHandler, RoleAdmin))
+	r.handle(http.MethodPut, "/v1/agent/{id}/skills", s.authorize(s.updateAgentSkillsHandler, RoleAdmin))
+	r.handle(http.MethodGet, "/v1/agent/{id}/tasks", s.authorizeAgent(s.agentTasksHandler))
+	r.handle(http.MethodPost, "/v1/agent/{id}/tasks/{task}/accept", s.authorizeAgent(s.agentTaskActionHandler("accept")))
+	r.handle(http.MethodPost, "/v1/agent/{id}/tasks/{task}/start", s.authorizeAgent(s.agentTaskActionHandler("start")))
+	r.handle(http.MethodPost, "/v1/agent/{id}/tasks/{task}/complete", s.authorizeAgent(s.agentTaskActionHandler("complete")))
+	r.handle(http.MethodGet, "/v1/agent/{id}/tasks/{task}/comments", s.authorizeAgent(s.listCommentsHandler(true)))
+	r.handle(http.MethodPost, "/v1/agent/{id}/tasks/{task}/comments", s.authorizeAgent(s.createCommentHandler(true)))
+	r.handle(http.MethodGet, "/v1/agent/{id}/tasks/{task}/attachments", s.authorizeAgent(s.listAttachmentsHandler(true)))
+	r.handle(http.MethodPost, "/v1/agent/{id}/tasks/{task}/attachments", s.authorizeAgent(s.uploadAttachmentHandler(true)))
+	r.handle(http.MethodGet, "/v1/agent/{id}/tasks/{task}/attachments/{attachment}", s.authorizeAgent(s.downloadAttachmentHandler(true)))
+
+	r.handle(http.MethodGet, "/v1/report/agents", s.authorize(s.agentReportHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/report/queues", s.authorize(s.queueReportHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodGet, "/v1/report/skills", s.authorize(s.skillReportHandler, RoleAdmin, RoleSubmitter))
+
+	r.handle(http.MethodGet, "/v1/skill", s.authorize(s.listSkillHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/skill", s.authorize(s.createSkillHandler, RoleAdmin))
+	r.handle(http.MethodGet, "/v1/priority", s.authorize(s.listPriorityHandler, RoleAdmin, RoleSubmitter))
+	r.handle(http.MethodPost, "/v1/priority", s.authorize(s.createPriorityHandler, RoleAdmin))
+
+	r.handle(http.MethodGet, "/v1/apikey", s.authorize(s.listAPIKeyHandler, RoleAdmin))
+	r.handle(http.MethodPost, "/v1/apikey", s.authorize(s.createAPIKeyHandler, RoleAdmin))
+	r.handle(http.MethodDelete, "/v1/apikey/{id}", s.authorize(s.revokeAPIKeyHandler, RoleAdmin))
+
+	r.handle(http.MethodGet, "/dashboard", s.dashboardHandler)
+	r.handle(http.MethodGet, "/dashboard/{file}", s.dashboardHandler)
+
+	r.handle(http.MethodGet, "/metrics", s.metricsHandler)
+	r.handle(http.MethodGet, "/healthz", s.healthzHandler)
+	r.handle(http.MethodGet, "/readyz", s.readyzHandler)
+	r.handle(http.MethodGet, "/version", s.versionHandler)
+
+	// Deprecated aliases of the original routes.
+	r.handle(http.MethodGet, "/v1/task/complete/{id}", deprecated("/v1/task/{id}/complete", s.authorize(s.completeTaskHandler, RoleAdmin, RoleSubmitter)))
+	r.handle(http.MethodGet, "/v1/agent/list", deprecated("/v1/agent", s.authorize(s.listAgentHandler, RoleAdmin, RoleSubmitter)))
+
+	return r
+}
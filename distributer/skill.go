@@ -0,0 +1,13 @@
+package distributer
+
+// skill is the payload for the database and HTTP response
+type skill struct {
+	Skill       string `json:"skill"`
+	Description string `json:"description"`
+}
+
+// priority is the payload for the database and HTTP response
+type priority struct {
+	Priority string `json:"priority"`
+	Level    int    `json:"priority_level"`
+}
@@ -0,0 +1,1056 @@
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var _ Store = (*sqliteStore)(nil)
+
+// sqliteStore is the Store backed by a SQLite database file, so the distributer
+// can run as a single binary without a database server.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(db *sql.DB) *sqliteStore {
+	return &sqliteStore{
+		db: db,
+	}
+}
+
+// sqliteDSN is the data source for the database file, with the foreign keys
+// enforced and waiting on a locked database rather than failing.
+func sqliteDSN(file string) string {
+	sep := "?"
+	if strings.Contains(file, "?") {
+		sep = "&"
+	}
+	return "file:" + file + sep + "_foreign_keys=on&_busy_timeout=5000"
+}
+
+// inList returns the placeholders for an IN list of the values and the values
+// as query arguments.
+func inList(values []string) (string, []interface{}) {
+	placeholders := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for idx, v := range values {
+		placeholders[idx] = "?"
+		args[idx] = v
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// taskSkills selects the skills of the task, from TASKSKILLS, as a JSON array.
+const taskSkills = `(SELECT json_group_array(SKILL) FROM TASKSKILLS WHERE TASKSKILLS.TENANT = TASKS.TENANT AND TASKSKILLS.TASK = TASKS.ID)`
+
+// taskDetailColumns selects the details of the task, scanned by a
+// taskDetailsScanner.
+const taskDetailColumns = `DESCRIPTION, METADATA, TAGS, EXTERNALURL, SLA`
+
+// sortedKeys returns the keys of the map in order, so the queries, and
+// reports, built from it are the same each time.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeSkills(skills string) ([]string, error) {
+	var s []string
+	if err := json.Unmarshal([]byte(skills), &s); err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *sqliteStore) Tenants(ctx context.Context) ([]tenant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT ID, NAME, CREATEDATE FROM TENANTS ORDER BY ID`)
+	if err != nil {
+		logQueryError(ctx, "Tenants", err)
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := []tenant{}
+	for rows.Next() {
+		var t tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreateTime); err != nil {
+			return nil, errors.New("unable to retrieve tenants")
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+func (s *sqliteStore) CreateTenant(ctx context.Context, t tenant) error {
+	stmt := `INSERT INTO TENANTS (ID, NAME, CREATEDATE) VALUES (?, ?, ?)`
+	if _, err := s.db.ExecContext(ctx, stmt, t.ID, t.Name, t.CreateTime); err != nil {
+		logQueryError(ctx, "CreateTenant", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) SkillCount(ctx context.Context, tenant string, skills []string) (int, error) {
+	in, args := inList(skills)
+	stmt := `SELECT COUNT(*) FROM SKILLS WHERE TENANT = ? AND SKILL IN ` + in
+	row := s.db.QueryRowContext(ctx, stmt, append([]interface{}{tenant}, args...)...)
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		logQueryError(ctx, "SkillCount", err)
+		return -1, err
+	}
+	return count, nil
+}
+
+func (s *sqliteStore) Skills(ctx context.Context, tenant string) ([]skill, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT SKILL, DESCRIPTION FROM SKILLS WHERE TENANT = ? ORDER BY SKILL`, tenant)
+	if err != nil {
+		logQueryError(ctx, "Skills", err)
+		return nil, err
+	}
+	defer rows.Close()
+	skills := []skill{}
+	for rows.Next() {
+		var sk skill
+		if err := rows.Scan(&sk.Skill, &sk.Description); err != nil {
+			return nil, errors.New("unable to retrieve skills")
+		}
+		skills = append(skills, sk)
+	}
+	return skills, nil
+}
+
+func (s *sqliteStore) SkillAgents(ctx context.Context, tenant string) (map[string]int, error) {
+	stmt := `
+	SELECT SKILLS.SKILL, COUNT(AGENTSKILLS.AGENT)
+	FROM SKILLS
+	LEFT JOIN AGENTSKILLS ON AGENTSKILLS.TENANT = SKILLS.TENANT AND AGENTSKILLS.SKILL = SKILLS.SKILL
+	WHERE SKILLS.TENANT = ?
+	GROUP BY SKILLS.SKILL
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "SkillAgents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var sk string
+		var count int
+		if err := rows.Scan(&sk, &count); err != nil {
+			return nil, errors.New("unable to retrieve skills")
+		}
+		counts[sk] = count
+	}
+	return counts, nil
+}
+
+func (s *sqliteStore) CreateSkill(ctx context.Context, tenant string, sk skill) error {
+	stmt := `INSERT INTO SKILLS (TENANT, SKILL, DESCRIPTION) VALUES (?, ?, ?)`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, sk.Skill, sk.Description); err != nil {
+		logQueryError(ctx, "CreateSkill", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) PriorityLevel(ctx context.Context, tenant, priority string) (int, error) {
+	stmt := `SELECT PRIORITY_LEVEL FROM PRIORITIES WHERE TENANT = ? AND PRIORITY = ?`
+	row := s.db.QueryRowContext(ctx, stmt, tenant, priority)
+	var level int
+	err := row.Scan(&level)
+	if err != nil {
+		logQueryError(ctx, "PriorityLevel", err)
+		return -1, err
+	}
+	return level, nil
+}
+
+func (s *sqliteStore) Priorities(ctx context.Context, tenant string) ([]priority, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT PRIORITY, PRIORITY_LEVEL FROM PRIORITIES WHERE TENANT = ? ORDER BY PRIORITY_LEVEL`, tenant)
+	if err != nil {
+		logQueryError(ctx, "Priorities", err)
+		return nil, err
+	}
+	defer rows.Close()
+	priorities := []priority{}
+	for rows.Next() {
+		var p priority
+		if err := rows.Scan(&p.Priority, &p.Level); err != nil {
+			return nil, errors.New("unable to retrieve priorities")
+		}
+		priorities = append(priorities, p)
+	}
+	return priorities, nil
+}
+
+func (s *sqliteStore) CreatePriority(ctx context.Context, tenant string, p priority) error {
+	stmt := `INSERT INTO PRIORITIES (TENANT, PRIORITY, PRIORITY_LEVEL) VALUES (?, ?, ?)`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, p.Priority, p.Level); err != nil {
+		logQueryError(ctx, "CreatePriority", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Agents(ctx context.Context, tenant string, ids []string) ([]agent, error) {
+	in, args := inList(ids)
+	stmt := `SELECT ID, FIRSTNAME, LASTNAME FROM AGENTS WHERE TENANT = ? AND ID IN ` + in + ` ORDER BY ID`
+	rows, err := s.db.QueryContext(ctx, stmt, append([]interface{}{tenant}, args...)...)
+	if err != nil {
+		logQueryError(ctx, "Agents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var agents []agent
+	for rows.Next() {
+		var a agent
+		if err := rows.Scan(&a.ID, &a.FirstName, &a.LastName); err != nil {
+			return nil, errors.New("no agents found")
+		}
+		agents = append(agents, a)
+	}
+	if len(agents) == 0 {
+		return nil, errors.New("no agents found")
+	}
+	return agents, nil
+}
+
+func (s *sqliteStore) MatchingAgents(ctx context.Context, tenant string, skills []string) ([]agent, error) {
+	in, args := inList(skills)
+	stmt := `SELECT AGENT FROM AGENTSKILLS WHERE TENANT = ? AND SKILL IN ` + in + ` GROUP BY AGENT HAVING COUNT(*) = ?`
+	args = append([]interface{}{tenant}, args...)
+	rows, err := s.db.QueryContext(ctx, stmt, append(args, len(skills))...)
+	if err != nil {
+		logQueryError(ctx, "MatchingAgents", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errNoSkilledAgents
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return nil, errNoSkilledAgents
+	}
+
+	return s.Agents(ctx, tenant, ids)
+}
+
+func (s *sqliteStore) CreateAgent(ctx context.Context, tenant string, a agent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt := `INSERT INTO AGENTS (TENANT, ID, FIRSTNAME, LASTNAME) VALUES (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, stmt, tenant, a.ID, a.FirstName, a.LastName); err != nil {
+		logQueryError(ctx, "CreateAgent", err)
+		tx.Rollback()
+		return err
+	}
+	if err := insertSQLiteAgentSkills(ctx, tx, tenant, a.ID, a.Skills); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) UpdateAgentSkills(ctx context.Context, tenant, agentID string, skills []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM AGENTSKILLS WHERE TENANT = ? AND AGENT = ?`, tenant, agentID); err != nil {
+		logQueryError(ctx, "UpdateAgentSkills", err)
+		tx.Rollback()
+		return err
+	}
+	if err := insertSQLiteAgentSkills(ctx, tx, tenant, agentID, skills); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSQLiteAgentSkills(ctx context.Context, tx *sql.Tx, tenant, agentID string, skills []string) error {
+	stmt := `INSERT INTO AGENTSKILLS (TENANT, ID, SKILL, AGENT) VALUES (?, ?, ?, ?)`
+	for _, s := range skills {
+		if _, err := tx.ExecContext(ctx, stmt, tenant, xid.New().String(), s, agentID); err != nil {
+			logQueryError(ctx, "insertSQLiteAgentSkills", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) OpenTasks(ctx context.Context, tenant string, agentIDs []string) (map[string][]task, error) {
+	agentIn, agentArgs := inList(agentIDs)
+	statusIn, statusArgs := inList(openStatuses)
+	stmt := `
+	SELECT
+	TASKS.ID, TASKS.CREATEDATE, TASKS.NAME, PRIORITIES.PRIORITY_LEVEL, TASKS.AGENT
+	FROM TASKS
+	INNER JOIN PRIORITIES ON TASKS.TENANT = PRIORITIES.TENANT AND TASKS.PRIORITY = PRIORITIES.PRIORITY
+	WHERE
+		TASKS.TENANT = ?
+	AND
+		TASKS.AGENT IN ` + agentIn + `
+	AND
+		TASKS.STATUS IN ` + statusIn
+	args := append(append([]interface{}{tenant}, agentArgs...), statusArgs...)
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		logQueryError(ctx, "OpenTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+	at := map[string][]task{}
+	for rows.Next() {
+		var t task
+		if err := rows.Scan(&t.ID, &t.StartTime, &t.Name, &t.priorityLevel, &t.Agent); err != nil {
+			return nil, errors.New("no agents found")
+		}
+		at[t.Agent] = append(at[t.Agent], t)
+	}
+
+	return at, nil
+}
+
+func (s *sqliteStore) RecentAgent(ctx context.Context, tenant string, agentIDs []string, priorityLevel int) (string, error) {
+	agentIn, agentArgs := inList(agentIDs)
+	statusIn, statusArgs := inList(openStatuses)
+	stmt := `
+	SELECT
+	TASKS.AGENT
+	FROM TASKS
+	INNER JOIN PRIORITIES ON TASKS.TENANT = PRIORITIES.TENANT AND TASKS.PRIORITY = PRIORITIES.PRIORITY
+	WHERE
+		TASKS.TENANT = ?
+	AND
+		TASKS.AGENT IN ` + agentIn + `
+	AND
+		TASKS.STATUS IN ` + statusIn + `
+	AND
+		PRIORITIES.PRIORITY_LEVEL < ?
+	ORDER BY TASKS.CREATEDATE DESC
+	LIMIT 1
+	`
+	args := append(append([]interface{}{tenant}, agentArgs...), statusArgs...)
+	var agentID string
+	err := s.db.QueryRowContext(ctx, stmt, append(args, priorityLevel)...).Scan(&agentID)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", nil
+	case err != nil:
+		logQueryError(ctx, "RecentAgent", err)
+		return "", err
+	}
+	return agentID, nil
+}
+
+func (s *sqliteStore) AgentTasks(ctx context.Context, tenant string) ([]agentTasks, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT ID, FIRSTNAME, LASTNAME FROM AGENTS WHERE TENANT = ? ORDER BY ID`, tenant)
+	if err != nil {
+		logQueryError(ctx, "AgentTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var lats []agentTasks
+	index := map[string]int{}
+	for rows.Next() {
+		var a agent
+		if err := rows.Scan(&a.ID, &a.FirstName, &a.LastName); err != nil {
+			return nil, errors.New("unable to retrieve agents")
+		}
+		index[a.ID] = len(lats)
+		lats = append(lats, agentTasks{
+			agent: a,
+		})
+	}
+	rows.Close()
+
+	statusIn, statusArgs := inList(openStatuses)
+	stmt := `
+	SELECT
+	ID, NAME, AGENT, PRIORITY, ` + taskSkills + `, CREATEDATE, STATUS, COMPLETEDATE
+	FROM TASKS
+	WHERE
+		TENANT = ?
+	AND
+		STATUS IN ` + statusIn + `
+	ORDER BY CREATEDATE
+	`
+	rows, err = s.db.QueryContext(ctx, stmt, append([]interface{}{tenant}, statusArgs...)...)
+	if err != nil {
+		logQueryError(ctx, "AgentTasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanSQLiteTask(rows, nil, nil)
+		if err != nil {
+			logQueryError(ctx, "AgentTasks", err)
+			return nil, errors.New("unable to retrieve agent tasks")
+		}
+		if idx, has := index[t.Agent]; has {
+			lats[idx].Tasks = append(lats[idx].Tasks, t)
+		}
+	}
+	return lats, nil
+}
+
+func (s *sqliteStore) TasksByAgent(ctx context.Context, tenant, agentID string) ([]task, error) {
+	statusIn, statusArgs := inList(openStatuses)
+	stmt := `
+	SELECT
+	ID, NAME, AGENT, PRIORITY, ` + taskSkills + `, CREATEDATE, STATUS, COMPLETEDATE, ` + taskDetailColumns + `
+	FROM TASKS
+	WHERE
+		TENANT = ?
+	AND
+		AGENT = ?
+	AND
+		STATUS IN ` + statusIn + `
+	ORDER BY CREATEDATE
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, append([]interface{}{tenant, agentID}, statusArgs...)...)
+	if err != nil {
+		logQueryError(ctx, "TasksByAgent", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []task{}
+	for rows.Next() {
+		var details taskDetailsScanner
+		t, err := scanSQLiteTask(rows, nil, &details)
+		if err != nil {
+			logQueryError(ctx, "TasksByAgent", err)
+			return nil, errors.New("unable to retrieve agent tasks")
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *sqliteStore) Tasks(ctx context.Context, tenant string, filter taskFilter) ([]task, error) {
+	stmt := `
+	SELECT
+	ID, NAME, AGENT, PRIORITY, ` + taskSkills + `, CREATEDATE, STATUS, COMPLETEDATE, ` + taskResultColumns + `, ` + taskDetailColumns + `
+	FROM TASKS
+	WHERE
+		TENANT = ?
+	`
+	args := []interface{}{tenant}
+	if len(filter.Statuses) > 0 {
+		statusIn, statusArgs := inList(filter.Statuses)
+		stmt += " AND STATUS IN " + statusIn
+		args = append(args, statusArgs...)
+	}
+	if filter.Agent != "" {
+		stmt += " AND AGENT = ?"
+		args = append(args, filter.Agent)
+	}
+	// The dates are compared as julian days, as they are stored with the
+	// offset of the local time when they were written.
+	if !filter.ActiveTo.IsZero() {
+		stmt += " AND julianday(CREATEDATE) < julianday(?)"
+		args = append(args, filter.ActiveTo)
+	}
+	if !filter.ActiveFrom.IsZero() {
+		stmt += " AND (COMPLETEDATE IS NULL OR julianday(COMPLETEDATE) >= julianday(?))"
+		args = append(args, filter.ActiveFrom)
+	}
+	for _, tag := range filter.Tags {
+		stmt += " AND EXISTS (SELECT 1 FROM json_each(TASKS.TAGS) WHERE json_each.value = ?)"
+		args = append(args, tag)
+	}
+	for _, key := range sortedKeys(filter.Metadata) {
+		stmt += " AND json_extract(METADATA, ?) = ?"
+		args = append(args, `$."`+key+`"`, filter.Metadata[key])
+	}
+	stmt += " ORDER BY CREATEDATE DESC"
+	if filter.Limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		logQueryError(ctx, "Tasks", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []task{}
+	for rows.Next() {
+		var result taskResultScanner
+		var details taskDetailsScanner
+		t, err := scanSQLiteTask(rows, &result, &details)
+		if err != nil {
+			logQueryError(ctx, "Tasks", err)
+			return nil, errors.New("unable to retrieve tasks")
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *sqliteStore) Task(ctx context.Context, tenant, id string) (task, error) {
+	stmt := `
+	SELECT
+	ID, NAME, AGENT, PRIORITY, ` + taskSkills + `, CREATEDATE, STATUS, COMPLETEDATE, ` + taskResultColumns + `, ` + taskDetailColumns + `
+	FROM TASKS
+	WHERE
+		TENANT = ?
+	AND
+		ID = ?
+	`
+	var result taskResultScanner
+	var details taskDetailsScanner
+	t, err := scanSQLiteTask(s.db.QueryRowContext(ctx, stmt, tenant, id), &result, &details)
+	if err != nil {
+		logQueryError(ctx, "Task", err)
+		return task{}, fmt.Errorf("unable to find task %s", id)
+	}
+	return t, nil
+}
+
+// scanSQLiteTask scans a task selected with its skills and complete date, and
+// the result and details when they are not nil.
+func scanSQLiteTask(row interface{ Scan(...interface{}) error }, result *taskResultScanner, details *taskDetailsScanner) (task, error) {
+	var t task
+	var skills string
+	var date sql.NullTime
+	dest := []interface{}{&t.ID, &t.Name, &t.Agent, &t.Priorty, &skills, &t.StartTime, &t.Status, &date}
+	if result != nil {
+		dest = append(dest, result.dest()...)
+	}
+	if details != nil {
+		dest = append(dest, details.dest()...)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return task{}, err
+	}
+	var err error
+	if t.Skills, err = decodeSkills(skills); err != nil {
+		return task{}, err
+	}
+	if result != nil {
+		t.taskResult = result.taskResult()
+	}
+	if details != nil {
+		if t.taskDetails, err = details.details(); err != nil {
+			return task{}, err
+		}
+	}
+	if date.Valid {
+		t.CompleteTime = date.Time
+	}
+	return t, nil
+}
+
+func (s *sqliteStore) CreateTask(ctx context.Context, tenant string, t task) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO TASKS
+	(TENANT, ID, NAME, CREATEDATE, PRIORITY, STATUS, AGENT, DESCRIPTION, METADATA, TAGS, EXTERNALURL, SLA)
+	VALUES
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	description, metadata, tags, externalURL, sla := t.taskDetails.columns()
+	if _, err := tx.ExecContext(ctx, stmt, tenant, t.ID, t.Name, t.StartTime, t.Priorty, t.Status, t.Agent, description, metadata, tags, externalURL, sla); err != nil {
+		logQueryError(ctx, "CreateTask", err)
+		tx.Rollback()
+		return err
+	}
+	stmt = `INSERT OR IGNORE INTO TASKSKILLS (TENANT, TASK, SKILL) VALUES (?, ?, ?)`
+	for _, sk := range t.Skills {
+		if _, err := tx.ExecContext(ctx, stmt, tenant, t.ID, sk); err != nil {
+			logQueryError(ctx, "CreateTask", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) UpdateTaskStatus(ctx context.Context, tenant, id, status string, result taskResult) error {
+	outcome, note, resultData := result.columns()
+	stmt := `UPDATE TASKS SET STATUS = ?, COMPLETEDATE = ?, OUTCOME = ?, RESULT = ?, RESULTDATA = ? WHERE TENANT = ? AND ID = ?`
+	if _, err := s.db.ExecContext(ctx, stmt, status, time.Now(), outcome, note, resultData, tenant, id); err != nil {
+		logQueryError(ctx, "UpdateTaskStatus", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) TransitionTask(ctx context.Context, tenant, id, agentID string, from []string, to string, result taskResult) (bool, error) {
+	var completeDate sql.NullTime
+	if (taskTransition{to: to}).ends() {
+		completeDate = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	outcome, note, resultData := result.columns()
+
+	fromIn, fromArgs := inList(from)
+	stmt := `
+	UPDATE TASKS
+	SET STATUS = ?, COMPLETEDATE = ?, OUTCOME = ?, RESULT = ?, RESULTDATA = ?
+	WHERE
+		TENANT = ?
+	AND
+		ID = ?
+	AND
+		AGENT = ?
+	AND
+		STATUS IN ` + fromIn
+	args := append([]interface{}{to, completeDate, outcome, note, resultData, tenant, id, agentID}, fromArgs...)
+	res, err := s.db.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		logQueryError(ctx, "TransitionTask", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *sqliteStore) CreateComment(ctx context.Context, tenant string, c comment) error {
+	stmt := `
+	INSERT INTO TASKCOMMENTS
+		(TENANT, ID, TASK, AUTHOR, ROLE, AGENT, BODY, CREATEDATE)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	agentID := sql.NullString{String: c.Agent, Valid: c.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, c.ID, c.Task, c.Author, c.Role, agentID, c.Body, c.CreateTime); err != nil {
+		logQueryError(ctx, "CreateComment", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Comments(ctx context.Context, tenant, taskID string) ([]comment, error) {
+	stmt := `
+	SELECT ID, TASK, AUTHOR, ROLE, AGENT, BODY, CREATEDATE
+	FROM TASKCOMMENTS
+	WHERE TENANT = ? AND TASK = ?
+	ORDER BY CREATEDATE, ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, taskID)
+	if err != nil {
+		logQueryError(ctx, "Comments", err)
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []comment{}
+	for rows.Next() {
+		var c comment
+		var agentID sql.NullString
+		if err := rows.Scan(&c.ID, &c.Task, &c.Author, &c.Role, &agentID, &c.Body, &c.CreateTime); err != nil {
+			logQueryError(ctx, "Comments", err)
+			return nil, errors.New("unable to retrieve comments")
+		}
+		c.Agent = agentID.String
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+func (s *sqliteStore) CreateAttachment(ctx context.Context, tenant string, a attachment) error {
+	stmt := `
+	INSERT INTO TASKATTACHMENTS
+		(TENANT, ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, a.ID, a.Task, a.Name, a.ContentType, a.Size, a.SHA256, a.Author, a.CreateTime); err != nil {
+		logQueryError(ctx, "CreateAttachment", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Attachments(ctx context.Context, tenant, taskID string) ([]attachment, error) {
+	stmt := `
+	SELECT ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE
+	FROM TASKATTACHMENTS
+	WHERE TENANT = ? AND TASK = ?
+	ORDER BY CREATEDATE, ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, taskID)
+	if err != nil {
+		logQueryError(ctx, "Attachments", err)
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := []attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			logQueryError(ctx, "Attachments", err)
+			return nil, errors.New("unable to retrieve attachments")
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+func (s *sqliteStore) Attachment(ctx context.Context, tenant, taskID, id string) (attachment, error) {
+	stmt := `
+	SELECT ID, TASK, NAME, CONTENTTYPE, SIZE, SHA256, AUTHOR, CREATEDATE
+	FROM TASKATTACHMENTS
+	WHERE TENANT = ? AND TASK = ? AND ID = ?
+	`
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, stmt, tenant, taskID, id))
+	if err != nil {
+		logQueryError(ctx, "Attachment", err)
+		return attachment{}, fmt.Errorf("unable to find attachment %s", id)
+	}
+	return a, nil
+}
+
+func (s *sqliteStore) CreateSchedule(ctx context.Context, tenant string, sc schedule) error {
+	stmt := `
+	INSERT INTO SCHEDULES
+		(TENANT, ID, TASK, CRON, STARTAT, PAUSED, NEXTRUN, AUTHOR, CREATEDATE)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	taskJSON, err := json.Marshal(sc.Task)
+	if err != nil {
+		return err
+	}
+	cron := sql.NullString{String: sc.Cron, Valid: sc.Cron != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, sc.ID, string(taskJSON), cron, nullTime(sc.StartAt), sc.Paused, nullTime(sc.NextRun), sc.Author, sc.CreateTime); err != nil {
+		logQueryError(ctx, "CreateSchedule", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Schedules(ctx context.Context, tenant string) ([]schedule, error) {
+	stmt := `SELECT ` + scheduleColumns + ` FROM SCHEDULES WHERE TENANT = ? ORDER BY CREATEDATE, ID`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "Schedules", err)
+		return nil, err
+	}
+	defer rows.Close()
+	schedules := []schedule{}
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			logQueryError(ctx, "Schedules", err)
+			return nil, errors.New("unable to retrieve schedules")
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, nil
+}
+
+func (s *sqliteStore) Schedule(ctx context.Context, tenant, id string) (schedule, error) {
+	stmt := `SELECT ` + scheduleColumns + ` FROM SCHEDULES WHERE TENANT = ? AND ID = ?`
+	sc, err := scanSchedule(s.db.QueryRowContext(ctx, stmt, tenant, id))
+	if err != nil {
+		logQueryError(ctx, "Schedule", err)
+		return schedule{}, fmt.Errorf("unable to find schedule %s", id)
+	}
+	return sc, nil
+}
+
+func (s *sqliteStore) SetSchedulePaused(ctx context.Context, tenant, id string, paused bool, nextRun *time.Time) (bool, error) {
+	stmt := `UPDATE SCHEDULES SET PAUSED = ?, NEXTRUN = ? WHERE TENANT = ? AND ID = ?`
+	res, err := s.db.ExecContext(ctx, stmt, paused, nullTime(nextRun), tenant, id)
+	if err != nil {
+		logQueryError(ctx, "SetSchedulePaused", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *sqliteStore) DeleteSchedule(ctx context.Context, tenant, id string) (bool, error) {
+	stmt := `DELETE FROM SCHEDULES WHERE TENANT = ? AND ID = ?`
+	res, err := s.db.ExecContext(ctx, stmt, tenant, id)
+	if err != nil {
+		logQueryError(ctx, "DeleteSchedule", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *sqliteStore) DueSchedules(ctx context.Context, now time.Time, limit int) ([]schedule, error) {
+	stmt := `
+	SELECT TENANT, ` + scheduleColumns + `
+	FROM SCHEDULES
+	WHERE PAUSED = ? AND NEXTRUN IS NOT NULL AND NEXTRUN <= ?
+	ORDER BY NEXTRUN
+	LIMIT ?
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, false, now.UTC(), limit)
+	if err != nil {
+		logQueryError(ctx, "DueSchedules", err)
+		return nil, err
+	}
+	defer rows.Close()
+	schedules := []schedule{}
+	for rows.Next() {
+		var tenant string
+		sc, err := scanSchedule(rows, &tenant)
+		if err != nil {
+			logQueryError(ctx, "DueSchedules", err)
+			return nil, errors.New("unable to retrieve schedules")
+		}
+		sc.tenant = tenant
+		schedules = append(schedules, sc)
+	}
+	return schedules, nil
+}
+
+func (s *sqliteStore) ClaimSchedule(ctx context.Context, tenant, id string, due, until time.Time) (bool, error) {
+	stmt := `
+	UPDATE SCHEDULES
+	SET NEXTRUN = ?
+	WHERE TENANT = ? AND ID = ? AND NEXTRUN = ? AND PAUSED = ?
+	`
+	res, err := s.db.ExecContext(ctx, stmt, until.UTC(), tenant, id, due.UTC(), false)
+	if err != nil {
+		logQueryError(ctx, "ClaimSchedule", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *sqliteStore) RecordScheduleRun(ctx context.Context, tenant, id string, run scheduleRun, nextRun *time.Time) error {
+	stmt := `
+	UPDATE SCHEDULES
+	SET NEXTRUN = ?, LASTRUN = ?, LASTTASK = ?, LASTERROR = ?
+	WHERE TENANT = ? AND ID = ?
+	`
+	lastTask := sql.NullString{String: run.LastTask, Valid: run.LastTask != ""}
+	lastError := sql.NullString{String: run.LastError, Valid: run.LastError != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, nullTime(nextRun), nullTime(run.LastRun), lastTask, lastError, tenant, id); err != nil {
+		logQueryError(ctx, "RecordScheduleRun", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) CreateTemplate(ctx context.Context, tenant string, tp template) error {
+	stmt := `INSERT INTO TEMPLATES (TENANT, NAME, DEFAULTS, AUTHOR, CREATEDATE) VALUES (?, ?, ?, ?, ?)`
+	defaults, err := json.Marshal(tp.templateDefaults)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, tp.Name, string(defaults), tp.Author, tp.CreateTime); err != nil {
+		logQueryError(ctx, "CreateTemplate", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Templates(ctx context.Context, tenant string) ([]template, error) {
+	stmt := `SELECT ` + templateColumns + ` FROM TEMPLATES WHERE TENANT = ? ORDER BY NAME`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "Templates", err)
+		return nil, err
+	}
+	defer rows.Close()
+	templates := []template{}
+	for rows.Next() {
+		tp, err := scanTemplate(rows)
+		if err != nil {
+			logQueryError(ctx, "Templates", err)
+			return nil, errors.New("unable to retrieve templates")
+		}
+		templates = append(templates, tp)
+	}
+	return templates, nil
+}
+
+func (s *sqliteStore) Template(ctx context.Context, tenant, name string) (template, error) {
+	stmt := `SELECT ` + templateColumns + ` FROM TEMPLATES WHERE TENANT = ? AND NAME = ?`
+	tp, err := scanTemplate(s.db.QueryRowContext(ctx, stmt, tenant, name))
+	if err != nil {
+		logQueryError(ctx, "Template", err)
+		return template{}, fmt.Errorf("unable to find template %s", name)
+	}
+	return tp, nil
+}
+
+func (s *sqliteStore) DeleteTemplate(ctx context.Context, tenant, name string) (bool, error) {
+	stmt := `DELETE FROM TEMPLATES WHERE TENANT = ? AND NAME = ?`
+	res, err := s.db.ExecContext(ctx, stmt, tenant, name)
+	if err != nil {
+		logQueryError(ctx, "DeleteTemplate", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *sqliteStore) CreateEvent(ctx context.Context, tenant string, e event) error {
+	stmt := `
+	INSERT INTO EVENTS
+		(TENANT, TYPE, TASK, AGENT, STATUS, CREATEDATE)
+	VALUES
+		(?, ?, ?, ?, ?, ?)
+	`
+	agentID := sql.NullString{String: e.Agent, Valid: e.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, tenant, e.Type, e.Task, agentID, e.Status, e.CreateTime); err != nil {
+		logQueryError(ctx, "CreateEvent", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) Events(ctx context.Context, tenant string, after int64, limit int) ([]event, error) {
+	stmt := `
+	SELECT ID, TYPE, TASK, AGENT, STATUS, CREATEDATE
+	FROM EVENTS
+	WHERE TENANT = ? AND ID > ?
+	ORDER BY ID
+	LIMIT ?
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, after, limit)
+	if err != nil {
+		logQueryError(ctx, "Events", err)
+		return nil, err
+	}
+	defer rows.Close()
+	events := []event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve events")
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (s *sqliteStore) EventsBetween(ctx context.Context, tenant string, from, to time.Time) ([]event, error) {
+	// The dates are compared as julian days, as they are stored with the
+	// offset of the local time when they were written.
+	stmt := `
+	SELECT ID, TYPE, TASK, AGENT, STATUS, CREATEDATE
+	FROM EVENTS
+	WHERE TENANT = ? AND julianday(CREATEDATE) >= julianday(?) AND julianday(CREATEDATE) < julianday(?)
+	ORDER BY ID
+	`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant, from, to)
+	if err != nil {
+		logQueryError(ctx, "EventsBetween", err)
+		return nil, err
+	}
+	defer rows.Close()
+	events := []event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve events")
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (s *sqliteStore) CreateAPIKey(ctx context.Context, key apiKey) error {
+	stmt := `
+	INSERT INTO APIKEYS
+		(ID, TENANT, NAME, HASH, ROLE, AGENT, CREATEDATE)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?)
+	`
+	agentID := sql.NullString{String: key.Agent, Valid: key.Agent != ""}
+	if _, err := s.db.ExecContext(ctx, stmt, key.ID, key.Tenant, key.Name, key.hash, key.Role, agentID, key.CreateTime); err != nil {
+		logQueryError(ctx, "CreateAPIKey", err)
+		return err
+	}
+	return nil
+}
+
+func (s *sqliteStore) APIKeyByHash(ctx context.Context, hash string) (apiKey, error) {
+	stmt := `SELECT ID, TENANT, NAME, ROLE, AGENT, CREATEDATE, REVOKEDATE FROM APIKEYS WHERE HASH = ?`
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, stmt, hash))
+	if err != nil {
+		return apiKey{}, errors.New("unable to find API key")
+	}
+	return key, nil
+}
+
+func (s *sqliteStore) APIKeys(ctx context.Context, tenant string) ([]apiKey, error) {
+	stmt := `SELECT ID, TENANT, NAME, ROLE, AGENT, CREATEDATE, REVOKEDATE FROM APIKEYS WHERE TENANT = ? ORDER BY CREATEDATE`
+	rows, err := s.db.QueryContext(ctx, stmt, tenant)
+	if err != nil {
+		logQueryError(ctx, "APIKeys", err)
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []apiKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.New("unable to retrieve API keys")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *sqliteStore) RevokeAPIKey(ctx context.Context, tenant, id string) (bool, error) {
+	stmt := `UPDATE APIKEYS SET REVOKEDATE = ? WHERE TENANT = ? AND ID = ? AND REVOKEDATE IS NULL`
+	res, err := s.db.ExecContext(ctx, stmt, time.Now(), tenant, id)
+	if err != nil {
+		logQueryError(ctx, "RevokeAPIKey", err)
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
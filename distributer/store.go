@@ -0,0 +1,125 @@
+package distributer
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errNoSkilledAgents is returned by MatchingAgents when no agent has the
+// skills.
+var errNoSkilledAgents = errors.New("no agents have the skills")
+
+// Store is the storage of the tenants, agents, skills, priorities, tasks,
+// schedules, templates and API keys.  Other than the tenants, looking up an API key and
+// the due schedules, everything is scoped to a tenant.  A Store is opened
+// with OpenStore or NewPostgresStore.
+type Store interface {
+	Tenants(ctx context.Context) ([]tenant, error)
+	CreateTenant(ctx context.Context, t tenant) error
+
+	// SkillCount returns how many of the skills are present.
+	SkillCount(ctx context.Context, tenant string, skills []string) (int, error)
+	Skills(ctx context.Context, tenant string) ([]skill, error)
+	// SkillAgents returns how many agents have each skill, the skills no
+	// agent has are 0.
+	SkillAgents(ctx context.Context, tenant string) (map[string]int, error)
+	CreateSkill(ctx context.Context, tenant string, s skill) error
+
+	// PriorityLevel returns the level of the priority, an error is returned if
+	// the priority is not present.
+	PriorityLevel(ctx context.Context, tenant, priority string) (int, error)
+	Priorities(ctx context.Context, tenant string) ([]priority, error)
+	CreatePriority(ctx context.Context, tenant string, p priority) error
+
+	// Agents returns the agents with the ids, an error is returned if none of
+	// them are present.
+	Agents(ctx context.Context, tenant string, ids []string) ([]agent, error)
+	// MatchingAgents returns the agents that have all of the skills, or
+	// errNoSkilledAgents if none do.
+	MatchingAgents(ctx context.Context, tenant string, skills []string) ([]agent, error)
+	CreateAgent(ctx context.Context, tenant string, a agent) error
+	UpdateAgentSkills(ctx context.Context, tenant, agentID string, skills []string) error
+
+	// OpenTasks returns the tasks the agents are working on, by agent id, with
+	// the priority level of each task.
+	OpenTasks(ctx context.Context, tenant string, agentIDs []string) (map[string][]task, error)
+	// RecentAgent returns the agent, of the agents, with the most recent open
+	// task below the priority level.  An empty id is returned if there is none.
+	RecentAgent(ctx context.Context, tenant string, agentIDs []string, priorityLevel int) (string, error)
+	// AgentTasks returns every agent with the tasks they are working on.
+	AgentTasks(ctx context.Context, tenant string) ([]agentTasks, error)
+	// TasksByAgent returns the tasks the agent is working on.
+	TasksByAgent(ctx context.Context, tenant, agentID string) ([]task, error)
+	// Tasks returns the tasks selected by the filter, newest first.
+	Tasks(ctx context.Context, tenant string, filter taskFilter) ([]task, error)
+	Task(ctx context.Context, tenant, id string) (task, error)
+	CreateTask(ctx context.Context, tenant string, t task) error
+	// UpdateTaskStatus sets the status of the task with its result.
+	UpdateTaskStatus(ctx context.Context, tenant, id, status string, result taskResult) error
+	// TransitionTask moves an agent's task to a new status, with its result,
+	// only if the task is still in one of the from statuses.  False is
+	// returned if no task was updated.
+	TransitionTask(ctx context.Context, tenant, id, agentID string, from []string, to string, result taskResult) (bool, error)
+
+	CreateComment(ctx context.Context, tenant string, c comment) error
+	// Comments returns the comments of the task, oldest first.
+	Comments(ctx context.Context, tenant, taskID string) ([]comment, error)
+
+	CreateAttachment(ctx context.Context, tenant string, a attachment) error
+	// Attachments returns the attachments of the task, oldest first.
+	Attachments(ctx context.Context, tenant, taskID string) ([]attachment, error)
+	// Attachment returns the attachment of the task, an error is returned if
+	// it is not present.
+	Attachment(ctx context.Context, tenant, taskID, id string) (attachment, error)
+
+	CreateSchedule(ctx context.Context, tenant string, sc schedule) error
+	// Schedules returns the schedules, oldest first.
+	Schedules(ctx context.Context, tenant string) ([]schedule, error)
+	// Schedule returns the schedule, an error is returned if it is not
+	// present.
+	Schedule(ctx context.Context, tenant, id string) (schedule, error)
+	// SetSchedulePaused pauses or resumes the schedule with its next run,
+	// false is returned if it is not present.
+	SetSchedulePaused(ctx context.Context, tenant, id string, paused bool, nextRun *time.Time) (bool, error)
+	// DeleteSchedule deletes the schedule, false is returned if it is not
+	// present.
+	DeleteSchedule(ctx context.Context, tenant, id string) (bool, error)
+	// DueSchedules returns at most limit schedules, of any tenant, that are
+	// not paused with a next run at or before the time, the earliest first.
+	DueSchedules(ctx context.Context, now time.Time, limit int) ([]schedule, error)
+	// ClaimSchedule moves the next run of the schedule from the due time to
+	// the until time, only if it is still due at that time and not paused.
+	// False is returned if it was not claimed.
+	ClaimSchedule(ctx context.Context, tenant, id string, due, until time.Time) (bool, error)
+	// RecordScheduleRun sets the last run of the schedule and its next run,
+	// which is nil once it is done.
+	RecordScheduleRun(ctx context.Context, tenant, id string, run scheduleRun, nextRun *time.Time) error
+
+	CreateTemplate(ctx context.Context, tenant string, tp template) error
+	// Templates returns the templates, by name.
+	Templates(ctx context.Context, tenant string) ([]template, error)
+	// Template returns the template, an error is returned if it is not
+	// present.
+	Template(ctx context.Context, tenant, name string) (template, error)
+	// DeleteTemplate deletes the template, false is returned if it is not
+	// present.
+	DeleteTemplate(ctx context.Context, tenant, name string) (bool, error)
+
+	// CreateEvent records the event with an id after the ids of every event
+	// before it.
+	CreateEvent(ctx context.Context, tenant string, e event) error
+	// Events returns at most limit events with an id after the id, oldest
+	// first.
+	Events(ctx context.Context, tenant string, after int64, limit int) ([]event, error)
+	// EventsBetween returns the events recorded from the start until, but not
+	// including, the end, oldest first.
+	EventsBetween(ctx context.Context, tenant string, from, to time.Time) ([]event, error)
+
+	CreateAPIKey(ctx context.Context, key apiKey) error
+	// APIKeyByHash returns the key, of any tenant, with the hash.
+	APIKeyByHash(ctx context.Context, hash string) (apiKey, error)
+	APIKeys(ctx context.Context, tenant string) ([]apiKey, error)
+	// RevokeAPIKey revokes the key, false is returned if there is no active key.
+	RevokeAPIKey(ctx context.Context, tenant, id string) (bool, error)
+}
@@ -0,0 +1,430 @@
+package distributer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// payload from the create task HTTP request
+type payload struct {
+	Name    string   `json:"name"`
+	Skills  []string `json:"skills"`
+	Priorty string   `json:"priority"`
+	taskDetails
+	// StartAt holds the task until the time, when it is in the future.
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// taskDetails describe the work of a task, so it does not have to be looked
+// up elsewhere by the task id.
+type taskDetails struct {
+	Description string `json:"description,omitempty"`
+	// Metadata is any JSON object, like the ids of the work in other systems.
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	ExternalURL string          `json:"external_url,omitempty"`
+	// SLA is how long the task should take to complete from when it is
+	// distributed, like 4h.
+	SLA string `json:"sla,omitempty"`
+}
+
+// validate normalizes the details and adds their invalid fields.
+func (d *taskDetails) validate(v *validator) {
+	d.Description = v.optionalText("description", d.Description, maxTaskDescription)
+	d.Metadata = v.object("metadata", d.Metadata)
+	d.Tags = v.list("tags", d.Tags, false, maxTaskTags, maxTagLength)
+	if len(d.Tags) == 0 {
+		d.Tags = nil
+	}
+	d.ExternalURL = v.link("external_url", d.ExternalURL)
+	d.SLA = v.duration("sla", d.SLA, maxSLA)
+}
+
+// copy returns the details without sharing the tags or metadata.
+func (d taskDetails) copy() taskDetails {
+	d.Tags = append([]string(nil), d.Tags...)
+	d.Metadata = append(json.RawMessage(nil), d.Metadata...)
+	return d
+}
+
+// taskDetailsScanner scans the details selected by the SQL stores, with the
+// metadata as a JSON object and the tags as a JSON array.
+type taskDetailsScanner struct {
+	description sql.NullString
+	metadata    sql.NullString
+	tags        sql.NullString
+	externalURL sql.NullString
+	sla         sql.NullString
+}
+
+// columns returns the details as the nullable columns of the SQL stores, with
+// the tags as a JSON array.
+func (d taskDetails) columns() (description, metadata, tags, externalURL, sla sql.NullString) {
+	description = sql.NullString{String: d.Description, Valid: d.Description != ""}
+	metadata = sql.NullString{String: string(d.Metadata), Valid: len(d.Metadata) > 0}
+	if len(d.Tags) > 0 {
+		b, _ := json.Marshal(d.Tags)
+		tags = sql.NullString{String: string(b), Valid: true}
+	}
+	externalURL = sql.NullString{String: d.ExternalURL, Valid: d.ExternalURL != ""}
+	sla = sql.NullString{String: d.SLA, Valid: d.SLA != ""}
+	return description, metadata, tags, externalURL, sla
+}
+
+func (s *taskDetailsScanner) dest() []interface{} {
+	return []interface{}{&s.description, &s.metadata, &s.tags, &s.externalURL, &s.sla}
+}
+
+func (s *taskDetailsScanner) details() (taskDetails, error) {
+	d := taskDetails{
+		Description: s.description.String,
+		ExternalURL: s.externalURL.String,
+		SLA:         s.sla.String,
+	}
+	if s.metadata.Valid {
+		d.Metadata = json.RawMessage(s.metadata.String)
+	}
+	if s.tags.Valid {
+		if err := json.Unmarshal([]byte(s.tags.String), &d.Tags); err != nil {
+			return taskDetails{}, err
+		}
+	}
+	if len(d.Tags) == 0 {
+		d.Tags = nil
+	}
+	return d, nil
+}
+
+func createPayload(body io.ReadCloser) (*payload, error) {
+	var p payload
+	if err := decodePayload(body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// validate normalizes the payload, trimming the fields and dropping duplicate
+// skills, and returns every invalid field.  The error is only returned when
+// the skills or priorities can not be retrieved.
+func (p *payload) validate(ctx context.Context, store Store, tenant string) ([]fieldError, error) {
+	v := &validator{}
+	p.Name = v.text("name", p.Name, maxTaskNameLength)
+	p.Skills = v.skills("skills", p.Skills, true)
+	p.Priorty = v.text("priority", p.Priorty, maxPriorityLength)
+	p.taskDetails.validate(v)
+	if err := v.knownSkills(ctx, store, tenant, "skills", p.Skills); err != nil {
+		return nil, err
+	}
+	if !v.has("priority") {
+		if level, err := store.PriorityLevel(ctx, tenant, p.Priorty); err != nil || level == -1 {
+			v.add("priority", fieldUnsupported, "priority %s is not present", p.Priorty)
+		}
+	}
+	return v.invalid, nil
+}
+
+// errNoAgent is returned when no agent with the skills is free or working on
+// a lower priority task.
+var errNoAgent = errors.New("unable to find an agent to assign the task")
+
+// The statuses a task moves through once it has been distributed to an agent.
+// A task that is not complete can be cancelled instead.
+const (
+	statusAssigned  = "Assigned"
+	statusAccepted  = "Accepted"
+	statusStarted   = "Started"
+	statusComplete  = "Complete"
+	statusCancelled = "Cancelled"
+)
+
+// openStatuses are the statuses of a task that an agent is still working on.
+var openStatuses = []string{statusAssigned, statusAccepted, statusStarted}
+
+// task that is distributed to an agent
+type task struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Skills        []string `json:"skills"`
+	Priorty       string   `json:"priority"`
+	priorityLevel int
+	Status        string    `json:"status"`
+	StartTime     time.Time `json:"start_time"`
+	CompleteTime  time.Time `json:"complete_time,omitempty"`
+	Agent         string    `json:"assigned_agent"`
+	taskResult
+	taskDetails
+	// Comments and Attachments are only present in the response of a
+	// single task.
+	Comments    []comment    `json:"comments,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+	store       Store
+	tenant      string
+}
+
+// assignTask distributes the task to a free agent with the skills, or the agent
+// with the most recent lower priority task.  The context cancels the queries
+// when the request is canceled.
+func (t *task) assignTask(ctx context.Context, p payload) (err error) {
+	ctx, sp := startSpan(ctx, "assignTask", spanKindInternal)
+	defer func() {
+		sp.setAttributes("task.priority", p.Priorty, "task.agent", t.Agent)
+		sp.setError(err)
+		sp.finish()
+	}()
+	logger := ctxLogger(ctx)
+	skilledAgents, err := t.store.MatchingAgents(ctx, t.tenant, p.Skills)
+	if errors.Is(err, errNoSkilledAgents) {
+		logger.Debug("no agent available", "reason", "no agent has the skills")
+		return errNoAgent
+	}
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(skilledAgents))
+	for idx, a := range skilledAgents {
+		ids[idx] = a.ID
+	}
+	ats, err := t.store.OpenTasks(ctx, t.tenant, ids)
+	if err != nil {
+		return err
+	}
+	level, err := t.store.PriorityLevel(ctx, t.tenant, p.Priorty)
+	if err != nil {
+		return err
+	}
+	if level < 0 {
+		return errNoAgent
+	}
+	logger.Debug("assigning task", "skills", p.Skills, "priority", p.Priorty, "priority_level", level, "candidates", ids)
+
+	for _, skilledAgent := range skilledAgents {
+		if tsks, has := ats[skilledAgent.ID]; has {
+			for _, tsk := range tsks {
+				if tsk.priorityLevel >= level {
+					logger.Debug("skipping busy agent", "agent", skilledAgent.ID, "task", tsk.ID, "task_priority_level", tsk.priorityLevel)
+					delete(ats, skilledAgent.ID)
+				}
+			}
+		} else {
+			logger.Debug("assigning to free agent", "agent", skilledAgent.ID, "reason", "no open tasks")
+			return t.insert(ctx, p, skilledAgent.ID)
+		}
+	}
+
+	if len(ats) == 0 {
+		logger.Debug("no agent available", "reason", "every candidate has an open task of the same or a higher priority")
+		return errNoAgent
+	}
+	var available []string
+	for id := range ats {
+		available = append(available, id)
+	}
+	id, err := t.store.RecentAgent(ctx, t.tenant, available, level)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		logger.Debug("no agent available", "reason", "no open task of a lower priority", "preemptible", available)
+		return errNoAgent
+	}
+	logger.Debug("assigning to busy agent", "agent", id, "reason", "most recent lower priority task", "preemptible", available)
+	return t.insert(ctx, p, id)
+}
+func (t *task) insert(ctx context.Context, ctp payload, agentID string) error {
+
+	t.ID = xid.New().String()
+	t.Name = ctp.Name
+	t.Priorty = ctp.Priorty
+	t.Skills = ctp.Skills
+	t.taskDetails = ctp.taskDetails
+	t.Agent = agentID
+	t.StartTime = time.Now()
+	t.Status = statusAssigned
+
+	return t.store.CreateTask(ctx, t.tenant, *t)
+}
+
+func (t *task) retrieve(ctx context.Context, id string) error {
+	tsk, err := t.store.Task(ctx, t.tenant, id)
+	if err != nil {
+		return err
+	}
+
+	t.ID = tsk.ID
+	t.Name = tsk.Name
+	t.Agent = tsk.Agent
+	t.Priorty = tsk.Priorty
+	t.StartTime = tsk.StartTime
+	t.Status = tsk.Status
+	t.Skills = tsk.Skills
+	t.CompleteTime = tsk.CompleteTime
+	t.taskResult = tsk.taskResult
+	t.taskDetails = tsk.taskDetails
+
+	return nil
+}
+
+// taskTransition moves a task to a status from one of the allowed statuses.
+type taskTransition struct {
+	from []string
+	to   string
+}
+
+// agentActions are the transitions an agent can make on their own tasks.
+var agentActions = map[string]taskTransition{
+	"accept":   {from: []string{statusAssigned}, to: statusAccepted},
+	"start":    {from: []string{statusAssigned, statusAccepted}, to: statusStarted},
+	"complete": completeTransition,
+}
+
+// completeTransition completes a task that is not complete or cancelled yet.
+var completeTransition = taskTransition{from: openStatuses, to: statusComplete}
+
+// cancelTransition cancels a task that is not complete yet.
+var cancelTransition = taskTransition{from: openStatuses, to: statusCancelled}
+
+// ends reports whether the transition completes or cancels the task, which
+// records when it ended.
+func (tt taskTransition) ends() bool {
+	return tt.to == statusComplete || tt.to == statusCancelled
+}
+
+func (tt taskTransition) allowed(status string) bool {
+	for _, s := range tt.from {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// taskFilter selects the tasks to list, an empty field selects every task.
+type taskFilter struct {
+	Statuses []string
+	Agent    string
+	// Tags selects the tasks with all of the tags.
+	Tags []string
+	// Metadata selects the tasks whose metadata has each key with the string
+	// value.
+	Metadata map[string]string
+	// ActiveFrom and ActiveTo select the tasks an agent had at some time
+	// between them, assigned before ActiveTo and not completed or cancelled
+	// before ActiveFrom.  A zero time does not limit the tasks.
+	ActiveFrom time.Time
+	ActiveTo   time.Time
+	Limit      int
+}
+
+// matches reports whether the task is selected by the filter, other than the
+// limit.
+func (f taskFilter) matches(t task) bool {
+	if f.Agent != "" && t.Agent != f.Agent {
+		return false
+	}
+	if !f.ActiveTo.IsZero() && !t.StartTime.Before(f.ActiveTo) {
+		return false
+	}
+	if !f.ActiveFrom.IsZero() && !t.CompleteTime.IsZero() && t.CompleteTime.Before(f.ActiveFrom) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(t.Tags, tag) {
+			return false
+		}
+	}
+	if len(f.Metadata) > 0 {
+		var metadata map[string]interface{}
+		json.Unmarshal(t.Metadata, &metadata)
+		for key, value := range f.Metadata {
+			if v, ok := metadata[key].(string); !ok || v != value {
+				return false
+			}
+		}
+	}
+	return len(f.Statuses) == 0 || (taskTransition{from: f.Statuses}).allowed(t.Status)
+}
+
+// taskResult is what was done for a task, reported when it is completed.
+type taskResult struct {
+	// Outcome is a code of how the task ended, like resolved or escalated.
+	Outcome string `json:"outcome,omitempty"`
+	// Result is the notes of what was done.
+	Result string `json:"result,omitempty"`
+	// ResultData is any JSON object, like the ids of the work in other
+	// systems.
+	ResultData json.RawMessage `json:"result_data,omitempty"`
+}
+
+// copy returns the result without sharing the result data.
+func (r taskResult) copy() taskResult {
+	r.ResultData = append(json.RawMessage(nil), r.ResultData...)
+	return r
+}
+
+// columns returns the result as the nullable columns of the SQL stores.
+func (r taskResult) columns() (outcome, result, resultData sql.NullString) {
+	outcome = sql.NullString{String: r.Outcome, Valid: r.Outcome != ""}
+	result = sql.NullString{String: r.Result, Valid: r.Result != ""}
+	resultData = sql.NullString{String: string(r.ResultData), Valid: len(r.ResultData) > 0}
+	return outcome, result, resultData
+}
+
+// taskResultColumns selects the result of the task in the SQL stores, scanned
+// by a taskResultScanner.
+const taskResultColumns = `RESULT, OUTCOME, RESULTDATA`
+
+// taskResultScanner scans the result selected by the SQL stores.
+type taskResultScanner struct {
+	result     sql.NullString
+	outcome    sql.NullString
+	resultData sql.NullString
+}
+
+func (s *taskResultScanner) dest() []interface{} {
+	return []interface{}{&s.result, &s.outcome, &s.resultData}
+}
+
+func (s *taskResultScanner) taskResult() taskResult {
+	r := taskResult{
+		Outcome: s.outcome.String,
+		Result:  s.result.String,
+	}
+	if s.resultData.Valid {
+		r.ResultData = json.RawMessage(s.resultData.String)
+	}
+	return r
+}
+
+// completePayload is the optional body when a task is completed.
+type completePayload struct {
+	taskResult
+}
+
+// validate normalizes the result and returns its invalid fields.
+func (p *completePayload) validate() []fieldError {
+	v := &validator{}
+	p.Outcome = v.code("outcome", p.Outcome, maxOutcomeLength)
+	p.Result = v.optionalText("result", p.Result, maxResultLength)
+	p.ResultData = v.object("result_data", p.ResultData)
+	return v.invalid
+}
+
+func createCompletePayload(body io.ReadCloser) (*completePayload, error) {
+	var p completePayload
+	err := decodePayload(body, &p)
+	switch {
+	case err == io.EOF:
+		return &p, nil
+	case err != nil:
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,724 @@
+package distributer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_createPayload(t *testing.T) {
+	type args struct {
+		body io.ReadCloser
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *payload
+		wantErr bool
+	}{
+		{
+			name: "Create payload",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{
+					"name": "Test Name",
+					"skills": ["skill1"],
+					"priority": "low"
+				}`)),
+			},
+			want: &payload{
+				Name: "Test Name",
+				Skills: []string{
+					"skill1",
+				},
+				Priorty: "low",
+			},
+			wantErr: false,
+		},
+		{
+			name: "JSON Error",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{
+					"name": "Test Name",
+					"skills": ["skill1"],
+					"priority": "low",
+				}`)),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Unknown field",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{"name": "Test Name", "skills": ["skill1"], "priority": "low", "priorty": "high"}`)),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Two values",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{"name": "Test Name"} {"name": "Other Name"}`)),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createPayload(tt.args.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("createPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_payload_validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     payload
+		want        payload
+		wantInvalid []string
+	}{
+		{
+			name:    "Valid",
+			payload: payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low"},
+			want:    payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low"},
+		},
+		{
+			name:    "Normalized",
+			payload: payload{Name: "  Test Name\n", Skills: []string{" skill1", "skill2", "skill1 "}, Priorty: "low "},
+			want:    payload{Name: "Test Name", Skills: []string{"skill1", "skill2"}, Priorty: "low"},
+		},
+		{
+			name:        "No Name",
+			payload:     payload{Name: " \t", Skills: []string{"skill1"}, Priorty: "low"},
+			wantInvalid: []string{"name required"},
+		},
+		{
+			name:        "No Skills",
+			payload:     payload{Name: "Test Name", Priorty: "low"},
+			wantInvalid: []string{"skills required"},
+		},
+		{
+			name:        "No Priority",
+			payload:     payload{Name: "Test Name", Skills: []string{"skill1"}},
+			wantInvalid: []string{"priority required"},
+		},
+		{
+			name:        "Every invalid field",
+			payload:     payload{Name: strings.Repeat("n", maxTaskNameLength+1), Skills: []string{"skill1", "", "skill9"}, Priorty: "zzz"},
+			wantInvalid: []string{"name too_long", "skills[1] required", "priority unsupported"},
+		},
+		{
+			name:        "Unknown skills",
+			payload:     payload{Name: "Test Name", Skills: []string{"skill1", "skill8", "skill9"}, Priorty: "low"},
+			wantInvalid: []string{"skills unsupported", "skills unsupported"},
+		},
+		{
+			name:        "Too many skills",
+			payload:     payload{Name: "Test Name", Skills: strings.Split("abcdefghijklmnopqrstuvwxyz", ""), Priorty: "low"},
+			wantInvalid: []string{"skills too_long"},
+		},
+		{
+			name: "Details",
+			payload: payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low", taskDetails: taskDetails{
+				Description: " Test Description ", Metadata: json.RawMessage(`{ "order": 12 }`), Tags: []string{"billing ", "billing", "vip"}, ExternalURL: "https://example.com/tickets/12", SLA: "90m",
+			}},
+			want: payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low", taskDetails: taskDetails{
+				Description: "Test Description", Metadata: json.RawMessage(`{"order":12}`), Tags: []string{"billing", "vip"}, ExternalURL: "https://example.com/tickets/12", SLA: "1h30m0s",
+			}},
+		},
+		{
+			name: "Invalid details",
+			payload: payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`["order"]`), Tags: strings.Split("abcdefghijklmnopqrstuvwxyz", ""), ExternalURL: "ftp://example.com", SLA: "-4h",
+			}},
+			wantInvalid: []string{"metadata invalid", "tags too_long", "external_url invalid", "sla invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payload
+			invalid, err := p.validate(context.Background(), newTestStore(t), DefaultTenant)
+			if err != nil {
+				t.Fatalf("payload.validate() error = %v", err)
+			}
+			var got []string
+			for _, fe := range invalid {
+				got = append(got, fe.Field+" "+fe.Code)
+			}
+			if !reflect.DeepEqual(got, tt.wantInvalid) {
+				t.Errorf("payload.validate() = %v, want %v", invalid, tt.wantInvalid)
+			}
+			if tt.wantInvalid == nil && !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("payload.validate() payload = %v, want %v", p, tt.want)
+			}
+		})
+	}
+}
+
+func Test_taskTransition_allowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		status string
+		want   bool
+	}{
+		{
+			name:   "Accept assigned",
+			action: "accept",
+			status: statusAssigned,
+			want:   true,
+		},
+		{
+			name:   "Accept started",
+			action: "accept",
+			status: statusStarted,
+			want:   false,
+		},
+		{
+			name:   "Start accepted",
+			action: "start",
+			status: statusAccepted,
+			want:   true,
+		},
+		{
+			name:   "Complete started",
+			action: "complete",
+			status: statusStarted,
+			want:   true,
+		},
+		{
+			name:   "Complete completed",
+			action: "complete",
+			status: statusComplete,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := agentActions[tt.action].allowed(tt.status); got != tt.want {
+				t.Errorf("taskTransition.allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_createCompletePayload(t *testing.T) {
+	type args struct {
+		body io.ReadCloser
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *completePayload
+		wantErr bool
+	}{
+		{
+			name: "Result note",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{"result": "Called the customer back"}`)),
+			},
+			want: &completePayload{
+				taskResult: taskResult{Result: "Called the customer back"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Outcome and result data",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{"outcome": "resolved", "result": "Refunded", "result_data": {"refund": 12}}`)),
+			},
+			want: &completePayload{
+				taskResult: taskResult{Outcome: "resolved", Result: "Refunded", ResultData: json.RawMessage(`{"refund": 12}`)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Empty body",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader("")),
+			},
+			want:    &completePayload{},
+			wantErr: false,
+		},
+		{
+			name: "JSON Error",
+			args: args{
+				body: ioutil.NopCloser(strings.NewReader(`{"result": }`)),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createCompletePayload(tt.args.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("createCompletePayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createCompletePayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_completePayload_validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     completePayload
+		want        completePayload
+		wantInvalid []string
+	}{
+		{
+			name:    "Empty",
+			payload: completePayload{},
+			want:    completePayload{},
+		},
+		{
+			name:    "Normalized",
+			payload: completePayload{taskResult{Outcome: " resolved ", Result: "Refunded\n", ResultData: json.RawMessage(`{ "refund": 12 }`)}},
+			want:    completePayload{taskResult{Outcome: "resolved", Result: "Refunded", ResultData: json.RawMessage(`{"refund":12}`)}},
+		},
+		{
+			name:        "Every invalid field",
+			payload:     completePayload{taskResult{Outcome: "not resolved", Result: strings.Repeat("r", maxResultLength+1), ResultData: json.RawMessage(`12`)}},
+			wantInvalid: []string{"outcome invalid", "result too_long", "result_data invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payload
+			var got []string
+			for _, fe := range p.validate() {
+				got = append(got, fe.Field+" "+fe.Code)
+			}
+			if !reflect.DeepEqual(got, tt.wantInvalid) {
+				t.Errorf("completePayload.validate() = %v, want %v", got, tt.wantInvalid)
+			}
+			if tt.wantInvalid == nil && !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("completePayload.validate() payload = %v, want %v", p, tt.want)
+			}
+		})
+	}
+}
+
+// newTestStore returns a memory store with the seed data.
+func newTestStore(t *testing.T) *memoryStore {
+	s := newMemoryStore()
+	if err := s.seed(); err != nil {
+		t.Fatalf("memoryStore.seed() error = %v", err)
+	}
+	return s
+}
+
+// testStores are the stores the distribution is tested against.
+var testStores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{name: "memory", open: func(t *testing.T) Store { return newTestStore(t) }},
+	{name: "sqlite", open: newTestSQLiteStore},
+}
+
+// newTestSQLiteStore returns a migrated and seeded SQLite store in memory.
+func newTestSQLiteStore(t *testing.T) Store {
+	s, err := OpenStore(context.Background(), "sqlite://:memory:")
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	t.Cleanup(func() {
+		s.(*sqliteStore).db.Close()
+	})
+	return s
+}
+
+func Test_task_assignTask(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		existing  []task
+		payload   payload
+		wantAgent string
+		wantErr   bool
+	}{
+		{
+			name: "Free agent",
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "low",
+			},
+			wantAgent: "1000",
+			wantErr:   false,
+		},
+		{
+			name: "Agent with all skills",
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1", "skill3"},
+				Priorty: "low",
+			},
+			wantAgent: "1003",
+			wantErr:   false,
+		},
+		{
+			name: "Next free agent",
+			existing: []task{
+				{ID: "a", Agent: "1000", Priorty: "low", Status: statusAssigned, StartTime: now},
+			},
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "low",
+			},
+			wantAgent: "1003",
+			wantErr:   false,
+		},
+		{
+			name: "Completed tasks are not counted",
+			existing: []task{
+				{ID: "a", Agent: "1000", Priorty: "high", Status: statusComplete, StartTime: now},
+			},
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "high",
+			},
+			wantAgent: "1000",
+			wantErr:   false,
+		},
+		{
+			name: "Most recent lower priority",
+			existing: []task{
+				{ID: "a", Agent: "1000", Priorty: "low", Status: statusAssigned, StartTime: now.Add(-time.Hour)},
+				{ID: "b", Agent: "1003", Priorty: "low", Status: statusStarted, StartTime: now},
+			},
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "high",
+			},
+			wantAgent: "1003",
+			wantErr:   false,
+		},
+		{
+			name: "Skip agents with equal priority",
+			existing: []task{
+				{ID: "a", Agent: "1000", Priorty: "low", Status: statusAssigned, StartTime: now.Add(-time.Hour)},
+				{ID: "b", Agent: "1003", Priorty: "high", Status: statusAccepted, StartTime: now},
+			},
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "high",
+			},
+			wantAgent: "1000",
+			wantErr:   false,
+		},
+		{
+			name: "All agents busy",
+			existing: []task{
+				{ID: "a", Agent: "1000", Priorty: "low", Status: statusAssigned, StartTime: now},
+				{ID: "b", Agent: "1003", Priorty: "low", Status: statusAssigned, StartTime: now},
+			},
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1"},
+				Priorty: "low",
+			},
+			wantErr: true,
+		},
+		{
+			name: "No agent with the skills",
+			payload: payload{
+				Name:    "Test Name",
+				Skills:  []string{"skill1", "skill2"},
+				Priorty: "low",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, ts := range testStores {
+			t.Run(tt.name+"/"+ts.name, func(t *testing.T) {
+				s := ts.open(t)
+				for _, e := range tt.existing {
+					if err := s.CreateTask(context.Background(), DefaultTenant, e); err != nil {
+						t.Fatalf("Store.CreateTask() error = %v", err)
+					}
+				}
+				tsk := &task{
+					store:  s,
+					tenant: DefaultTenant,
+				}
+				err := tsk.assignTask(context.Background(), tt.payload)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("task.assignTask() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if tsk.Agent != tt.wantAgent {
+					t.Errorf("task.assignTask() agent = %v, want %v", tsk.Agent, tt.wantAgent)
+				}
+				if tt.wantErr {
+					return
+				}
+				stored, err := s.Task(context.Background(), DefaultTenant, tsk.ID)
+				if err != nil {
+					t.Errorf("task.assignTask() task was not stored %v", err)
+					return
+				}
+				if stored.Status != statusAssigned || stored.Agent != tt.wantAgent || !reflect.DeepEqual(stored.Skills, tt.payload.Skills) {
+					t.Errorf("task.assignTask() stored = %v", stored)
+				}
+			})
+		}
+	}
+}
+
+func Test_Store_Tasks(t *testing.T) {
+	now := time.Now()
+	existing := []task{
+		{ID: "a", Name: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusComplete, StartTime: now.Add(-2 * time.Hour)},
+		{ID: "b", Name: "b", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: now.Add(-time.Hour)},
+		{ID: "c", Name: "c", Agent: "1003", Priorty: "high", Skills: []string{"skill3"}, Status: statusStarted, StartTime: now, taskDetails: taskDetails{
+			Description: "Test Description", Metadata: json.RawMessage(`{"customer":"acme","order":12}`), Tags: []string{"billing", "vip"}, ExternalURL: "https://example.com/tickets/12",
+		}},
+		{ID: "d", Name: "d", Agent: "1003", Priorty: "low", Skills: []string{"skill3"}, Status: statusCancelled, StartTime: now.Add(-3 * time.Hour)},
+	}
+	existing[1].Tags = []string{"billing"}
+	tests := []struct {
+		name   string
+		filter taskFilter
+		want   []string
+	}{
+		{
+			name: "Every task",
+			want: []string{"c", "b", "a", "d"},
+		},
+		{
+			name:   "Open statuses",
+			filter: taskFilter{Statuses: openStatuses},
+			want:   []string{"c", "b"},
+		},
+		{
+			name:   "Agent",
+			filter: taskFilter{Agent: "1000"},
+			want:   []string{"b", "a"},
+		},
+		{
+			name:   "Limit",
+			filter: taskFilter{Limit: 1},
+			want:   []string{"c"},
+		},
+		{
+			name:   "Tag",
+			filter: taskFilter{Tags: []string{"billing"}},
+			want:   []string{"c", "b"},
+		},
+		{
+			name:   "Every tag",
+			filter: taskFilter{Tags: []string{"billing", "vip"}},
+			want:   []string{"c"},
+		},
+		{
+			name:   "Metadata",
+			filter: taskFilter{Metadata: map[string]string{"customer": "acme"}},
+			want:   []string{"c"},
+		},
+		{
+			name:   "Other metadata",
+			filter: taskFilter{Metadata: map[string]string{"customer": "other"}},
+		},
+		{
+			name:   "Active to",
+			filter: taskFilter{ActiveTo: now.Add(-30 * time.Minute)},
+			want:   []string{"b", "a", "d"},
+		},
+		{
+			name:   "Active from",
+			filter: taskFilter{ActiveFrom: now.Add(time.Hour)},
+			want:   []string{"c", "b"},
+		},
+	}
+	for _, tt := range tests {
+		for _, ts := range testStores {
+			t.Run(tt.name+"/"+ts.name, func(t *testing.T) {
+				s := ts.open(t)
+				for _, e := range existing {
+					created := e
+					if e.Status == statusCancelled {
+						created.Status = statusAssigned
+					}
+					if err := s.CreateTask(context.Background(), DefaultTenant, created); err != nil {
+						t.Fatalf("Store.CreateTask() error = %v", err)
+					}
+					switch e.Status {
+					case statusComplete:
+						if err := s.UpdateTaskStatus(context.Background(), DefaultTenant, e.ID, e.Status, taskResult{}); err != nil {
+							t.Fatalf("Store.UpdateTaskStatus() error = %v", err)
+						}
+					case statusCancelled:
+						if updated, err := s.TransitionTask(context.Background(), DefaultTenant, e.ID, e.Agent, cancelTransition.from, cancelTransition.to, taskResult{}); err != nil || !updated {
+							t.Fatalf("Store.TransitionTask() = %v, %v, want cancelled", updated, err)
+						}
+					}
+				}
+				tasks, err := s.Tasks(context.Background(), DefaultTenant, tt.filter)
+				if err != nil {
+					t.Fatalf("Store.Tasks() error = %v", err)
+				}
+				var got []string
+				for _, tsk := range tasks {
+					got = append(got, tsk.ID)
+					if tsk.Status == statusCancelled && tsk.CompleteTime.IsZero() {
+						t.Errorf("Store.Tasks() %s complete time is zero, want when it was cancelled", tsk.ID)
+					}
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Store.Tasks() = %v, want %v", got, tt.want)
+				}
+				if len(tasks) > 0 && tasks[0].ID == "c" && !reflect.DeepEqual(tasks[0].taskDetails, existing[2].taskDetails) {
+					t.Errorf("Store.Tasks() details = %+v, want %+v", tasks[0].taskDetails, existing[2].taskDetails)
+				}
+			})
+		}
+	}
+}
+
+func Test_Store_Events(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			tsk := task{ID: "a", Name: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: time.Now()}
+			if err := s.CreateTask(context.Background(), DefaultTenant, tsk); err != nil {
+				t.Fatalf("Store.CreateTask() error = %v", err)
+			}
+			for _, status := range []string{statusAssigned, statusAccepted, statusComplete} {
+				e := event{Type: statusEvents[status], Task: tsk.ID, Agent: tsk.Agent, Status: status, CreateTime: time.Now()}
+				if err := s.CreateEvent(context.Background(), DefaultTenant, e); err != nil {
+					t.Fatalf("Store.CreateEvent() error = %v", err)
+				}
+			}
+			events, err := s.Events(context.Background(), DefaultTenant, 0, 2)
+			if err != nil || len(events) != 2 || events[0].Type != eventTaskAssigned || events[0].ID >= events[1].ID {
+				t.Fatalf("Store.Events() = %v, %v, want the first 2 events", events, err)
+			}
+			after, err := s.Events(context.Background(), DefaultTenant, events[1].ID, 10)
+			if err != nil || len(after) != 1 || after[0].Type != eventTaskCompleted {
+				t.Errorf("Store.Events() after = %v, %v, want the completed event", after, err)
+			}
+			other, err := s.Events(context.Background(), "other", 0, 10)
+			if err != nil || len(other) != 0 {
+				t.Errorf("Store.Events() of another tenant = %v, %v, want none", other, err)
+			}
+		})
+	}
+}
+
+func Test_Store_Comments(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			now := time.Now()
+			for _, id := range []string{"a", "b"} {
+				tsk := task{ID: id, Name: id, Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: now}
+				if err := s.CreateTask(context.Background(), DefaultTenant, tsk); err != nil {
+					t.Fatalf("Store.CreateTask() error = %v", err)
+				}
+			}
+			comments := []comment{
+				{ID: "1", Task: "a", Author: "key1", Role: RoleSubmitter, Body: "Please call back", CreateTime: now},
+				{ID: "2", Task: "b", Author: "key1", Role: RoleSubmitter, Body: "Other task", CreateTime: now},
+				{ID: "3", Task: "a", Author: "key2", Role: RoleAgent, Agent: "1000", Body: "Called back", CreateTime: now.Add(time.Minute)},
+			}
+			for _, c := range comments {
+				if err := s.CreateComment(context.Background(), DefaultTenant, c); err != nil {
+					t.Fatalf("Store.CreateComment() error = %v", err)
+				}
+			}
+			got, err := s.Comments(context.Background(), DefaultTenant, "a")
+			if err != nil || len(got) != 2 || got[0].Body != "Please call back" || got[1].Agent != "1000" || got[1].Role != RoleAgent {
+				t.Errorf("Store.Comments() = %v, %v, want the task's comments oldest first", got, err)
+			}
+			if err := s.CreateComment(context.Background(), DefaultTenant, comment{ID: "4", Task: "unknown", Author: "key1", Role: RoleSubmitter, Body: "Lost", CreateTime: now}); err == nil {
+				t.Errorf("Store.CreateComment() of an unknown task error = nil, want an error")
+			}
+			ok, err := s.TransitionTask(context.Background(), DefaultTenant, "a", "1000", openStatuses, statusComplete, taskResult{Outcome: "resolved", Result: "Called back", ResultData: json.RawMessage(`{"calls":1}`)})
+			if err != nil || !ok {
+				t.Fatalf("Store.TransitionTask() = %v, %v, want true", ok, err)
+			}
+			completed, err := s.Task(context.Background(), DefaultTenant, "a")
+			if err != nil || completed.Outcome != "resolved" || completed.Result != "Called back" || string(completed.ResultData) != `{"calls":1}` {
+				t.Errorf("Store.Task() = %+v, %v, want the result", completed, err)
+			}
+		})
+	}
+}
+
+func Test_Store_Attachments(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			now := time.Now().UTC().Truncate(time.Second)
+			tsk := task{ID: "a", Name: "a", Agent: "1000", Priorty: "low", Skills: []string{"skill1"}, Status: statusAssigned, StartTime: now}
+			if err := s.CreateTask(context.Background(), DefaultTenant, tsk); err != nil {
+				t.Fatalf("Store.CreateTask() error = %v", err)
+			}
+			attachments := []attachment{
+				{ID: "1", Task: "a", Name: "scan.png", ContentType: "image/png", Size: 10, SHA256: strings.Repeat("a", 64), Author: "key1", CreateTime: now},
+				{ID: "2", Task: "a", Name: "notes.txt", ContentType: "text/plain", Size: 5, SHA256: strings.Repeat("b", 64), Author: "key2", CreateTime: now.Add(time.Minute)},
+			}
+			for _, a := range attachments {
+				if err := s.CreateAttachment(context.Background(), DefaultTenant, a); err != nil {
+					t.Fatalf("Store.CreateAttachment() error = %v", err)
+				}
+			}
+			got, err := s.Attachments(context.Background(), DefaultTenant, "a")
+			if err != nil || len(got) != 2 || got[0].Name != "scan.png" || got[1].Size != 5 {
+				t.Errorf("Store.Attachments() = %v, %v, want the task's attachments oldest first", got, err)
+			}
+			a, err := s.Attachment(context.Background(), DefaultTenant, "a", "2")
+			if err != nil || a.SHA256 != attachments[1].SHA256 || a.ContentType != "text/plain" || !a.CreateTime.Equal(attachments[1].CreateTime) {
+				t.Errorf("Store.Attachment() = %+v, %v, want %+v", a, err, attachments[1])
+			}
+			if _, err := s.Attachment(context.Background(), "other", "a", "2"); err == nil {
+				t.Errorf("Store.Attachment() of another tenant error = nil, want an error")
+			}
+			if err := s.CreateAttachment(context.Background(), DefaultTenant, attachment{ID: "3", Task: "unknown", Name: "lost.txt", CreateTime: now}); err == nil {
+				t.Errorf("Store.CreateAttachment() of an unknown task error = nil, want an error")
+			}
+		})
+	}
+}
+
+func Test_task_assignTask_canceled(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tsk := &task{
+		store:  s,
+		tenant: DefaultTenant,
+	}
+	err := tsk.assignTask(ctx, payload{Name: "Test Name", Skills: []string{"skill1"}, Priorty: "low"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("task.assignTask() error = %v, want %v", err, context.Canceled)
+	}
+	tasks, err := s.Tasks(context.Background(), DefaultTenant, taskFilter{})
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("task.assignTask() stored %v, %v, want no task", tasks, err)
+	}
+}
@@ -0,0 +1,156 @@
+package distributer
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// template holds the defaults of the tasks created with it, so the same kind
+// of task does not have to be described each time.
+type template struct {
+	Name string `json:"name"`
+	templateDefaults
+	// Author is the subject of the API key or token that created it.
+	Author     string    `json:"author"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// templateDefaults are the fields of a task a template fills in when the
+// request does not have them.
+type templateDefaults struct {
+	// NamePattern is the name of the tasks, with {date} and {time} replaced
+	// by when they are created, in UTC, and {metadata.<key>} by the value of
+	// the key in their metadata.
+	NamePattern string          `json:"name_pattern,omitempty"`
+	Skills      []string        `json:"skills,omitempty"`
+	Priority    string          `json:"priority,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	SLA         string          `json:"sla,omitempty"`
+}
+
+// copy returns the template without sharing the skills or metadata.
+func (tp template) copy() template {
+	tp.Skills = append([]string(nil), tp.Skills...)
+	tp.Metadata = append(json.RawMessage(nil), tp.Metadata...)
+	return tp
+}
+
+// namePlaceholder matches the placeholders of a name pattern.
+var namePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// apply fills in the fields the payload does not have with the defaults.  The
+// metadata is merged, the keys of the payload replacing the defaults, and the
+// name pattern is expanded with the merged metadata.
+func (d templateDefaults) apply(p *payload, now time.Time) {
+	if p.Skills == nil && len(d.Skills) > 0 {
+		p.Skills = append([]string(nil), d.Skills...)
+	}
+	if strings.TrimSpace(p.Priorty) == "" {
+		p.Priorty = d.Priority
+	}
+	if strings.TrimSpace(p.SLA) == "" {
+		p.SLA = d.SLA
+	}
+	p.Metadata = mergeObjects(d.Metadata, p.Metadata)
+	if strings.TrimSpace(p.Name) == "" && d.NamePattern != "" {
+		p.Name = d.expandName(p.Metadata, now)
+	}
+}
+
+// expandName returns the name pattern with its placeholders replaced, a key
+// the metadata does not have is empty.
+func (d templateDefaults) expandName(metadata json.RawMessage, now time.Time) string {
+	var values map[string]interface{}
+	json.Unmarshal(metadata, &values)
+	now = now.UTC()
+	return namePlaceholder.ReplaceAllStringFunc(d.NamePattern, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		switch name {
+		case "date":
+			return now.Format("2006-01-02")
+		case "time":
+			return now.Format("15:04")
+		}
+		switch value := values[strings.TrimPrefix(name, "metadata.")].(type) {
+		case nil:
+			return ""
+		case string:
+			return value
+		default:
+			b, _ := json.Marshal(value)
+			return string(b)
+		}
+	})
+}
+
+// mergeObjects returns the JSON object of the defaults with the keys of the
+// values, which are returned as they are when they are not an object so
+// validation reports them.
+func mergeObjects(defaults, values json.RawMessage) json.RawMessage {
+	if len(defaults) == 0 {
+		return values
+	}
+	if len(values) == 0 || string(values) == "null" {
+		return append(json.RawMessage(nil), defaults...)
+	}
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(defaults, &merged); err != nil {
+		return values
+	}
+	var overrides map[string]json.RawMessage
+	if err := json.Unmarshal(values, &overrides); err != nil || overrides == nil {
+		return values
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return values
+	}
+	return b
+}
+
+// templatePayload is the body of a new template.
+type templatePayload struct {
+	Name string `json:"name"`
+	templateDefaults
+}
+
+// validate normalizes the template and returns every invalid field.  Every
+// default is optional, the tasks created with the template must have the
+// fields it does not.  The error is only returned when the skills or
+// priorities can not be retrieved.
+func (p *templatePayload) validate(ctx context.Context, store Store, tenant string) ([]fieldError, error) {
+	v := &validator{}
+	p.Name = v.text("name", p.Name, maxTemplateLength)
+	if !v.has("name") && !validCode(p.Name) {
+		v.add("name", fieldInvalid, "name field must only have letters, digits, _ and -")
+	}
+	p.NamePattern = v.optionalText("name_pattern", p.NamePattern, maxTaskNameLength)
+	for _, match := range namePlaceholder.FindAllStringSubmatch(p.NamePattern, -1) {
+		key, isMetadata := strings.CutPrefix(match[1], "metadata.")
+		if match[1] != "date" && match[1] != "time" && !(isMetadata && validCode(key)) {
+			v.add("name_pattern", fieldInvalid, "name_pattern field placeholder %s must be {date}, {time} or {metadata.<key>}", match[0])
+		}
+	}
+	p.Skills = v.skills("skills", p.Skills, false)
+	if len(p.Skills) == 0 {
+		p.Skills = nil
+	}
+	p.Priority = v.optionalText("priority", p.Priority, maxPriorityLength)
+	p.Metadata = v.object("metadata", p.Metadata)
+	p.SLA = v.duration("sla", p.SLA, maxSLA)
+	if err := v.knownSkills(ctx, store, tenant, "skills", p.Skills); err != nil {
+		return nil, err
+	}
+	if p.Priority != "" && !v.has("priority") {
+		if level, err := store.PriorityLevel(ctx, tenant, p.Priority); err != nil || level == -1 {
+			v.add("priority", fieldUnsupported, "priority %s is not present", p.Priority)
+		}
+	}
+	return v.invalid, nil
+}
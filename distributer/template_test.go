@@ -0,0 +1,141 @@
+package distributer
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_templateDefaults_apply(t *testing.T) {
+	now := time.Date(2024, 1, 31, 9, 5, 0, 0, time.UTC)
+	defaults := templateDefaults{
+		NamePattern: "Call back {metadata.customer} on {date} at {time}",
+		Skills:      []string{"skill1"},
+		Priority:    "high",
+		Metadata:    json.RawMessage(`{"customer":"Jane","queue":"billing"}`),
+		SLA:         "4h0m0s",
+	}
+	tests := []struct {
+		name    string
+		payload payload
+		want    payload
+	}{
+		{
+			name:    "Defaults",
+			payload: payload{},
+			want: payload{Name: "Call back Jane on 2024-01-31 at 09:05", Skills: []string{"skill1"}, Priorty: "high", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`{"customer":"Jane","queue":"billing"}`), SLA: "4h0m0s",
+			}},
+		},
+		{
+			name: "Payload replaces the defaults",
+			payload: payload{Name: "Refund", Skills: []string{"skill2"}, Priorty: "low", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`{"queue":"refunds","order":12}`), SLA: "1h",
+			}},
+			want: payload{Name: "Refund", Skills: []string{"skill2"}, Priorty: "low", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`{"customer":"Jane","order":12,"queue":"refunds"}`), SLA: "1h",
+			}},
+		},
+		{
+			name:    "Name from the payload metadata",
+			payload: payload{taskDetails: taskDetails{Metadata: json.RawMessage(`{"customer":{"id":7}}`)}},
+			want: payload{Name: `Call back {"id":7} on 2024-01-31 at 09:05`, Skills: []string{"skill1"}, Priorty: "high", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`{"customer":{"id":7},"queue":"billing"}`), SLA: "4h0m0s",
+			}},
+		},
+		{
+			name:    "Invalid metadata is kept",
+			payload: payload{Name: "Refund", taskDetails: taskDetails{Metadata: json.RawMessage(`["order"]`)}},
+			want: payload{Name: "Refund", Skills: []string{"skill1"}, Priorty: "high", taskDetails: taskDetails{
+				Metadata: json.RawMessage(`["order"]`), SLA: "4h0m0s",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payload
+			defaults.apply(&p, now)
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("templateDefaults.apply() = %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func Test_templatePayload_validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     templatePayload
+		wantInvalid []string
+	}{
+		{name: "Only a name", payload: templatePayload{Name: "callback"}},
+		{
+			name: "Every default",
+			payload: templatePayload{Name: "callback", templateDefaults: templateDefaults{
+				NamePattern: "Call back {metadata.customer} on {date}", Skills: []string{"skill1"}, Priority: "high", Metadata: json.RawMessage(`{"queue":"billing"}`), SLA: "4h",
+			}},
+		},
+		{name: "No name", payload: templatePayload{}, wantInvalid: []string{"name required"}},
+		{name: "Name with spaces", payload: templatePayload{Name: "call back"}, wantInvalid: []string{"name invalid"}},
+		{
+			name: "Every invalid field",
+			payload: templatePayload{Name: "callback", templateDefaults: templateDefaults{
+				NamePattern: "Call back {customer}", Skills: []string{"skill9"}, Priority: "zzz", Metadata: json.RawMessage(`"billing"`), SLA: "soon",
+			}},
+			wantInvalid: []string{"name_pattern invalid", "metadata invalid", "sla invalid", "skills unsupported", "priority unsupported"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalid, err := tt.payload.validate(context.Background(), newTestStore(t), DefaultTenant)
+			if err != nil {
+				t.Fatalf("templatePayload.validate() error = %v", err)
+			}
+			var got []string
+			for _, fe := range invalid {
+				got = append(got, fe.Field+" "+fe.Code)
+			}
+			if !reflect.DeepEqual(got, tt.wantInvalid) {
+				t.Errorf("templatePayload.validate() = %v, want %v", invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func Test_Store_Templates(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			s := ts.open(t)
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			templates := []template{
+				{Name: "refund", templateDefaults: templateDefaults{Skills: []string{"skill2"}, Priority: "low"}, Author: "key1", CreateTime: now},
+				{Name: "callback", templateDefaults: templateDefaults{NamePattern: "Call back {date}", Metadata: json.RawMessage(`{"queue":"billing"}`), SLA: "4h0m0s"}, Author: "key1", CreateTime: now},
+			}
+			for _, tp := range templates {
+				if err := s.CreateTemplate(ctx, DefaultTenant, tp); err != nil {
+					t.Fatalf("Store.CreateTemplate() error = %v", err)
+				}
+			}
+			got, err := s.Templates(ctx, DefaultTenant)
+			if err != nil || len(got) != 2 || got[0].Name != "callback" || got[1].Skills[0] != "skill2" {
+				t.Errorf("Store.Templates() = %+v, %v, want both templates by name", got, err)
+			}
+			tp, err := s.Template(ctx, DefaultTenant, "callback")
+			if err != nil || tp.NamePattern != "Call back {date}" || string(tp.Metadata) != `{"queue":"billing"}` || tp.SLA != "4h0m0s" || !tp.CreateTime.Equal(now) {
+				t.Errorf("Store.Template() = %+v, %v, want the callback template", tp, err)
+			}
+			if _, err := s.Template(ctx, "other", "callback"); err == nil {
+				t.Errorf("Store.Template() of another tenant error = nil, want an error")
+			}
+			if deleted, err := s.DeleteTemplate(ctx, DefaultTenant, "refund"); err != nil || !deleted {
+				t.Errorf("Store.DeleteTemplate() = %v, %v, want true", deleted, err)
+			}
+			if deleted, err := s.DeleteTemplate(ctx, DefaultTenant, "refund"); err != nil || deleted {
+				t.Errorf("Store.DeleteTemplate() twice = %v, %v, want false", deleted, err)
+			}
+		})
+	}
+}
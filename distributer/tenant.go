@@ -0,0 +1,13 @@
+package distributer
+
+import "time"
+
+// DefaultTenant is the tenant of the data from before there were tenants.
+const DefaultTenant = "default"
+
+// tenant is a workspace with its own agents, skills, priorities and tasks.
+type tenant struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreateTime time.Time `json:"create_time"`
+}
@@ -0,0 +1,404 @@
+package distributer
+
+import (
+	"context"
+	"time"
+)
+
+// tracedStore starts a span for each call of the store it wraps.
+type tracedStore struct {
+	store  Store
+	tracer *Tracer
+	system string
+}
+
+// traceStore returns the store with a span for each call, or the store itself
+// when there is no tracer.
+func traceStore(store Store, tracer *Tracer) Store {
+	if tracer == nil {
+		return store
+	}
+	system := "memory"
+	switch store.(type) {
+	case *postgresStore:
+		system = "postgresql"
+	case *sqliteStore:
+		system = "sqlite"
+	}
+	return &tracedStore{store: store, tracer: tracer, system: system}
+}
+
+// start starts the span of a store call.
+func (s *tracedStore) start(ctx context.Context, op string) (context.Context, *span) {
+	ctx, sp := s.tracer.start(ctx, "store."+op, spanKindClient, nil)
+	sp.setAttributes("db.system", s.system, "db.operation", op)
+	return ctx, sp
+}
+
+func (s *tracedStore) Tenants(ctx context.Context) ([]tenant, error) {
+	ctx, sp := s.start(ctx, "Tenants")
+	defer sp.finish()
+	v, err := s.store.Tenants(ctx)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateTenant(ctx context.Context, t tenant) error {
+	ctx, sp := s.start(ctx, "CreateTenant")
+	defer sp.finish()
+	err := s.store.CreateTenant(ctx, t)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) SkillCount(ctx context.Context, tenant string, skills []string) (int, error) {
+	ctx, sp := s.start(ctx, "SkillCount")
+	defer sp.finish()
+	v, err := s.store.SkillCount(ctx, tenant, skills)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Skills(ctx context.Context, tenant string) ([]skill, error) {
+	ctx, sp := s.start(ctx, "Skills")
+	defer sp.finish()
+	v, err := s.store.Skills(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) SkillAgents(ctx context.Context, tenant string) (map[string]int, error) {
+	ctx, sp := s.start(ctx, "SkillAgents")
+	defer sp.finish()
+	v, err := s.store.SkillAgents(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateSkill(ctx context.Context, tenant string, sk skill) error {
+	ctx, sp := s.start(ctx, "CreateSkill")
+	defer sp.finish()
+	err := s.store.CreateSkill(ctx, tenant, sk)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) PriorityLevel(ctx context.Context, tenant, priority string) (int, error) {
+	ctx, sp := s.start(ctx, "PriorityLevel")
+	defer sp.finish()
+	v, err := s.store.PriorityLevel(ctx, tenant, priority)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Priorities(ctx context.Context, tenant string) ([]priority, error) {
+	ctx, sp := s.start(ctx, "Priorities")
+	defer sp.finish()
+	v, err := s.store.Priorities(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreatePriority(ctx context.Context, tenant string, p priority) error {
+	ctx, sp := s.start(ctx, "CreatePriority")
+	defer sp.finish()
+	err := s.store.CreatePriority(ctx, tenant, p)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Agents(ctx context.Context, tenant string, ids []string) ([]agent, error) {
+	ctx, sp := s.start(ctx, "Agents")
+	defer sp.finish()
+	v, err := s.store.Agents(ctx, tenant, ids)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) MatchingAgents(ctx context.Context, tenant string, skills []string) ([]agent, error) {
+	ctx, sp := s.start(ctx, "MatchingAgents")
+	defer sp.finish()
+	v, err := s.store.MatchingAgents(ctx, tenant, skills)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateAgent(ctx context.Context, tenant string, a agent) error {
+	ctx, sp := s.start(ctx, "CreateAgent")
+	defer sp.finish()
+	err := s.store.CreateAgent(ctx, tenant, a)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) UpdateAgentSkills(ctx context.Context, tenant, agentID string, skills []string) error {
+	ctx, sp := s.start(ctx, "UpdateAgentSkills")
+	defer sp.finish()
+	err := s.store.UpdateAgentSkills(ctx, tenant, agentID, skills)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) OpenTasks(ctx context.Context, tenant string, agentIDs []string) (map[string][]task, error) {
+	ctx, sp := s.start(ctx, "OpenTasks")
+	defer sp.finish()
+	v, err := s.store.OpenTasks(ctx, tenant, agentIDs)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) RecentAgent(ctx context.Context, tenant string, agentIDs []string, priorityLevel int) (string, error) {
+	ctx, sp := s.start(ctx, "RecentAgent")
+	defer sp.finish()
+	v, err := s.store.RecentAgent(ctx, tenant, agentIDs, priorityLevel)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) AgentTasks(ctx context.Context, tenant string) ([]agentTasks, error) {
+	ctx, sp := s.start(ctx, "AgentTasks")
+	defer sp.finish()
+	v, err := s.store.AgentTasks(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) TasksByAgent(ctx context.Context, tenant, agentID string) ([]task, error) {
+	ctx, sp := s.start(ctx, "TasksByAgent")
+	defer sp.finish()
+	v, err := s.store.TasksByAgent(ctx, tenant, agentID)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Tasks(ctx context.Context, tenant string, filter taskFilter) ([]task, error) {
+	ctx, sp := s.start(ctx, "Tasks")
+	defer sp.finish()
+	v, err := s.store.Tasks(ctx, tenant, filter)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Task(ctx context.Context, tenant, id string) (task, error) {
+	ctx, sp := s.start(ctx, "Task")
+	defer sp.finish()
+	v, err := s.store.Task(ctx, tenant, id)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateTask(ctx context.Context, tenant string, t task) error {
+	ctx, sp := s.start(ctx, "CreateTask")
+	defer sp.finish()
+	err := s.store.CreateTask(ctx, tenant, t)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) UpdateTaskStatus(ctx context.Context, tenant, id, status string, result taskResult) error {
+	ctx, sp := s.start(ctx, "UpdateTaskStatus")
+	defer sp.finish()
+	err := s.store.UpdateTaskStatus(ctx, tenant, id, status, result)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) TransitionTask(ctx context.Context, tenant, id, agentID string, from []string, to string, result taskResult) (bool, error) {
+	ctx, sp := s.start(ctx, "TransitionTask")
+	defer sp.finish()
+	v, err := s.store.TransitionTask(ctx, tenant, id, agentID, from, to, result)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateComment(ctx context.Context, tenant string, c comment) error {
+	ctx, sp := s.start(ctx, "CreateComment")
+	defer sp.finish()
+	err := s.store.CreateComment(ctx, tenant, c)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Comments(ctx context.Context, tenant, taskID string) ([]comment, error) {
+	ctx, sp := s.start(ctx, "Comments")
+	defer sp.finish()
+	v, err := s.store.Comments(ctx, tenant, taskID)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateAttachment(ctx context.Context, tenant string, a attachment) error {
+	ctx, sp := s.start(ctx, "CreateAttachment")
+	defer sp.finish()
+	err := s.store.CreateAttachment(ctx, tenant, a)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Attachments(ctx context.Context, tenant, taskID string) ([]attachment, error) {
+	ctx, sp := s.start(ctx, "Attachments")
+	defer sp.finish()
+	v, err := s.store.Attachments(ctx, tenant, taskID)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Attachment(ctx context.Context, tenant, taskID, id string) (attachment, error) {
+	ctx, sp := s.start(ctx, "Attachment")
+	defer sp.finish()
+	v, err := s.store.Attachment(ctx, tenant, taskID, id)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateSchedule(ctx context.Context, tenant string, sc schedule) error {
+	ctx, sp := s.start(ctx, "CreateSchedule")
+	defer sp.finish()
+	err := s.store.CreateSchedule(ctx, tenant, sc)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Schedules(ctx context.Context, tenant string) ([]schedule, error) {
+	ctx, sp := s.start(ctx, "Schedules")
+	defer sp.finish()
+	v, err := s.store.Schedules(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Schedule(ctx context.Context, tenant, id string) (schedule, error) {
+	ctx, sp := s.start(ctx, "Schedule")
+	defer sp.finish()
+	v, err := s.store.Schedule(ctx, tenant, id)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) SetSchedulePaused(ctx context.Context, tenant, id string, paused bool, nextRun *time.Time) (bool, error) {
+	ctx, sp := s.start(ctx, "SetSchedulePaused")
+	defer sp.finish()
+	v, err := s.store.SetSchedulePaused(ctx, tenant, id, paused, nextRun)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) DeleteSchedule(ctx context.Context, tenant, id string) (bool, error) {
+	ctx, sp := s.start(ctx, "DeleteSchedule")
+	defer sp.finish()
+	v, err := s.store.DeleteSchedule(ctx, tenant, id)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) DueSchedules(ctx context.Context, now time.Time, limit int) ([]schedule, error) {
+	ctx, sp := s.start(ctx, "DueSchedules")
+	defer sp.finish()
+	v, err := s.store.DueSchedules(ctx, now, limit)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) ClaimSchedule(ctx context.Context, tenant, id string, due, until time.Time) (bool, error) {
+	ctx, sp := s.start(ctx, "ClaimSchedule")
+	defer sp.finish()
+	v, err := s.store.ClaimSchedule(ctx, tenant, id, due, until)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) RecordScheduleRun(ctx context.Context, tenant, id string, run scheduleRun, nextRun *time.Time) error {
+	ctx, sp := s.start(ctx, "RecordScheduleRun")
+	defer sp.finish()
+	err := s.store.RecordScheduleRun(ctx, tenant, id, run, nextRun)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) CreateTemplate(ctx context.Context, tenant string, tp template) error {
+	ctx, sp := s.start(ctx, "CreateTemplate")
+	defer sp.finish()
+	err := s.store.CreateTemplate(ctx, tenant, tp)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Templates(ctx context.Context, tenant string) ([]template, error) {
+	ctx, sp := s.start(ctx, "Templates")
+	defer sp.finish()
+	v, err := s.store.Templates(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) Template(ctx context.Context, tenant, name string) (template, error) {
+	ctx, sp := s.start(ctx, "Template")
+	defer sp.finish()
+	v, err := s.store.Template(ctx, tenant, name)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) DeleteTemplate(ctx context.Context, tenant, name string) (bool, error) {
+	ctx, sp := s.start(ctx, "DeleteTemplate")
+	defer sp.finish()
+	v, err := s.store.DeleteTemplate(ctx, tenant, name)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateEvent(ctx context.Context, tenant string, e event) error {
+	ctx, sp := s.start(ctx, "CreateEvent")
+	defer sp.finish()
+	err := s.store.CreateEvent(ctx, tenant, e)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) Events(ctx context.Context, tenant string, after int64, limit int) ([]event, error) {
+	ctx, sp := s.start(ctx, "Events")
+	defer sp.finish()
+	v, err := s.store.Events(ctx, tenant, after, limit)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) EventsBetween(ctx context.Context, tenant string, from, to time.Time) ([]event, error) {
+	ctx, sp := s.start(ctx, "EventsBetween")
+	defer sp.finish()
+	v, err := s.store.EventsBetween(ctx, tenant, from, to)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) CreateAPIKey(ctx context.Context, key apiKey) error {
+	ctx, sp := s.start(ctx, "CreateAPIKey")
+	defer sp.finish()
+	err := s.store.CreateAPIKey(ctx, key)
+	sp.setError(err)
+	return err
+}
+
+func (s *tracedStore) APIKeyByHash(ctx context.Context, hash string) (apiKey, error) {
+	ctx, sp := s.start(ctx, "APIKeyByHash")
+	defer sp.finish()
+	v, err := s.store.APIKeyByHash(ctx, hash)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) APIKeys(ctx context.Context, tenant string) ([]apiKey, error) {
+	ctx, sp := s.start(ctx, "APIKeys")
+	defer sp.finish()
+	v, err := s.store.APIKeys(ctx, tenant)
+	sp.setError(err)
+	return v, err
+}
+
+func (s *tracedStore) RevokeAPIKey(ctx context.Context, tenant, id string) (bool, error) {
+	ctx, sp := s.start(ctx, "RevokeAPIKey")
+	defer sp.finish()
+	v, err := s.store.RevokeAPIKey(ctx, tenant, id)
+	sp.setError(err)
+	return v, err
+}
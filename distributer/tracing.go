@@ -0,0 +1,561 @@
+package distributer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of spans, named like the OpenTelemetry span kinds.
+const (
+	spanKindInternal = "internal"
+	spanKindServer   = "server"
+	spanKindClient   = "client"
+)
+
+// traceparentHeader is the W3C trace context header of a request.
+const traceparentHeader = "traceparent"
+
+// spanContext identifies a span within its trace.
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// parseTraceparent parses a W3C traceparent header, like
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(header string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == [16]byte{} {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == [8]byte{} {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// Span is an ended span of a request's trace, as given to the SpanExporter.
+// The ids are hex encoded and the parent id is empty for the first span of a
+// trace.
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Name     string
+	// Kind is internal, server or client, like the OpenTelemetry span kinds.
+	Kind  string
+	Start time.Time
+	End   time.Time
+	// Attributes are strings, ints or bools, named like the OpenTelemetry
+	// semantic conventions.
+	Attributes map[string]interface{}
+	// Error is the error of a failed span, empty when it succeeded.
+	Error string
+}
+
+// SpanExporter receives each span of a sampled trace once it ends.  It is
+// called while the request is handled, so it should hand the span off, like
+// the OTLP exporter of NewTracerFromEnv batches them, rather than send it.
+type SpanExporter interface {
+	ExportSpan(Span)
+}
+
+// span is an operation of a trace, exported once it ends.
+type span struct {
+	tracer     *Tracer
+	context    spanContext
+	parentID   [8]byte
+	name       string
+	kind       string
+	start      time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+// setAttributes sets the attributes, which are strings, ints or bools.
+func (sp *span) setAttributes(kv ...interface{}) {
+	if sp == nil {
+		return
+	}
+	for idx := 0; idx+1 < len(kv); idx += 2 {
+		sp.attributes[fmt.Sprint(kv[idx])] = kv[idx+1]
+	}
+}
+
+// setError marks the span as failed.
+func (sp *span) setError(err error) {
+	if sp == nil || err == nil {
+		return
+	}
+	sp.err = err.Error()
+}
+
+// finish ends the span and exports it when its trace is sampled.
+func (sp *span) finish() {
+	if sp == nil || !sp.context.sampled {
+		return
+	}
+	ended := Span{
+		TraceID:    hex.EncodeToString(sp.context.traceID[:]),
+		SpanID:     hex.EncodeToString(sp.context.spanID[:]),
+		Name:       sp.name,
+		Kind:       sp.kind,
+		Start:      sp.start,
+		End:        time.Now(),
+		Attributes: sp.attributes,
+		Error:      sp.err,
+	}
+	if sp.parentID != [8]byte{} {
+		ended.ParentID = hex.EncodeToString(sp.parentID[:])
+	}
+	sp.tracer.exporter.ExportSpan(ended)
+}
+
+// spanKey is the context key holding the current span.
+type spanKey struct{}
+
+// spanFromContext returns the current span, nil when the request is not
+// traced.
+func spanFromContext(ctx context.Context) *span {
+	sp, _ := ctx.Value(spanKey{}).(*span)
+	return sp
+}
+
+// Tracer starts the spans of the requests and store calls and gives the
+// sampled ones to its exporter once they end.  A request continues the trace
+// of its traceparent header, the sampler decides which traces are recorded.  A
+// nil Tracer does not trace.
+type Tracer struct {
+	exporter SpanExporter
+	sampler  sampler
+}
+
+// NewTracer returns a tracer exporting the spans with the exporter.  A
+// request's trace is sampled when its traceparent header is, and a request
+// without one starts a new sampled trace.
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{exporter: exporter, sampler: sampler{parentBased: true, ratio: 1}}
+}
+
+// NewTracerFromEnv returns the tracer configured by the standard OpenTelemetry
+// environment variables, or nil when OTEL_TRACES_EXPORTER is not set or is
+// none.  OTEL_TRACES_EXPORTER=console writes the spans to w as OTLP JSON
+// lines, otlp sends them to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or
+// OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces, using OTLP over HTTP with JSON
+// and the OTEL_EXPORTER_OTLP_HEADERS.  The service is OTEL_SERVICE_NAME and
+// the traces are sampled by OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+// Spans that can not be exported are logged by the logger.
+func NewTracerFromEnv(getenv func(string) string, w io.Writer, logger *slog.Logger) (*Tracer, error) {
+	service := getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "task-distributer"
+	}
+	smp, err := parseSampler(getenv("OTEL_TRACES_SAMPLER"), getenv("OTEL_TRACES_SAMPLER_ARG"))
+	if err != nil {
+		return nil, err
+	}
+	var send func(ctx context.Context, spans []Span) error
+	interval := 5 * time.Second
+	switch exporter := getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "none":
+		return nil, nil
+	case "console":
+		var mu sync.Mutex
+		send = func(ctx context.Context, spans []Span) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return json.NewEncoder(w).Encode(encodeOTLP(service, spans))
+		}
+		interval = time.Second
+	case "otlp":
+		if protocol := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+			return nil, fmt.Errorf("OTLP protocol %s is not supported, use http/json", protocol)
+		}
+		endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+		if endpoint == "" {
+			base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+			if base == "" {
+				base = "http://localhost:4318"
+			}
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+		headers := map[string]string{}
+		for _, pair := range strings.Split(getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+			if key, value, ok := strings.Cut(pair, "="); ok {
+				headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		otlp := &otlpSender{endpoint: endpoint, headers: headers, service: service, client: &http.Client{Timeout: 10 * time.Second}}
+		send = otlp.send
+	default:
+		return nil, fmt.Errorf("traces exporter %s is not supported, use otlp, console or none", exporter)
+	}
+	return &Tracer{
+		exporter: newBatchExporter(send, interval, logger),
+		sampler:  smp,
+	}, nil
+}
+
+// Shutdown exports the spans that are still pending, when the exporter
+// batches them.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	if b, ok := t.exporter.(*batchExporter); ok {
+		return b.shutdown(ctx)
+	}
+	return nil
+}
+
+// sampler decides if a trace is recorded.  A parent based sampler follows the
+// sampled flag of the request's traceparent header, the others, and a parent
+// based one for a trace the request starts, sample the ratio of the traces.
+type sampler struct {
+	parentBased bool
+	ratio       float64
+}
+
+// parseSampler parses an OTEL_TRACES_SAMPLER name and its ratio argument, the
+// default is parentbased_always_on.
+func parseSampler(name, arg string) (sampler, error) {
+	ratio := 1.0
+	if strings.HasSuffix(name, "traceidratio") && arg != "" {
+		var err error
+		if ratio, err = strconv.ParseFloat(arg, 64); err != nil || ratio < 0 || ratio > 1 {
+			return sampler{}, fmt.Errorf("traces sampler ratio %s must be between 0 and 1", arg)
+		}
+	}
+	switch name {
+	case "", "parentbased_always_on":
+		return sampler{parentBased: true, ratio: 1}, nil
+	case "parentbased_always_off":
+		return sampler{parentBased: true, ratio: 0}, nil
+	case "parentbased_traceidratio":
+		return sampler{parentBased: true, ratio: ratio}, nil
+	case "always_on":
+		return sampler{ratio: 1}, nil
+	case "always_off":
+		return sampler{ratio: 0}, nil
+	case "traceidratio":
+		return sampler{ratio: ratio}, nil
+	default:
+		return sampler{}, fmt.Errorf("traces sampler %s is not supported", name)
+	}
+}
+
+// sample reports if the trace is recorded, remote is the span of the
+// request's traceparent header, if it has one.  The ratio is taken of the
+// random lower half of the trace id, so every service sampling by the same
+// ratio keeps the same traces.
+func (s sampler) sample(traceID [16]byte, remote *spanContext) bool {
+	if s.parentBased && remote != nil {
+		return remote.sampled
+	}
+	switch {
+	case s.ratio >= 1:
+		return true
+	case s.ratio <= 0:
+		return false
+	}
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < uint64(s.ratio*(1<<63))
+}
+
+// start starts a span, a child of the context's span or of the remote parent.
+// The context with the span is returned.
+func (t *Tracer) start(ctx context.Context, name, kind string, remote *spanContext) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	sp := &span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+	switch parent := spanFromContext(ctx); {
+	case parent != nil:
+		sp.context = parent.context
+		sp.parentID = parent.context.spanID
+	case remote != nil:
+		sp.context = *remote
+		sp.parentID = remote.spanID
+		sp.context.sampled = t.sampler.sample(remote.traceID, remote)
+	default:
+		rand.Read(sp.context.traceID[:])
+		sp.context.sampled = t.sampler.sample(sp.context.traceID, nil)
+	}
+	rand.Read(sp.context.spanID[:])
+	return context.WithValue(ctx, spanKey{}, sp), sp
+}
+
+// startSpan starts a child span of the context's span, nothing is traced when
+// the context has no span.
+func startSpan(ctx context.Context, name, kind string) (context.Context, *span) {
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.start(ctx, name, kind, nil)
+}
+
+// maxPendingSpans is how many spans are kept for the next export, more are
+// dropped so a slow backend can not exhaust the memory.
+const maxPendingSpans = 2048
+
+// batchExporter queues the ended spans and sends them every interval, so a
+// request never waits for the tracing backend.
+type batchExporter struct {
+	send   func(ctx context.Context, spans []Span) error
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending []Span
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newBatchExporter(send func(ctx context.Context, spans []Span) error, interval time.Duration, logger *slog.Logger) *batchExporter {
+	b := &batchExporter{
+		send:   send,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run(interval)
+	return b
+}
+
+func (b *batchExporter) ExportSpan(sp Span) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) < maxPendingSpans {
+		b.pending = append(b.pending, sp)
+	}
+}
+
+// run sends the pending spans every interval until the exporter is shut down.
+func (b *batchExporter) run(interval time.Duration) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.flush(context.Background())
+		}
+	}
+}
+
+// flush sends the pending spans.
+func (b *batchExporter) flush(ctx context.Context) error {
+	b.mu.Lock()
+	spans := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := b.send(ctx, spans); err != nil {
+		if b.logger != nil {
+			b.logger.Error("unable to export spans", "error", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// shutdown stops sending every interval and sends the spans still pending.
+func (b *batchExporter) shutdown(ctx context.Context) error {
+	close(b.done)
+	b.wg.Wait()
+	return b.flush(ctx)
+}
+
+// OTLP JSON encoding of the spans, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpKinds are the OTLP numbers of the span kinds.
+var otlpKinds = map[string]int{
+	spanKindInternal: 1,
+	spanKindServer:   2,
+	spanKindClient:   3,
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch value := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": value}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+}
+
+// encodeOTLP encodes the spans as an OTLP export request.
+func encodeOTLP(service string, spans []Span) otlpTraces {
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: otlpValue(service)}}
+	var ss otlpScopeSpans
+	ss.Scope.Name = "task-distributer/distributer"
+	for _, sp := range spans {
+		out := otlpSpan{
+			TraceID:           sp.TraceID,
+			SpanID:            sp.SpanID,
+			ParentSpanID:      sp.ParentID,
+			Name:              sp.Name,
+			Kind:              otlpKinds[sp.Kind],
+			StartTimeUnixNano: strconv.FormatInt(sp.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(sp.End.UnixNano(), 10),
+		}
+		for _, key := range sortedKeys(sp.Attributes) {
+			out.Attributes = append(out.Attributes, otlpAttribute{Key: key, Value: otlpValue(sp.Attributes[key])})
+		}
+		if sp.Error != "" {
+			out.Status = &otlpStatus{Code: 2, Message: sp.Error}
+		}
+		ss.Spans = append(ss.Spans, out)
+	}
+	rs.ScopeSpans = []otlpScopeSpans{ss}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{rs}}
+}
+
+// otlpSender sends the spans to an OTLP collector over HTTP with JSON.
+type otlpSender struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+}
+
+func (o *otlpSender) send(ctx context.Context, spans []Span) error {
+	body, err := json.Marshal(encodeOTLP(o.service, spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range o.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := o.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to export spans %s", err.Error())
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New("unable to export spans " + response.Status)
+	}
+	return nil
+}
+
+// trace starts a server span for each request, continuing the trace of the
+// request's traceparent header, and tags the request's log lines with the
+// trace id.
+func (s *Server) trace(next http.Handler) http.Handler {
+	if s.tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var remote *spanContext
+		if sc, ok := parseTraceparent(request.Header.Get(traceparentHeader)); ok {
+			remote = &sc
+		}
+		ctx, sp := s.tracer.start(request.Context(), request.Method, spanKindServer, remote)
+		ctx = withLogger(ctx, ctxLogger(ctx).With("trace_id", hex.EncodeToString(sp.context.traceID[:])))
+		recorder := &statusRecorder{ResponseWriter: writer}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if route := requestRoute(ctx); route != "" {
+			sp.name = request.Method + " " + route
+			sp.setAttributes("http.route", route)
+		}
+		sp.setAttributes(
+			"http.request.method", request.Method,
+			"url.path", request.URL.Path,
+			"http.response.status_code", recorder.status,
+		)
+		if recorder.status >= http.StatusInternalServerError {
+			sp.setError(errors.New(http.StatusText(recorder.status)))
+		}
+		sp.finish()
+	})
+}
@@ -0,0 +1,297 @@
+package distributer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_parseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantOK      bool
+		wantSampled bool
+	}{
+		{
+			name:        "Sampled",
+			header:      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantOK:      true,
+			wantSampled: true,
+		},
+		{
+			name:   "Not sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantOK: true,
+		},
+		{
+			name:        "Future version with more fields",
+			header:      "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			wantOK:      true,
+			wantSampled: true,
+		},
+		{
+			name:   "Zero trace id",
+			header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name:   "Short span id",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01",
+		},
+		{
+			name:   "Invalid version",
+			header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name: "Missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := parseTraceparent(tt.header)
+			if ok != tt.wantOK || sc.sampled != tt.wantSampled {
+				t.Errorf("parseTraceparent() = %v, %v, want %v, sampled %v", sc, ok, tt.wantOK, tt.wantSampled)
+			}
+		})
+	}
+}
+
+// recordingExporter keeps the exported spans.
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (e *recordingExporter) ExportSpan(sp Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, sp)
+}
+
+func Test_Server_trace(t *testing.T) {
+	exporter := &recordingExporter{}
+	s, err := NewServer(newTestStore(t), Config{Tracer: NewTracer(exporter)})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/task/create", strings.NewReader(`{"name":"Test Name","skills":["skill1"],"priority":"low"}`))
+	request.Header.Set("X-API-Key", submitter)
+	request.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.ServeHTTP(httptest.NewRecorder(), request)
+
+	// The spans of issuing the key are not part of the request's trace.
+	byName := map[string]Span{}
+	for _, sp := range exporter.spans {
+		if sp.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			byName[sp.Name] = sp
+		}
+	}
+	server := byName["POST /v1/task/create"]
+	if server.ParentID != "00f067aa0ba902b7" || server.Kind != spanKindServer || server.Attributes["http.response.status_code"] != http.StatusOK {
+		t.Fatalf("server span = %+v, want a child of the request's span", server)
+	}
+	assign := byName["assignTask"]
+	if assign.ParentID != server.SpanID || assign.Attributes["task.agent"] != "1000" {
+		t.Fatalf("assignTask span = %+v, want a child of the server span", assign)
+	}
+	for _, name := range []string{"store.APIKeyByHash", "store.Skills"} {
+		if sp := byName[name]; sp.ParentID != server.SpanID || sp.Attributes["db.system"] != "memory" {
+			t.Errorf("%s span = %+v, want a child of the server span", name, sp)
+		}
+	}
+	for _, name := range []string{"store.MatchingAgents", "store.OpenTasks", "store.CreateTask"} {
+		if sp := byName[name]; sp.ParentID != assign.SpanID {
+			t.Errorf("%s span = %+v, want a child of the assignTask span", name, sp)
+		}
+	}
+
+	// A trace the caller did not sample is not exported.
+	exporter.spans = nil
+	request = httptest.NewRequest(http.MethodGet, "/v1/skill", nil)
+	request.Header.Set("X-API-Key", submitter)
+	request.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	s.ServeHTTP(httptest.NewRecorder(), request)
+	if len(exporter.spans) != 0 {
+		t.Errorf("exported spans = %+v, want none of the trace that is not sampled", exporter.spans)
+	}
+}
+
+func Test_NewTracerFromEnv(t *testing.T) {
+	var received struct {
+		sync.Mutex
+		path   string
+		header string
+		body   []byte
+	}
+	collector := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received.Lock()
+		defer received.Unlock()
+		received.path = request.URL.Path
+		received.header = request.Header.Get("Authorization")
+		received.body, _ = io.ReadAll(request.Body)
+	}))
+	defer collector.Close()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "Not configured",
+			wantNil: true,
+		},
+		{
+			name: "Console",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "console"},
+		},
+		{
+			name: "OTLP",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":        "otlp",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL,
+				"OTEL_EXPORTER_OTLP_HEADERS":  "Authorization=Bearer test",
+				"OTEL_SERVICE_NAME":           "tasks",
+			},
+		},
+		{
+			name:    "OTLP over gRPC",
+			env:     map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown exporter",
+			env:     map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown sampler",
+			env:     map[string]string{"OTEL_TRACES_EXPORTER": "console", "OTEL_TRACES_SAMPLER": "jaeger_remote"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid ratio",
+			env:     map[string]string{"OTEL_TRACES_EXPORTER": "console", "OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tracer, err := NewTracerFromEnv(func(key string) string { return tt.env[key] }, &out, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTracerFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (tracer == nil) != (tt.wantNil || tt.wantErr) {
+				t.Fatalf("NewTracerFromEnv() = %v, want nil %v", tracer, tt.wantNil)
+			}
+			if tracer == nil {
+				return
+			}
+			_, sp := tracer.start(context.Background(), "test", spanKindServer, nil)
+			sp.setError(errors.New("failed"))
+			sp.finish()
+			if err := tracer.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Tracer.Shutdown() error = %v", err)
+			}
+
+			body := out.Bytes()
+			service := "task-distributer"
+			if tt.env["OTEL_TRACES_EXPORTER"] == "otlp" {
+				received.Lock()
+				defer received.Unlock()
+				if received.path != "/v1/traces" || received.header != "Bearer test" {
+					t.Errorf("collector received %s with %q, want /v1/traces with the headers", received.path, received.header)
+				}
+				body = received.body
+				service = tt.env["OTEL_SERVICE_NAME"]
+			}
+			var traces otlpTraces
+			if err := json.Unmarshal(body, &traces); err != nil || len(traces.ResourceSpans) != 1 {
+				t.Fatalf("exported %s, %v, want the OTLP JSON of the span", body, err)
+			}
+			rs := traces.ResourceSpans[0]
+			if rs.Resource.Attributes[0].Value["stringValue"] != service {
+				t.Errorf("exported service %v, want %s", rs.Resource.Attributes, service)
+			}
+			got := rs.ScopeSpans[0].Spans[0]
+			if got.Name != "test" || got.Kind != 2 || got.Status == nil || got.Status.Code != 2 {
+				t.Errorf("exported span %+v, want the failed server span", got)
+			}
+		})
+	}
+}
+
+func Test_sampler_sample(t *testing.T) {
+	// The lower half of the trace id is 0x4000... and 0xc000..., a quarter and
+	// three quarters of the way.
+	low := [16]byte{8: 0x40}
+	high := [16]byte{8: 0xc0}
+	sampled := &spanContext{sampled: true}
+	notSampled := &spanContext{}
+	tests := []struct {
+		name    string
+		sampler string
+		arg     string
+		traceID [16]byte
+		remote  *spanContext
+		want    bool
+	}{
+		{name: "Default without a parent", traceID: high, want: true},
+		{name: "Default with a sampled parent", traceID: high, remote: sampled, want: true},
+		{name: "Default with a parent not sampled", traceID: high, remote: notSampled, want: false},
+		{name: "Always off", sampler: "always_off", traceID: low, remote: sampled, want: false},
+		{name: "Always on", sampler: "always_on", traceID: high, remote: notSampled, want: true},
+		{name: "Parent based always off without a parent", sampler: "parentbased_always_off", traceID: low, want: false},
+		{name: "Parent based always off with a sampled parent", sampler: "parentbased_always_off", traceID: low, remote: sampled, want: true},
+		{name: "Ratio below", sampler: "traceidratio", arg: "0.5", traceID: low, want: true},
+		{name: "Ratio above", sampler: "traceidratio", arg: "0.5", traceID: high, want: false},
+		{name: "Parent based ratio without a parent", sampler: "parentbased_traceidratio", arg: "0.5", traceID: high, want: false},
+		{name: "Parent based ratio with a sampled parent", sampler: "parentbased_traceidratio", arg: "0.5", traceID: high, remote: sampled, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSampler(tt.sampler, tt.arg)
+			if err != nil {
+				t.Fatalf("parseSampler() error = %v", err)
+			}
+			if got := s.sample(tt.traceID, tt.remote); got != tt.want {
+				t.Errorf("sampler.sample() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Server_trace_sampler(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter)
+	tracer.sampler = sampler{parentBased: true, ratio: 0}
+	s, err := NewServer(newTestStore(t), Config{Tracer: tracer})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	submitter := testAPIKey(t, s, RoleSubmitter, "")
+
+	// Only the request continuing a sampled trace is exported.
+	request := httptest.NewRequest(http.MethodGet, "/v1/skill", nil)
+	request.Header.Set("X-API-Key", submitter)
+	s.ServeHTTP(httptest.NewRecorder(), request)
+	if len(exporter.spans) != 0 {
+		t.Errorf("exported spans = %+v, want none of a request without a traceparent", exporter.spans)
+	}
+	request.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.ServeHTTP(httptest.NewRecorder(), request)
+	if len(exporter.spans) == 0 {
+		t.Errorf("exported no spans, want the spans of the sampled request")
+	}
+}
@@ -0,0 +1,236 @@
+package distributer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The limits of the requests.  The lengths are in characters and match the
+// columns they are stored in.
+const (
+	maxPayloadSize       = 64 << 10
+	maxTaskNameLength    = 200
+	maxTaskSkills        = 20
+	maxSkillLength       = 100
+	maxPriorityLength    = 100
+	maxAgentIDLength     = 10
+	maxAgentNameLength   = 100
+	maxDescriptionLength = 1000
+	maxTaskDescription   = 10000
+	maxMetadataSize      = 16 << 10
+	maxTaskTags          = 20
+	maxTagLength         = 50
+	maxURLLength         = 2048
+	maxOutcomeLength     = 50
+	maxResultLength      = 10000
+	maxCommentLength     = 10000
+	maxFileNameLength    = 255
+	maxCronLength        = 100
+	maxTemplateLength    = 100
+	maxSLA               = 365 * 24 * time.Hour
+)
+
+// errPayloadTooLarge is returned when a request body is over maxPayloadSize.
+var errPayloadTooLarge = fmt.Errorf("payload must be at most %d bytes", maxPayloadSize)
+
+// decodePayload decodes the JSON request body into the payload.  The body
+// must be a single JSON value of at most maxPayloadSize bytes without fields
+// the payload does not have.  An empty body returns io.EOF.
+func decodePayload(body io.ReadCloser, payload interface{}) error {
+	defer body.Close()
+	limited := &io.LimitedReader{R: body, N: maxPayloadSize + 1}
+	decoder := json.NewDecoder(limited)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(payload)
+	if err == nil && decoder.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("payload must be a single JSON value")
+	}
+	if limited.N <= 0 {
+		return errPayloadTooLarge
+	}
+	return err
+}
+
+// payloadError is the error response of a body decodePayload can not decode.
+func payloadError(err error) *apiError {
+	if errors.Is(err, errPayloadTooLarge) {
+		return newAPIError(http.StatusRequestEntityTooLarge, codePayloadTooLarge, "Unable to decode payload %s", err.Error())
+	}
+	return newAPIError(http.StatusBadRequest, codeInvalidPayload, "Unable to decode payload %s", err.Error())
+}
+
+// validator collects every invalid field of a request so they are reported
+// at once.
+type validator struct {
+	invalid []fieldError
+}
+
+func (v *validator) add(field, code, format string, args ...interface{}) {
+	v.invalid = append(v.invalid, fieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// has reports whether the field, or an element of it, is invalid.
+func (v *validator) has(field string) bool {
+	for _, fe := range v.invalid {
+		if fe.Field == field || strings.HasPrefix(fe.Field, field+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// text returns the field without surrounding white space, which must be
+// present and at most max characters.
+func (v *validator) text(field, value string, max int) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		v.add(field, fieldRequired, "%s field must be present", field)
+	case utf8.RuneCountInString(value) > max:
+		v.add(field, fieldTooLong, "%s field must be at most %d characters", field, max)
+	}
+	return value
+}
+
+// skills returns the skills without surrounding white space or duplicates.
+// Each must be present and at most maxSkillLength characters, and a nil list
+// is only valid when the skills are not required.
+func (v *validator) skills(field string, skills []string, required bool) []string {
+	return v.list(field, skills, required, maxTaskSkills, maxSkillLength)
+}
+
+// list returns the values without surrounding white space or duplicates.
+// There must be at most maxValues, each present and at most maxLength
+// characters, and a required list must have a value.
+func (v *validator) list(field string, values []string, required bool, maxValues, maxLength int) []string {
+	if values == nil {
+		if required {
+			v.add(field, fieldRequired, "%s field must be present", field)
+		}
+		return nil
+	}
+	normalized := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for idx, value := range values {
+		value = v.text(fmt.Sprintf("%s[%d]", field, idx), value, maxLength)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		normalized = append(normalized, value)
+	}
+	if required && len(values) == 0 {
+		v.add(field, fieldRequired, "%s field must have a value", field)
+	}
+	if len(normalized) > maxValues {
+		v.add(field, fieldTooLong, "%s field must have at most %d values", field, maxValues)
+	}
+	return normalized
+}
+
+// optionalText returns the field without surrounding white space, which must
+// be at most max characters when it is present.
+func (v *validator) optionalText(field, value string, max int) string {
+	if value = strings.TrimSpace(value); value != "" {
+		value = v.text(field, value, max)
+	}
+	return value
+}
+
+// code returns the field without surrounding white space, which must only be
+// letters, digits, underscores and dashes when it is present.
+func (v *validator) code(field, value string, max int) string {
+	value = v.optionalText(field, value, max)
+	if value != "" && !v.has(field) && !validCode(value) {
+		v.add(field, fieldInvalid, "%s field must only have letters, digits, _ and -", field)
+	}
+	return value
+}
+
+// validCode reports whether the value is only letters, digits, underscores and
+// dashes, so it is safe in a JSON path or a URL.
+func validCode(value string) bool {
+	return value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) < 0
+}
+
+// link returns the field without surrounding white space, which must be an
+// absolute http or https URL of at most maxURLLength characters when it is
+// present.
+func (v *validator) link(field, value string) string {
+	value = v.optionalText(field, value, maxURLLength)
+	if value == "" || v.has(field) {
+		return value
+	}
+	if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, fieldInvalid, "%s field must be an http or https URL", field)
+	}
+	return value
+}
+
+// duration returns the field as a Go duration, like 4h30m, which must be
+// positive and at most max when it is present.
+func (v *validator) duration(field, value string, max time.Duration) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	d, err := time.ParseDuration(value)
+	switch {
+	case err != nil || d <= 0:
+		v.add(field, fieldInvalid, "%s field must be a positive duration, like 4h or 30m", field)
+		return value
+	case d > max:
+		v.add(field, fieldTooLong, "%s field must be at most %s", field, max)
+	}
+	return d.String()
+}
+
+// object returns the field compacted, which must be a JSON object of at most
+// maxMetadataSize bytes when it is present.  A null object is not present.
+func (v *validator) object(field string, value json.RawMessage) json.RawMessage {
+	if len(value) == 0 || string(value) == "null" {
+		return nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil || compact.Bytes()[0] != '{' {
+		v.add(field, fieldInvalid, "%s field must be a JSON object", field)
+		return value
+	}
+	if compact.Len() > maxMetadataSize {
+		v.add(field, fieldTooLong, "%s field must be at most %d bytes", field, maxMetadataSize)
+	}
+	return compact.Bytes()
+}
+
+// knownSkills checks the skills are present in the tenant, the error is only
+// returned when the skills can not be retrieved.
+func (v *validator) knownSkills(ctx context.Context, store Store, tenant, field string, skills []string) error {
+	if len(skills) == 0 || v.has(field) {
+		return nil
+	}
+	present, err := store.Skills(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(present))
+	for _, sk := range present {
+		known[sk.Skill] = true
+	}
+	for _, sk := range skills {
+		if !known[sk] {
+			v.add(field, fieldUnsupported, "skill %s is not present", sk)
+		}
+	}
+	return nil
+}
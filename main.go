@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"task-distributer/distributer"
+)
+
+// commit and buildTime are set when building, like
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Heroku's dyno metadata commit is used when they are not.
+var (
+	commit    string
+	buildTime string
+)
+
+func main() {
+	// Heroku sends a SIGTERM before restarting a dyno, the requests in flight
+	// are finished before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Logs are json lines unless LOG_FORMAT=text, which is easier to read
+	// when running locally.
+	logger, err := distributer.NewLogger(os.Stderr, envDefault("LOG_FORMAT", "json"), envDefault("LOG_LEVEL", "info"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	// Spans are exported when OTEL_TRACES_EXPORTER is otlp, or written to the
+	// standard output when it is console.
+	tracer, err := distributer.NewTracerFromEnv(os.Getenv, os.Stdout, logger)
+	if err != nil {
+		fatal("error configuring tracing", err)
+	}
+	defer shutdownTracer(tracer)
+
+	if commit == "" {
+		commit = os.Getenv("HEROKU_SLUG_COMMIT")
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	store, err := distributer.OpenStore(ctx, databaseURL)
+	if err != nil {
+		fatal("error opening database", err)
+	}
+	blobs, err := distributer.OpenBlobStore(os.Getenv("BLOB_STORE_URL"), os.Getenv)
+	if err != nil {
+		fatal("error opening blob store", err)
+	}
+	server, err := distributer.NewServer(store, distributer.Config{
+		Blobs:            blobs,
+		JWTSecret:        []byte(os.Getenv("JWT_SECRET")),
+		Logger:           logger,
+		MetricsToken:     os.Getenv("METRICS_TOKEN"),
+		AnonymousMetrics: os.Getenv("METRICS_ANONYMOUS") == "true",
+		Tracer:           tracer,
+		Build: distributer.BuildInfo{
+			Commit: commit,
+			Time:   buildTime,
+		},
+	})
+	if err != nil {
+		fatal("error creating server", err)
+	}
+
+	if len(os.Args) > 1 {
+		if err := server.RunCommand(ctx, os.Args[1:]); err != nil {
+			shutdownTracer(tracer)
+			log.Fatal(err)
+		}
+		return
+	}
+
+	port := os.Getenv("PORT")
+
+	if port == "" {
+		fatal("error starting server", errors.New("$PORT must be set"))
+	}
+
+	if strings.HasPrefix(databaseURL, "memory://") {
+		key, err := server.IssueAPIKey(ctx, distributer.DefaultTenant, "local", distributer.RoleAdmin, "")
+		if err != nil {
+			fatal("error issuing local API key", err)
+		}
+		logger.Info("using the memory store", "admin_api_key", key)
+	}
+
+	if err := server.ListenAndServe(ctx, ":"+port); err != nil {
+		fatal("error serving", err)
+	}
+}
+
+// envDefault returns the environment variable, or the default when it is not
+// set.
+func envDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// shutdownTracer exports the spans not yet exported.
+func shutdownTracer(tracer *distributer.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("error exporting spans", "error", err)
+	}
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}